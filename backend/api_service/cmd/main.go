@@ -5,6 +5,9 @@ import (
 	"api_service/internal"
 	"encoding/json"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 func enableCORS(next http.HandlerFunc) http.HandlerFunc {
@@ -12,6 +15,7 @@ func enableCORS(next http.HandlerFunc) http.HandlerFunc {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Next-Cursor")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -25,7 +29,9 @@ func enableCORS(next http.HandlerFunc) http.HandlerFunc {
 func loggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		configs.APILogger.Printf("Received %s request for %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
-		configs.APILogger.Printf("Headers: %v", r.Header)
+		headers := r.Header.Clone()
+		headers.Del("X-Internal-Token")
+		configs.APILogger.Printf("Headers: %v", headers)
 
 		next(w, r)
 	}
@@ -42,7 +48,15 @@ func main() {
 	wrappedLogin := enableCORS(loggingMiddleware(handler.AuthHandler))
 	wrappedChecker := enableCORS(loggingMiddleware(handler.CheckerHandler))
 	wrappedCheckers := enableCORS(loggingMiddleware(handler.CheckersHandler))
-	wrappedPingAll := enableCORS(loggingMiddleware(handler.PingAllHandler))
+	wrappedIncidents := enableCORS(loggingMiddleware(handler.IncidentsHandler))
+	wrappedStatusPages := enableCORS(loggingMiddleware(handler.StatusPagesHandler))
+	wrappedPublicStatus := enableCORS(loggingMiddleware(handler.PublicStatusHandler))
+	wrappedMaintenance := enableCORS(loggingMiddleware(handler.MaintenanceWindowsHandler))
+	wrappedChannels := enableCORS(loggingMiddleware(handler.ChannelsHandler))
+	// Внутренние эндпоинты без CORS: их вызывают cron и админские скрипты, не браузер
+	wrappedPingAll := loggingMiddleware(handler.PingAllHandler)
+	wrappedJobs := loggingMiddleware(handler.JobsHandler)
+	wrappedHeartbeat := enableCORS(loggingMiddleware(handler.HeartbeatHandler))
 	
 	wrappedSwaggerSpec := enableCORS(loggingMiddleware(swaggerHandler.ServeSwaggerSpec))
 	wrappedSwaggerUI := enableCORS(loggingMiddleware(swaggerHandler.ServeSwaggerUI))
@@ -51,7 +65,19 @@ func main() {
 	http.HandleFunc("/login", wrappedLogin)
 	http.HandleFunc("/checker/", wrappedChecker)
 	http.HandleFunc("/checkers", wrappedCheckers)
-	http.HandleFunc("/pingAll", wrappedPingAll)
+	http.HandleFunc("/incidents", wrappedIncidents)
+	http.HandleFunc("/incidents/", wrappedIncidents)
+	http.HandleFunc("/status-pages", wrappedStatusPages)
+	http.HandleFunc("/status-pages/", wrappedStatusPages)
+	http.HandleFunc("/status/", wrappedPublicStatus) // без JWT: публичная страница по slug
+	http.HandleFunc("/maintenance-windows", wrappedMaintenance)
+	http.HandleFunc("/maintenance-windows/", wrappedMaintenance)
+	http.HandleFunc("/notification-channels", wrappedChannels)
+	http.HandleFunc("/notification-channels/", wrappedChannels)
+	http.HandleFunc("/pingAll", wrappedPingAll) // только с X-Internal-Token
+	http.HandleFunc("/jobs", wrappedJobs)
+	http.HandleFunc("/jobs/", wrappedJobs)
+	http.HandleFunc("/heartbeat/", wrappedHeartbeat) // без JWT: секрет - токен в URL
 
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -68,7 +94,28 @@ func main() {
 
 	configs.APILogger.Println("API Service starting on :8080")
 
-	go configs.StartCronScheduler("http://localhost:8080")
+	// Планировщик и поиск пропущенных heartbeat работают на всех репликах,
+	// но проверки запускает только держатель аренды
+	leader := handler.Leader()
+	leader.Start()
+
+	scheduler := internal.NewScheduler(handler)
+	scheduler.Start()
+
+	sweeper := internal.NewHeartbeatSweeper(handler)
+	sweeper.Start()
+
+	// При остановке контейнера отдаём аренду, чтобы другая реплика сразу стала лидером
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		<-sig
+		configs.APILogger.Println("API Service shutting down")
+		scheduler.Stop()
+		sweeper.Stop()
+		leader.Stop()
+		os.Exit(0)
+	}()
 
 	err := http.ListenAndServe(":8080", nil)
 	if err != nil {
@@ -1,14 +1,18 @@
 package configs
 
 import (
+	"api_service/models"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/go-co-op/gocron"
 	"github.com/segmentio/kafka-go"
 )
 
@@ -20,16 +24,92 @@ const (
 	KafkaAddr   = "kafka1:29092"
 )
 
+// Параметры планировщика проверок
+const (
+	SchedulerTick        = time.Second      // как часто ищем сайты, которым пора на проверку
+	SitesRefreshInterval = 30 * time.Second // как часто перечитываем список сайтов из db_service
+	DefaultCheckInterval = 60               // интервал по умолчанию, сек
+	MinCheckInterval     = 10               // минимально допустимый интервал, сек
+	MaxWindowSize        = 64               // максимум проверок в окне "M из K" (site_states.recent_results)
+	HeartbeatSweepTick   = 15 * time.Second // как часто ищем heartbeat-мониторы без сигнала
+	HeartbeatMinGap      = time.Second      // сигналы монитора чаще этого отбрасываются
+	MaxGracePeriod       = 7 * 24 * 3600    // максимальное допустимое опоздание сигнала, сек
+	MaxSteps             = 10               // шагов в multistep-проверке (столько же допускает ping_service)
+	MultistepPingTimeout = 28 * time.Second // ping_service сам обрывает цепочку через 25 с
+	JobHistorySize       = 50               // сколько последних прогонов /pingAll хранится в истории
+	SchedulerLease       = "scheduler"      // аренда в db_service: её держатель планирует проверки
+)
+
+// MaxStatsWindow - самое длинное окно для /checker/{id}/stats
+const MaxStatsWindow = 366 * 24 * time.Hour
+
+// Публичные страницы статуса
+const (
+	StatusPageDays     = 90               // дней в полосках аптайма
+	StatusPageCacheTTL = 30 * time.Second // сколько страница отдаётся из кэша, не нагружая ClickHouse
+)
+
+// Окна обслуживания
+const (
+	MaintenanceRefresh   = 30 * time.Second // как часто перечитываем расписание из db_service
+	MaintenanceLookahead = time.Hour        // на сколько вперёд и назад загружаем окна
+)
+
 var APILogger *log.Logger
 var Client *http.Client
 var KafkaWriter *kafka.Writer
 
+// Параметры пула проверок (переопределяются переменными окружения)
+var (
+	PingWorkers   = 20                     // PING_WORKERS - одновременных проверок
+	PingPerHost   = 2                      // PING_PER_HOST - одновременных запросов к одному хосту
+	PingHostDelay = 200 * time.Millisecond // PING_HOST_DELAY_MS - пауза между запросами к одному хосту
+	// CERT_WARNING_DAYS - за сколько дней до истечения сертификата предупреждать, через запятую
+	CertWarningDays = []int{30, 14, 7, 1}
+	// JOB_TIMEOUT_SEC - сколько может длиться один прогон /pingAll
+	JobTimeout = 10 * time.Minute
+	// INTERNAL_API_TOKEN - токен для /pingAll и /jobs; пока не задан, эндпоинты выключены
+	InternalAPIToken = ""
+	// LEASE_TTL_SEC - на сколько продлевается аренда лидера; за это время реплики
+	// замечают, что лидер пропал. Продлевается втрое чаще
+	LeaseTTL = 15 * time.Second
+	// INSTANCE_ID - имя реплики в аренде; по умолчанию hostname и случайный суффикс
+	InstanceID = ""
+	// PING_AGENTS - агенты ping_service по регионам: "eu=http://ping_eu:8082,us=http://ping_us:8082"
+	PingAgents = []PingAgent{{Region: "default", URL: PingURL}}
+	// PROBE_FANOUT - скольким агентам отправлять каждую проверку; 0 - всем
+	ProbeFanout = 0
+	// PROBE_QUORUM - сколько регионов должны увидеть неудачу, чтобы она засчиталась; 0 - большинство
+	ProbeQuorum = 0
+)
+
+// PingAgent - экземпляр ping_service в своей сети
+type PingAgent struct {
+	Region string
+	URL    string
+}
+
 func Configure() {
 	APILogger = log.New(os.Stdout, "API_SERVICE: ", log.LstdFlags)
 	Client = &http.Client{
 		Timeout: 30 * time.Second,
 	}
 
+	PingWorkers = envInt("PING_WORKERS", PingWorkers)
+	PingPerHost = envInt("PING_PER_HOST", PingPerHost)
+	PingHostDelay = time.Duration(envInt("PING_HOST_DELAY_MS", int(PingHostDelay.Milliseconds()))) * time.Millisecond
+	CertWarningDays = envIntList("CERT_WARNING_DAYS", CertWarningDays)
+	JobTimeout = time.Duration(envInt("JOB_TIMEOUT_SEC", int(JobTimeout.Seconds()))) * time.Second
+	InternalAPIToken = os.Getenv("INTERNAL_API_TOKEN")
+	LeaseTTL = time.Duration(envInt("LEASE_TTL_SEC", int(LeaseTTL.Seconds()))) * time.Second
+	InstanceID = os.Getenv("INSTANCE_ID")
+	if InstanceID == "" {
+		InstanceID = defaultInstanceID()
+	}
+	PingAgents = envAgents("PING_AGENTS", PingAgents)
+	ProbeFanout = envInt("PROBE_FANOUT", ProbeFanout)
+	ProbeQuorum = envInt("PROBE_QUORUM", ProbeQuorum)
+
 	// Инициализация Kafka writer
 	KafkaWriter = &kafka.Writer{
 		Addr:     kafka.TCP(KafkaAddr),
@@ -38,42 +118,51 @@ func Configure() {
 	}
 }
 
-func StartCronScheduler(apiBaseURL string) {
-	s := gocron.NewScheduler(time.UTC)
-
-	s.Every(1).Minute().Do(func() {
-		APILogger.Println("Starting cron job: pingAll")
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
-
-		req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiBaseURL+"/pingAll", nil)
-		if err != nil {
-			APILogger.Println("Error creating pingAll request:", err)
-			return
-		}
-
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			APILogger.Println("Error calling /pingAll:", err)
-			return
-		}
-		defer resp.Body.Close()
-
-		APILogger.Println("/pingAll called, response code:", resp.StatusCode)
-	})
+// defaultInstanceID - hostname контейнера и случайный суффикс, чтобы
+// перезапущенная реплика не выдавала себя за прежнего держателя аренды.
+func defaultInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "api"
+	}
+	b := make([]byte, 4)
+	rand.Read(b)
+	return host + "-" + hex.EncodeToString(b)
+}
 
-	s.StartAsync()
+func envInt(name string, def int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		APILogger.Printf("invalid %s=%q, using default %d", name, v, def)
+		return def
+	}
+	return n
 }
 
-func SendKafkaNotification(email, site string, responseTime int64) error {
-	message := map[string]interface{}{
-		"email":  email,
-		"site":   site,
-		"time":   responseTime,
-		"status": "down",
+func envIntList(name string, def []int) []int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
 	}
+	var list []int
+	for _, part := range strings.Split(v, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n <= 0 {
+			APILogger.Printf("invalid %s=%q, using default %v", name, v, def)
+			return def
+		}
+		list = append(list, n)
+	}
+	return list
+}
 
-	jsonData, err := json.Marshal(message)
+// SendKafkaNotification публикует событие о сайте в топик notification-alerts.
+func SendKafkaNotification(n models.Notification) error {
+	jsonData, err := json.Marshal(n)
 	if err != nil {
 		return err
 	}
@@ -84,3 +173,24 @@ func SendKafkaNotification(email, site string, responseTime int64) error {
 		},
 	)
 }
+
+// envAgents разбирает список "регион=URL" через запятую; регионы не должны повторяться.
+func envAgents(name string, def []PingAgent) []PingAgent {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	var agents []PingAgent
+	seen := map[string]bool{}
+	for _, part := range strings.Split(v, ",") {
+		region, url, ok := strings.Cut(strings.TrimSpace(part), "=")
+		region, url = strings.TrimSpace(region), strings.TrimRight(strings.TrimSpace(url), "/")
+		if !ok || region == "" || url == "" || seen[region] {
+			APILogger.Printf("invalid %s=%q, using default agents", name, v)
+			return def
+		}
+		seen[region] = true
+		agents = append(agents, PingAgent{Region: region, URL: url})
+	}
+	return agents
+}
@@ -2,15 +2,10 @@ module api_service
 
 go 1.24.6
 
-require (
-	github.com/go-co-op/gocron v1.37.0
-	github.com/segmentio/kafka-go v0.4.49
-)
+require github.com/segmentio/kafka-go v0.4.49
 
 require (
-	github.com/google/uuid v1.4.0 // indirect
 	github.com/klauspost/compress v1.15.9 // indirect
 	github.com/pierrec/lz4/v4 v4.1.15 // indirect
-	github.com/robfig/cron/v3 v3.0.1 // indirect
-	go.uber.org/atomic v1.9.0 // indirect
+	github.com/stretchr/testify v1.8.2 // indirect
 )
@@ -0,0 +1,318 @@
+package internal
+
+import (
+	"api_service/configs"
+	"api_service/models"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Состояния сайта для алертов
+const (
+	stateUp   = "up"
+	stateDown = "down"
+)
+
+// Типы событий, уходящих в Kafka
+const (
+	EventDown        = "down"
+	EventRecovery    = "recovery"
+	EventCertExpiry  = "cert_expiry"  // до истечения сертификата осталось не больше порога
+	EventCertInvalid = "cert_invalid" // цепочка или имя в сертификате не прошли проверку
+	EventDNSChanged  = "dns_changed"  // dns-проверка вернула не те записи, что в прошлый раз
+)
+
+// siteState - последнее известное состояние сайта и счётчики неудач (таблица site_states в db_service)
+type siteState struct {
+	SiteID              int        `json:"site_id"`
+	Status              string     `json:"status"`
+	ChangedAt           time.Time  `json:"changed_at"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	RecentResults       string     `json:"recent_results"` // "1" - неудача, "0" - успех; новые в конце
+	FailingSince        *time.Time `json:"failing_since,omitempty"`
+	CertNotAfter        *time.Time `json:"cert_not_after,omitempty"`
+	CertWarnedDays      int        `json:"cert_warned_days"` // наименьший порог, о котором уже предупредили
+	CertError           string     `json:"cert_error,omitempty"`
+	DNSRecords          string     `json:"dns_records,omitempty"`         // записи последней dns-проверки через \n
+	DNSSeen             bool       `json:"dns_seen"`                      // dns-проверка уже была (DNSRecords может быть пустым)
+	HeartbeatMissedAt   *time.Time `json:"heartbeat_missed_at,omitempty"` // когда последний раз засчитали пропуск сигнала
+}
+
+// Transition - смена состояния сайта, о которой нужно уведомить
+type Transition struct {
+	Event        string
+	At           time.Time
+	DownSince    time.Time // для EventRecovery - начало сбоя
+	FirstFailure time.Time // для EventDown - первая неудачная проверка серии
+	IncidentID   int       // инцидент, открытый или закрытый переходом; 0 - не удалось записать
+}
+
+// StateTracker превращает поток результатов проверок в события up -> down -> up.
+// Сайт объявляется упавшим, только когда сработал порог (N неудач подряд или
+// M из последних K), и восстановленным, когда порог перестал выполняться.
+// Состояние и счётчики кэшируются в памяти и сохраняются в db_service после
+// каждого изменения, поэтому рестарт api_service их не сбрасывает.
+// Кэшу доверяем, только пока реплика - лидер: сайты проверяет лидер, и
+// состояния меняет только он.
+type StateTracker struct {
+	mu       sync.Mutex
+	sites    map[int]*trackedSite
+	gen      int         // поколение кэша; Reset увеличивает его
+	isLeader func() bool // nil - реплика одна, кэш всегда актуален
+}
+
+type trackedSite struct {
+	mu    sync.Mutex // проверки одного сайта обрабатываются по очереди
+	state *siteState // nil - ещё не загружено из db_service
+	gen   int        // поколение кэша, в котором загружено state
+}
+
+func NewStateTracker(isLeader func() bool) *StateTracker {
+	return &StateTracker{sites: map[int]*trackedSite{}, isLeader: isLeader}
+}
+
+// Observe учитывает результат проверки и возвращает переход, если он случился.
+func (t *StateTracker) Observe(site models.Site, checkStatus string, at time.Time) (*Transition, error) {
+	ts := t.lockSite(site.ID, site.Type == models.TypeHeartbeat)
+	defer ts.mu.Unlock()
+
+	if err := ts.load(site.ID); err != nil {
+		return nil, err
+	}
+
+	next := *ts.state
+	failed := checkStatus == "bad"
+	recordResult(&next, site, failed, at.UTC())
+
+	var tr *Transition
+	switch {
+	case next.Status != stateDown && thresholdReached(next, site):
+		next.Status = stateDown
+		next.ChangedAt = at.UTC()
+		tr = &Transition{Event: EventDown, At: at}
+		if next.FailingSince != nil {
+			tr.FirstFailure = *next.FailingSince
+		}
+	case next.Status == stateDown && !failed && !thresholdReached(next, site):
+		tr = &Transition{Event: EventRecovery, At: at, DownSince: next.ChangedAt}
+		next.Status = stateUp
+		next.ChangedAt = at.UTC()
+	}
+
+	if err := ts.save(next); err != nil {
+		return nil, err
+	}
+	return tr, nil
+}
+
+// recordResult обновляет счётчики неудач результатом очередной проверки.
+func recordResult(st *siteState, site models.Site, failed bool, at time.Time) {
+	if failed {
+		if st.ConsecutiveFailures == 0 {
+			st.FailingSince = &at
+		}
+		st.ConsecutiveFailures++
+	} else {
+		st.ConsecutiveFailures = 0
+		st.FailingSince = nil
+	}
+
+	k := windowSize(site)
+	if k == 0 {
+		st.RecentResults = ""
+		return
+	}
+	mark := "0"
+	if failed {
+		mark = "1"
+	}
+	st.RecentResults += mark
+	if len(st.RecentResults) > k {
+		st.RecentResults = st.RecentResults[len(st.RecentResults)-k:]
+	}
+}
+
+// thresholdReached - выполняется ли хотя бы одно из условий "сайт упал".
+func thresholdReached(st siteState, site models.Site) bool {
+	n := site.FailureThreshold
+	if n <= 0 {
+		n = 1
+	}
+	if st.ConsecutiveFailures >= n {
+		return true
+	}
+
+	k := windowSize(site)
+	m := site.WindowFailures
+	if k == 0 || m <= 0 {
+		return false
+	}
+	if m > k {
+		m = k
+	}
+	return strings.Count(st.RecentResults, "1") >= m
+}
+
+func windowSize(site models.Site) int {
+	k := site.WindowSize
+	if k < 0 {
+		k = 0
+	}
+	if k > configs.MaxWindowSize {
+		k = configs.MaxWindowSize
+	}
+	return k
+}
+
+func sameState(a, b siteState) bool {
+	return a.Status == b.Status && a.ChangedAt.Equal(b.ChangedAt) &&
+		a.ConsecutiveFailures == b.ConsecutiveFailures && a.RecentResults == b.RecentResults &&
+		sameTime(a.FailingSince, b.FailingSince) &&
+		sameTime(a.CertNotAfter, b.CertNotAfter) && a.CertWarnedDays == b.CertWarnedDays && a.CertError == b.CertError &&
+		a.DNSRecords == b.DNSRecords && a.DNSSeen == b.DNSSeen && sameTime(a.HeartbeatMissedAt, b.HeartbeatMissedAt)
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// load подтягивает состояние из db_service при первом обращении; вызывается под ts.mu.
+func (ts *trackedSite) load(siteID int) error {
+	if ts.state != nil {
+		return nil
+	}
+	st, err := fetchSiteState(siteID)
+	if err != nil {
+		return err
+	}
+	ts.state = st
+	return nil
+}
+
+// save сохраняет изменившееся состояние в db_service и кэш; вызывается под ts.mu.
+func (ts *trackedSite) save(next siteState) error {
+	if sameState(next, *ts.state) {
+		return nil
+	}
+	if err := saveSiteState(next); err != nil {
+		return err
+	}
+	ts.state = &next
+	return nil
+}
+
+// lockSite блокирует сайт и сбрасывает его кэш, если тот мог устареть:
+// на ведомой реплике и для shared-сайтов, чьё состояние меняют все реплики
+// (сигналы heartbeat-мониторов принимает любая из них).
+func (t *StateTracker) lockSite(siteID int, shared bool) *trackedSite {
+	ts := t.site(siteID)
+	ts.mu.Lock()
+
+	t.mu.Lock()
+	gen := t.gen
+	t.mu.Unlock()
+
+	if shared || ts.gen != gen || (t.isLeader != nil && !t.isLeader()) {
+		ts.state = nil
+	}
+	ts.gen = gen
+	return ts
+}
+
+// Reset объявляет весь кэш устаревшим, например когда реплика стала лидером.
+// Состояния перечитываются при следующем обращении к сайту.
+func (t *StateTracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.gen++
+}
+
+func (t *StateTracker) site(siteID int) *trackedSite {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ts, ok := t.sites[siteID]
+	if !ok {
+		ts = &trackedSite{}
+		t.sites[siteID] = ts
+	}
+	return ts
+}
+
+func fetchSiteState(siteID int) (*siteState, error) {
+	resp, err := configs.Client.Get(fmt.Sprintf("%s/site-state/%d", configs.DBURL, siteID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get site state: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// Сайт ещё ни разу не менял состояние - считаем, что он доступен
+	if resp.StatusCode == http.StatusNotFound {
+		return &siteState{SiteID: siteID, Status: stateUp}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("db service returned status: %d", resp.StatusCode)
+	}
+
+	var st siteState
+	if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+		return nil, fmt.Errorf("failed to parse site state: %v", err)
+	}
+	return &st, nil
+}
+
+func saveSiteState(st siteState) error {
+	jsonData, _ := json.Marshal(st)
+	req, err := http.NewRequest(http.MethodPut,
+		fmt.Sprintf("%s/site-state/%d", configs.DBURL, st.SiteID), bytes.NewReader(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := configs.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to save site state: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("db service returned status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// notifyTransition отправляет владельцу сайта уведомление о смене состояния.
+func (h *Handler) notifyTransition(userID int, site models.Site, result *PingResult, tr *Transition) {
+	userEmail, err := h.getUserEmail(userID)
+	if err != nil {
+		configs.APILogger.Printf("get user email failed: %v", err)
+		return
+	}
+
+	n := models.Notification{
+		Email:        userEmail,
+		Site:         site.URL,
+		Event:        tr.Event,
+		Time:         tr.At.UTC().Format(time.RFC3339),
+		ResponseTime: result.ResponseTime,
+		IncidentID:   tr.IncidentID,
+	}
+	if tr.Event == EventDown {
+		n.Error = result.Error
+	}
+	if tr.Event == EventRecovery && !tr.DownSince.IsZero() {
+		n.DownSince = tr.DownSince.UTC().Format(time.RFC3339)
+		n.Duration = int64(tr.At.Sub(tr.DownSince).Seconds())
+	}
+
+	h.sendNotification(userID, site.ID, n)
+}
@@ -0,0 +1,166 @@
+package internal
+
+import (
+	"api_service/configs"
+	"api_service/models"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// CertEvent - предупреждение о сертификате сайта
+type CertEvent struct {
+	Event     string // EventCertExpiry | EventCertInvalid
+	Threshold int    // для EventCertExpiry - сработавший порог, дней
+}
+
+// certExpired - "порог" истёкшего сертификата в CertWarnedDays: меньше любого
+// из configs.CertWarningDays, поэтому об истечении сообщаем после всех предупреждений.
+const certExpired = -1
+
+// ObserveCert учитывает сертификат из очередной проверки и возвращает
+// предупреждение, если о нём ещё не сообщали, и признак того, что сертификат
+// (срок или ошибка) изменился с прошлой проверки. О сроке предупреждаем один
+// раз на каждый порог из configs.CertWarningDays и ещё раз, когда сертификат
+// истёк; новый сертификат (другой NotAfter) сбрасывает отправленные
+// предупреждения. Об ошибке цепочки сообщаем, когда она появилась, повторно -
+// только после исправления.
+func (t *StateTracker) ObserveCert(site models.Site, cert *models.CertInfo) (*CertEvent, bool, error) {
+	notAfter, err := time.Parse(time.RFC3339, cert.NotAfter)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid cert not_after %q: %v", cert.NotAfter, err)
+	}
+
+	ts := t.lockSite(site.ID, false)
+	defer ts.mu.Unlock()
+
+	if err := ts.load(site.ID); err != nil {
+		return nil, false, err
+	}
+
+	next := *ts.state
+	changed := next.CertNotAfter == nil || !next.CertNotAfter.Equal(notAfter) || next.CertError != cert.Error
+	if next.CertNotAfter == nil || !next.CertNotAfter.Equal(notAfter) {
+		next.CertNotAfter = &notAfter
+		next.CertWarnedDays = 0
+	}
+
+	var ev *CertEvent
+	switch {
+	case !time.Now().Before(notAfter):
+		// Истёкший сертификат не проходит и проверку цепочки, но владельцу
+		// важнее срок, чем текст ошибки x509
+		if next.CertWarnedDays != certExpired {
+			next.CertWarnedDays = certExpired
+			ev = &CertEvent{Event: EventCertExpiry, Threshold: certExpired}
+		}
+		next.CertError = cert.Error
+	case cert.Error != "":
+		if next.CertError == "" {
+			ev = &CertEvent{Event: EventCertInvalid}
+		}
+		next.CertError = cert.Error
+	default:
+		next.CertError = ""
+		if th := certThreshold(cert.DaysLeft); th > 0 && (next.CertWarnedDays == 0 || th < next.CertWarnedDays) {
+			next.CertWarnedDays = th
+			ev = &CertEvent{Event: EventCertExpiry, Threshold: th}
+		}
+	}
+
+	if err := ts.save(next); err != nil {
+		return nil, false, err
+	}
+	return ev, changed, nil
+}
+
+// certThreshold возвращает наименьший порог, под который попадает срок сертификата; 0 - ни один.
+func certThreshold(daysLeft int) int {
+	res := 0
+	for _, th := range configs.CertWarningDays {
+		if daysLeft <= th && (res == 0 || th < res) {
+			res = th
+		}
+	}
+	return res
+}
+
+// checkCert сохраняет сертификат в историю, если он изменился, и при
+// необходимости предупреждает владельца.
+func (h *Handler) checkCert(userID int, site models.Site, cert *models.CertInfo) {
+	ev, changed, err := h.states.ObserveCert(site, cert)
+	if err != nil {
+		configs.APILogger.Printf("update cert state of site %d failed: %v", site.ID, err)
+		return
+	}
+	// В истории только смены сертификата и его ошибки, а не каждая проверка
+	if changed {
+		if err := saveCertLog(userID, site.URL, cert); err != nil {
+			configs.APILogger.Printf("save cert log for %s failed: %v", site.URL, err)
+		}
+	}
+	if ev == nil {
+		return
+	}
+
+	userEmail, err := h.getUserEmail(userID)
+	if err != nil {
+		configs.APILogger.Printf("get user email failed: %v", err)
+		return
+	}
+
+	daysLeft := cert.DaysLeft
+	if ev.Threshold == certExpired && daysLeft >= 0 {
+		// Истёк меньше суток назад
+		daysLeft = -1
+	}
+	n := models.Notification{
+		Email:        userEmail,
+		Site:         site.URL,
+		Event:        ev.Event,
+		Time:         time.Now().UTC().Format(time.RFC3339),
+		CertIssuer:   cert.Issuer,
+		CertNotAfter: cert.NotAfter,
+		DaysLeft:     &daysLeft,
+		CertError:    cert.Error,
+	}
+	h.sendNotification(userID, site.ID, n)
+}
+
+func saveCertLog(userID int, site string, cert *models.CertInfo) error {
+	jsonData, _ := json.Marshal(map[string]any{
+		"user_id":   userID,
+		"site":      site,
+		"issuer":    cert.Issuer,
+		"subject":   cert.Subject,
+		"dns_names": cert.DNSNames,
+		"not_after": cert.NotAfter,
+		"days_left": cert.DaysLeft,
+		"error":     cert.Error,
+	})
+
+	resp, err := configs.Client.Post(configs.DBURL+"/cert-logs", "application/json", bytes.NewReader(jsonData))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("db service returned status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// getCertLogs проксирует историю сертификата сайта из db_service.
+func (h *Handler) getCertLogs(resp http.ResponseWriter, userID, siteID int) {
+	dbReq, err := http.NewRequest(http.MethodGet,
+		fmt.Sprintf("%s/cert-logs/%d?user_id=%d", configs.DBURL, siteID, userID), nil)
+	if err != nil {
+		http.Error(resp, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	h.proxyToDB(resp, dbReq)
+}
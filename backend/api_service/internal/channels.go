@@ -0,0 +1,269 @@
+package internal
+
+import (
+	"api_service/configs"
+	"api_service/models"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const (
+	maxWebhookHeaders      = 20
+	maxWebhookTemplateSize = 10 << 10
+	maxWebhookSecret       = 256
+)
+
+var (
+	headerNameRe    = regexp.MustCompile(`^[A-Za-z0-9!#$%&'*+.^_|~-]+$`)
+	telegramTokenRe = regexp.MustCompile(`^\d+:[A-Za-z0-9_-]+$`)
+	// числовой ID чата или группы либо @username публичного канала
+	telegramChatRe = regexp.MustCompile(`^(-?\d+|@[A-Za-z0-9_]{5,32})$`)
+)
+
+// webhookFuncs - функции шаблона тела; должны совпадать с notification_service
+var webhookFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// channelRequest - тело POST/PUT /notification-channels
+type channelRequest struct {
+	Type    string          `json:"type"`
+	Name    string          `json:"name,omitempty"`
+	Config  json.RawMessage `json:"config"`
+	Enabled *bool           `json:"enabled,omitempty"`
+	SiteIDs []int           `json:"site_ids,omitempty"`
+}
+
+// ChannelsHandler - каналы уведомлений пользователя помимо email:
+//
+//	GET  /notification-channels
+//	POST /notification-channels      {"type": "webhook|slack|telegram", "name", "config": {...}, "enabled", "site_ids"}
+//	GET/PUT/DELETE /notification-channels/{id}
+//
+// Пустой site_ids - канал для всех мониторов пользователя. Настройки канала
+// проверяются здесь, доставкой занимается notification_service.
+func (h *Handler) ChannelsHandler(resp http.ResponseWriter, req *http.Request) {
+	userID, err := h.verifyJWT(req)
+	if err != nil {
+		http.Error(resp, "unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	pathParts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	target := configs.DBURL + "/notification-channels"
+	switch len(pathParts) {
+	case 1:
+		if req.Method != http.MethodGet && req.Method != http.MethodPost {
+			http.Error(resp, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+	case 2:
+		channelID, err := strconv.Atoi(pathParts[1])
+		if err != nil {
+			http.Error(resp, "invalid channel ID", http.StatusBadRequest)
+			return
+		}
+		switch req.Method {
+		case http.MethodGet, http.MethodPut, http.MethodDelete:
+		default:
+			http.Error(resp, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		target += "/" + strconv.Itoa(channelID)
+	default:
+		http.Error(resp, "invalid URL", http.StatusBadRequest)
+		return
+	}
+
+	var body []byte
+	if req.Method == http.MethodPost || req.Method == http.MethodPut {
+		var ch channelRequest
+		if err := json.NewDecoder(req.Body).Decode(&ch); err != nil {
+			http.Error(resp, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := validateChannel(&ch); err != nil {
+			http.Error(resp, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body, _ = json.Marshal(ch)
+	}
+
+	dbReq, err := http.NewRequest(req.Method, target+"?user_id="+strconv.Itoa(userID), bytes.NewReader(body))
+	if err != nil {
+		http.Error(resp, "internal error", http.StatusInternalServerError)
+		return
+	}
+	dbReq.Header.Set("Content-Type", "application/json")
+
+	h.proxyToDB(resp, dbReq)
+}
+
+// validateChannel проверяет настройки канала и приводит их к каноническому виду.
+func validateChannel(ch *channelRequest) error {
+	switch ch.Type {
+	case models.ChannelWebhook:
+		var cfg models.WebhookConfig
+		if err := decodeStrict(ch.Config, &cfg); err != nil {
+			return fmt.Errorf("invalid webhook config: %v", err)
+		}
+		if err := validateWebhook(&cfg); err != nil {
+			return err
+		}
+		ch.Config, _ = json.Marshal(cfg)
+	case models.ChannelSlack:
+		var cfg models.SlackConfig
+		if err := decodeStrict(ch.Config, &cfg); err != nil {
+			return fmt.Errorf("invalid slack config: %v", err)
+		}
+		u, err := url.Parse(cfg.WebhookURL)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return errors.New("webhook_url must be an absolute https URL")
+		}
+		ch.Config, _ = json.Marshal(cfg)
+	case models.ChannelTelegram:
+		var cfg models.TelegramConfig
+		if err := decodeStrict(ch.Config, &cfg); err != nil {
+			return fmt.Errorf("invalid telegram config: %v", err)
+		}
+		if !telegramTokenRe.MatchString(cfg.BotToken) {
+			return errors.New("bot_token must look like 123456:ABC-DEF")
+		}
+		if !telegramChatRe.MatchString(cfg.ChatID) {
+			return errors.New("chat_id must be a numeric chat ID or @channel_username")
+		}
+		ch.Config, _ = json.Marshal(cfg)
+	default:
+		return fmt.Errorf("unknown channel type %q", ch.Type)
+	}
+	return nil
+}
+
+func validateWebhook(cfg *models.WebhookConfig) error {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+
+	cfg.Method = strings.ToUpper(cfg.Method)
+	switch cfg.Method {
+	case "":
+		cfg.Method = http.MethodPost
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return errors.New("method must be POST, PUT or PATCH")
+	}
+
+	if len(cfg.Headers) > maxWebhookHeaders {
+		return fmt.Errorf("at most %d headers", maxWebhookHeaders)
+	}
+	for name, value := range cfg.Headers {
+		if !headerNameRe.MatchString(name) {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("invalid value of header %s", name)
+		}
+		// Эти заголовки выставляет notification_service
+		if strings.HasPrefix(strings.ToLower(name), "x-pingtower-") {
+			return fmt.Errorf("header %s is reserved", name)
+		}
+	}
+
+	if len(cfg.Secret) > maxWebhookSecret {
+		return fmt.Errorf("secret must be at most %d characters", maxWebhookSecret)
+	}
+
+	if cfg.BodyTemplate != "" {
+		if len(cfg.BodyTemplate) > maxWebhookTemplateSize {
+			return fmt.Errorf("body_template must be at most %d bytes", maxWebhookTemplateSize)
+		}
+		tmpl, err := template.New("webhook").Funcs(webhookFuncs).Option("missingkey=error").Parse(cfg.BodyTemplate)
+		if err != nil {
+			return fmt.Errorf("invalid body_template: %v", err)
+		}
+		// Пробный прогон на правдоподобном уведомлении ловит опечатки в полях и невалидный JSON
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, sampleNotification()); err != nil {
+			return fmt.Errorf("invalid body_template: %v", err)
+		}
+		if !json.Valid(buf.Bytes()) {
+			return errors.New("body_template must render valid JSON")
+		}
+	}
+	return nil
+}
+
+func sampleNotification() models.Notification {
+	daysLeft := 7
+	return models.Notification{
+		Email:        "user@example.com",
+		Site:         "https://example.com",
+		Event:        EventDown,
+		Time:         "2025-01-01T00:00:00Z",
+		ResponseTime: 1500,
+		Error:        "connection timeout",
+		DownSince:    "2025-01-01T00:00:00Z",
+		Duration:     60,
+		IncidentID:   1,
+		CertIssuer:   "Example CA",
+		CertNotAfter: "2025-01-08T00:00:00Z",
+		DaysLeft:     &daysLeft,
+		CertError:    "certificate has expired",
+		RecordType:   "A",
+		OldRecords:   []string{"192.0.2.1"},
+		NewRecords:   []string{"192.0.2.2"},
+	}
+}
+
+// decodeStrict разбирает JSON, не допуская неизвестных полей: опечатка в
+// настройке канала иначе молча выключила бы её.
+func decodeStrict(raw json.RawMessage, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// sendNotification добавляет к уведомлению каналы, выбранные владельцем для
+// сайта, и публикует его в Kafka. Если каналы получить не удалось, письмо
+// всё равно уходит.
+func (h *Handler) sendNotification(userID, siteID int, n models.Notification) {
+	channels, err := siteChannels(userID, siteID)
+	if err != nil {
+		configs.APILogger.Printf("get notification channels of site %d failed: %v", siteID, err)
+	}
+	n.Channels = channels
+
+	if err := configs.SendKafkaNotification(n); err != nil {
+		configs.APILogger.Printf("send %s notification for %s failed: %v", n.Event, n.Site, err)
+	}
+}
+
+func siteChannels(userID, siteID int) ([]models.NotificationChannel, error) {
+	resp, err := configs.Client.Get(fmt.Sprintf("%s/site-channels/%d?user_id=%d", configs.DBURL, siteID, userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channels: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("db service returned status: %d", resp.StatusCode)
+	}
+
+	var channels []models.NotificationChannel
+	if err := json.NewDecoder(resp.Body).Decode(&channels); err != nil {
+		return nil, fmt.Errorf("failed to parse channels: %v", err)
+	}
+	return channels, nil
+}
@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"api_service/configs"
+	"api_service/models"
+	"strings"
+	"time"
+)
+
+// ObserveDNS запоминает записи из очередной dns-проверки и возвращает прежний
+// набор, если он был и отличается от нового. Первый результат только
+// запоминается, чтобы добавление сайта не вызывало алерт.
+func (t *StateTracker) ObserveDNS(siteID int, records []string) ([]string, bool, error) {
+	ts := t.lockSite(siteID, false)
+	defer ts.mu.Unlock()
+
+	if err := ts.load(siteID); err != nil {
+		return nil, false, err
+	}
+
+	joined := strings.Join(records, "\n")
+	prev, seen := ts.state.DNSRecords, ts.state.DNSSeen
+	if seen && prev == joined {
+		return nil, false, nil
+	}
+
+	next := *ts.state
+	next.DNSRecords = joined
+	next.DNSSeen = true
+	if err := ts.save(next); err != nil {
+		return nil, false, err
+	}
+	if !seen {
+		return nil, false, nil
+	}
+	// Пустой прежний набор - резолвер ничего не вернул, это тоже изменение
+	var old []string
+	if prev != "" {
+		old = strings.Split(prev, "\n")
+	}
+	return old, true, nil
+}
+
+// checkDNS сообщает владельцу, что резолвер стал возвращать другие записи.
+func (h *Handler) checkDNS(userID int, site models.Site, res *models.DNSResult) {
+	old, changed, err := h.states.ObserveDNS(site.ID, res.Records)
+	if err != nil {
+		configs.APILogger.Printf("update dns state of site %d failed: %v", site.ID, err)
+		return
+	}
+	if !changed {
+		return
+	}
+
+	userEmail, err := h.getUserEmail(userID)
+	if err != nil {
+		configs.APILogger.Printf("get user email failed: %v", err)
+		return
+	}
+
+	n := models.Notification{
+		Email:      userEmail,
+		Site:       site.URL,
+		Event:      EventDNSChanged,
+		Time:       time.Now().UTC().Format(time.RFC3339),
+		RecordType: res.RecordType,
+		OldRecords: old,
+		NewRecords: res.Records,
+	}
+	h.sendNotification(userID, site.ID, n)
+}
@@ -6,8 +6,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/mail"
 	"net/url"
@@ -17,10 +19,34 @@ import (
 	"time"
 )
 
-type Handler struct{}
+type Handler struct {
+	pool   *Pool
+	states *StateTracker
+	jobs   *JobRunner
+	leader *LeaderElector
+	status *statusCache
+	// Окна обслуживания: в них проверки не вызывают алертов
+	maintenance *MaintenanceCalendar
+	beats       *heartbeatLimiter
+}
 
 func NewHandler() *Handler {
-	return &Handler{}
+	h := &Handler{}
+	h.leader = NewLeaderElector(h)
+	h.states = NewStateTracker(h.leader.IsLeader)
+	h.pool = NewPool(configs.PingWorkers, configs.PingPerHost, configs.PingHostDelay, func(job CheckJob) (*PingResult, error) {
+		return h.checkSite(job.UserID, job.Site)
+	})
+	h.jobs = NewJobRunner(h)
+	h.status = newStatusCache()
+	h.maintenance = NewMaintenanceCalendar()
+	h.beats = newHeartbeatLimiter(configs.HeartbeatMinGap)
+	return h
+}
+
+// Leader возвращает выбор лидера; запускать его должен main вместе с планировщиком.
+func (h *Handler) Leader() *LeaderElector {
+	return h.leader
 }
 
 func (h *Handler) AuthHandler(resp http.ResponseWriter, req *http.Request) {
@@ -52,6 +78,11 @@ func (h *Handler) AuthHandler(resp http.ResponseWriter, req *http.Request) {
 		http.Error(resp, "password must be at least 6 characters", http.StatusBadRequest)
 		return
 	}
+	// bcrypt учитывает только первые 72 байта пароля
+	if len(authReq.Password) > 72 {
+		http.Error(resp, "password must be at most 72 bytes", http.StatusBadRequest)
+		return
+	}
 
 	// Проверка существования пользователя
 	userExists, err := h.checkUserExists(authReq.Email)
@@ -92,11 +123,15 @@ func (h *Handler) AuthHandler(resp http.ResponseWriter, req *http.Request) {
 			return
 		}
 
-		// Проверка логина (пока заглушка)
+		// Проверка пароля в db_service
 		userID, err := h.verifyLogin(authReq.Email, authReq.Password)
+		if errors.Is(err, errInvalidCredentials) {
+			http.Error(resp, "invalid email or password", http.StatusUnauthorized)
+			return
+		}
 		if err != nil {
 			configs.APILogger.Println("login verification failed:", err)
-			http.Error(resp, "login failed", http.StatusUnauthorized)
+			http.Error(resp, "login failed", http.StatusInternalServerError)
 			return
 		}
 
@@ -121,29 +156,50 @@ func (h *Handler) CheckerHandler(resp http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	// Извлекаем ID сайта из URL
-	pathParts := strings.Split(req.URL.Path, "/")
-	if len(pathParts) < 3 {
+	// Извлекаем ID сайта из URL: /checker/{id} или /checker/{id}/{ресурс}
+	pathParts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	if len(pathParts) < 2 || len(pathParts) > 3 {
 		http.Error(resp, "invalid URL", http.StatusBadRequest)
 		return
 	}
 
-	siteID, err := strconv.Atoi(pathParts[len(pathParts)-1])
+	siteID, err := strconv.Atoi(pathParts[1])
 	if err != nil {
 		http.Error(resp, "invalid site ID", http.StatusBadRequest)
 		return
 	}
 
-	// Получаем логи сайта
-	logs, err := h.getSiteLogs(userID, siteID)
-	if err != nil {
-		configs.APILogger.Println("get site logs failed:", err)
-		http.Error(resp, "internal error", http.StatusInternalServerError)
+	if len(pathParts) == 3 {
+		if req.Method != http.MethodGet {
+			http.Error(resp, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		switch pathParts[2] {
+		case "cert":
+			h.getCertLogs(resp, userID, siteID)
+		case "stats":
+			h.getSiteStats(resp, req, userID, siteID)
+		default:
+			http.Error(resp, "not found", http.StatusNotFound)
+		}
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPatch:
+		h.updateUserSite(resp, req, userID, siteID)
+		return
+	case http.MethodDelete:
+		h.deleteUserSite(resp, req, userID, siteID)
+		return
+	default:
+		http.Error(resp, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	resp.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(resp).Encode(logs)
+	// Получаем логи сайта
+	h.getSiteLogs(resp, req, userID, siteID)
 }
 
 func (h *Handler) CheckersHandler(resp http.ResponseWriter, req *http.Request) {
@@ -164,63 +220,54 @@ func (h *Handler) CheckersHandler(resp http.ResponseWriter, req *http.Request) {
 	}
 }
 
-func (h *Handler) PingAllHandler(resp http.ResponseWriter, req *http.Request) {
-	if req.Method != http.MethodPost {
-		http.Error(resp, "method not allowed", http.StatusMethodNotAllowed)
-		return
+// checkSite выполняет одну проверку сайта: пинг из нескольких регионов,
+// сохранение логов и, при смене состояния сайта, уведомление владельца. Используется и /pingAll, и планировщиком.
+// В окне обслуживания проверка только пишется в логи: состояние, инциденты и
+// уведомления не трогаются.
+func (h *Handler) checkSite(userID int, site models.Site) (*PingResult, error) {
+	inMaintenance := h.maintenance.Active(userID, site.ID, time.Now())
+	regions, pingResult, probeErr := h.probeSite(site)
+
+	// Лог пишется по каждому ответившему региону, даже если кворума не набралось.
+	// Без лога проверка всё равно доходит до состояния и алертов: сбой ClickHouse
+	// не должен отключать уведомления о падениях
+	for _, r := range regions {
+		if r.err != nil {
+			continue
+		}
+		r.result.Maintenance = inMaintenance
+		if err := h.savePingLog(userID, site.URL, r.result); err != nil {
+			configs.APILogger.Printf("save ping log for %s failed: %v", site.URL, err)
+		}
 	}
-
-	// Получаем всех пользователей и их сайты
-	usersSites, err := h.getAllUsersSites()
-	if err != nil {
-		configs.APILogger.Println("get all users sites failed:", err)
-		http.Error(resp, "internal error", http.StatusInternalServerError)
-		return
+	if probeErr != nil {
+		configs.APILogger.Printf("ping site %s failed: %v", site.URL, probeErr)
+		return nil, probeErr
+	}
+	if inMaintenance {
+		pingResult.Maintenance = true
+		return pingResult, nil
 	}
 
-	successCount := 0
-	failCount := 0
-
-	// Пингуем все сайты
-	for _, userSite := range usersSites {
-		for _, site := range userSite.Sites {
-			pingResult, err := h.pingSite(site.URL)
-			if err != nil {
-				configs.APILogger.Printf("ping site %s failed: %v", site.URL, err)
-				failCount++
-				continue
-			}
-			if err := h.savePingLog(userSite.UserID, site.URL, pingResult.ResponseTime, pingResult.Status); err != nil {
-				configs.APILogger.Printf("save ping log failed: %v", err)
-				failCount++
-				continue
-			}
-
-			if pingResult.Status == "bad" {
-				failCount++
-			} else {
-				successCount++
-			}
-			if pingResult.Status == "bad" {
-				userEmail, err := h.getUserEmail(userSite.UserID)
-				if err == nil {
-					_ = configs.SendKafkaNotification(userEmail, site.URL, pingResult.ResponseTime)
-				} else {
-					configs.APILogger.Printf("get user email failed: %v", err)
-				}
-			}
+	// Уведомляем только о смене состояния: up -> down и down -> up
+	tr, err := h.states.Observe(site, pingResult.Status, time.Now())
+	if err != nil {
+		configs.APILogger.Printf("update state of site %d failed: %v", site.ID, err)
+	} else {
+		h.trackIncident(site, pingResult, tr)
+		if tr != nil {
+			h.notifyTransition(userID, site, pingResult, tr)
 		}
 	}
 
-	response := map[string]interface{}{
-		"message":    "Ping completed",
-		"successful": successCount,
-		"failed":     failCount,
-		"total":      successCount + failCount,
+	if pingResult.Cert != nil {
+		h.checkCert(userID, site, pingResult.Cert)
+	}
+	if pingResult.DNS != nil && pingResult.DNS.Records != nil {
+		h.checkDNS(userID, site, pingResult.DNS)
 	}
 
-	resp.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(resp).Encode(response)
+	return pingResult, nil
 }
 
 // Вспомогательные методы
@@ -267,9 +314,46 @@ func (h *Handler) registerUser(email, password string) (int, error) {
 	return int(result["id"].(float64)), nil
 }
 
+// errInvalidCredentials - неверная пара email/пароль (в отличие от сбоя db_service)
+var errInvalidCredentials = errors.New("invalid email or password")
+
 func (h *Handler) verifyLogin(email, password string) (int, error) {
-	// Реализация проверки логина через auth service
-	return 1, nil // Заглушка
+	creds := map[string]string{
+		"email":    email,
+		"password": password,
+	}
+
+	jsonData, _ := json.Marshal(creds)
+	req, err := http.NewRequest(http.MethodPost, configs.DBURL+"/user/verify", bytes.NewReader(jsonData))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := configs.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return 0, errInvalidCredentials
+	}
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return 0, fmt.Errorf("db service error: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+
+	var result struct {
+		ID int `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+	if result.ID == 0 {
+		return 0, errInvalidCredentials
+	}
+	return result.ID, nil
 }
 
 func (h *Handler) getJWTToken(email string, userID int, password string) (*models.AuthResp, error) {
@@ -367,22 +451,27 @@ func (h *Handler) verifyJWT(req *http.Request) (int, error) {
 	}
 	return got.ID, nil
 }
-func (h *Handler) getSiteLogs(userID, siteID int) ([]models.PingLog, error) {
-	req, err := http.NewRequest(http.MethodGet,
-		fmt.Sprintf("%s/checker/%d?user_id=%d", configs.DBURL, siteID, userID), nil)
-	if err != nil {
-		return nil, err
+
+// getSiteLogs отдаёт страницу логов сайта (from, to, region, limit, cursor;
+// курсор следующей страницы - в заголовке X-Next-Cursor) или агрегаты по
+// интервалам (bucket=1m|5m|1h). Параметры проверяет db_service.
+func (h *Handler) getSiteLogs(resp http.ResponseWriter, req *http.Request, userID, siteID int) {
+	params := url.Values{}
+	params.Set("user_id", strconv.Itoa(userID))
+	for _, name := range []string{"from", "to", "region", "limit", "cursor", "bucket"} {
+		if v := req.URL.Query().Get(name); v != "" {
+			params.Set(name, v)
+		}
 	}
 
-	resp, err := configs.Client.Do(req)
+	dbReq, err := http.NewRequest(http.MethodGet,
+		fmt.Sprintf("%s/checker/%d?%s", configs.DBURL, siteID, params.Encode()), nil)
 	if err != nil {
-		return nil, err
+		http.Error(resp, "internal error", http.StatusInternalServerError)
+		return
 	}
-	defer resp.Body.Close()
 
-	var logs []models.PingLog
-	err = json.NewDecoder(resp.Body).Decode(&logs)
-	return logs, err
+	h.proxyToDB(resp, dbReq)
 }
 
 func (h *Handler) getUserSites(resp http.ResponseWriter, userID int) {
@@ -415,6 +504,37 @@ func (h *Handler) addUserSite(resp http.ResponseWriter, req *http.Request, userI
 		return
 	}
 
+	if siteReq.Time != 0 && siteReq.Time < configs.MinCheckInterval {
+		http.Error(resp, fmt.Sprintf("check interval must be at least %d seconds", configs.MinCheckInterval), http.StatusBadRequest)
+		return
+	}
+	if err := validateThresholds(siteReq.FailureThreshold, siteReq.WindowSize, siteReq.WindowFailures); err != nil {
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateAssertions(siteReq.Assertions); err != nil {
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+	siteReq.Method = strings.ToUpper(siteReq.Method)
+	if err := validateRequestSpec(siteReq.Method, siteReq.Headers, siteReq.ExpectedStatus); err != nil {
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateMonitor(monitorSpec{
+		Type: siteReq.Type, Site: siteReq.Site, TimeoutMs: siteReq.TimeoutMs,
+		Count: siteReq.Count, IntervalMs: siteReq.IntervalMs,
+		RecordType: siteReq.RecordType, Resolver: siteReq.Resolver, Expected: siteReq.Expected,
+		GracePeriod: siteReq.GracePeriod, Steps: siteReq.Steps,
+	}); err != nil {
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if siteReq.Type == models.TypeMultistep && len(siteReq.Steps) == 0 {
+		http.Error(resp, "multistep check requires steps", http.StatusBadRequest)
+		return
+	}
+
 	siteReq.UserID = userID
 	jsonData, _ := json.Marshal(siteReq)
 
@@ -436,6 +556,126 @@ func (h *Handler) addUserSite(resp http.ResponseWriter, req *http.Request, userI
 	io.Copy(resp, dbResp.Body)
 }
 
+// updateUserSite проксирует PUT/PATCH /checker/{id} в db_service.
+// Владелец проверяется там же: сайт ищется по паре (id, user_id).
+func (h *Handler) updateUserSite(resp http.ResponseWriter, req *http.Request, userID, siteID int) {
+	var upd models.UpdateSiteRequest
+	if err := json.NewDecoder(req.Body).Decode(&upd); err != nil {
+		http.Error(resp, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Method == http.MethodPut && (upd.Site == nil || upd.Time == nil) {
+		http.Error(resp, "site and time are required for PUT", http.StatusBadRequest)
+		return
+	}
+	if upd.Time != nil && *upd.Time < configs.MinCheckInterval {
+		http.Error(resp, fmt.Sprintf("check interval must be at least %d seconds", configs.MinCheckInterval), http.StatusBadRequest)
+		return
+	}
+
+	// Поля связаны между собой (window_failures и window_size, type и site),
+	// поэтому проверяется сохранённый монитор с наложенными изменениями
+	stored, err := userSite(userID, siteID)
+	if errors.Is(err, errSiteNotFound) {
+		http.Error(resp, "site not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		configs.APILogger.Printf("get site %d failed: %v", siteID, err)
+		http.Error(resp, "internal error", http.StatusInternalServerError)
+		return
+	}
+	merged := mergeSiteUpdate(stored, upd)
+
+	if err := validateThresholds(merged.FailureThreshold, merged.WindowSize, merged.WindowFailures); err != nil {
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateAssertions(upd.Assertions); err != nil {
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+	method := ""
+	if upd.Method != nil {
+		method = strings.ToUpper(*upd.Method)
+		if method == "" {
+			http.Error(resp, "method must not be empty", http.StatusBadRequest)
+			return
+		}
+		upd.Method = &method
+	}
+	if err := validateRequestSpec(method, upd.Headers, upd.ExpectedStatus); err != nil {
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if upd.Type != nil && *upd.Type == "" {
+		http.Error(resp, "type must not be empty", http.StatusBadRequest)
+		return
+	}
+	if err := validateMonitor(monitorSpec{
+		Type: merged.Type, Site: merged.URL, TimeoutMs: merged.TimeoutMs,
+		Count: merged.Count, IntervalMs: merged.IntervalMs,
+		RecordType: merged.RecordType, Resolver: merged.Resolver, Expected: merged.Expected,
+		GracePeriod: merged.GracePeriod, Steps: merged.Steps,
+	}); err != nil {
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if merged.Type == models.TypeMultistep && len(merged.Steps) == 0 {
+		http.Error(resp, "multistep check requires steps", http.StatusBadRequest)
+		return
+	}
+
+	jsonData, _ := json.Marshal(upd)
+	dbReq, err := http.NewRequest(req.Method,
+		fmt.Sprintf("%s/checker/%d?user_id=%d", configs.DBURL, siteID, userID), bytes.NewReader(jsonData))
+	if err != nil {
+		http.Error(resp, "internal error", http.StatusInternalServerError)
+		return
+	}
+	dbReq.Header.Set("Content-Type", "application/json")
+
+	h.proxyToDB(resp, dbReq)
+}
+
+// deleteUserSite проксирует DELETE /checker/{id}?logs=keep|archive|purge в db_service.
+func (h *Handler) deleteUserSite(resp http.ResponseWriter, req *http.Request, userID, siteID int) {
+	q := url.Values{}
+	q.Set("user_id", strconv.Itoa(userID))
+	if logs := req.URL.Query().Get("logs"); logs != "" {
+		q.Set("logs", logs)
+	}
+
+	dbReq, err := http.NewRequest(http.MethodDelete,
+		fmt.Sprintf("%s/checker/%d?%s", configs.DBURL, siteID, q.Encode()), nil)
+	if err != nil {
+		http.Error(resp, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	h.proxyToDB(resp, dbReq)
+}
+
+// proxyToDB выполняет запрос к db_service и отдаёт клиенту его статус и тело.
+func (h *Handler) proxyToDB(resp http.ResponseWriter, dbReq *http.Request) {
+	dbResp, err := configs.Client.Do(dbReq)
+	if err != nil {
+		configs.APILogger.Printf("db service request %s %s failed: %v", dbReq.Method, dbReq.URL.Path, err)
+		http.Error(resp, "internal error", http.StatusInternalServerError)
+		return
+	}
+	defer dbResp.Body.Close()
+
+	if ct := dbResp.Header.Get("Content-Type"); ct != "" {
+		resp.Header().Set("Content-Type", ct)
+	}
+	if next := dbResp.Header.Get("X-Next-Cursor"); next != "" {
+		resp.Header().Set("X-Next-Cursor", next)
+	}
+	resp.WriteHeader(dbResp.StatusCode)
+	io.Copy(resp, dbResp.Body)
+}
+
 func (h *Handler) getAllUsersSites() ([]models.UserSites, error) {
 	// Запрос к DB service для получения всех пользователей и их сайтов
 	req, err := http.NewRequest(http.MethodGet, configs.DBURL+"/all-users-sites", nil)
@@ -461,6 +701,76 @@ func (h *Handler) getAllUsersSites() ([]models.UserSites, error) {
 	return usersSites, nil
 }
 
+var errSiteNotFound = errors.New("site not found")
+
+// userSite возвращает сохранённые настройки сайта пользователя.
+func userSite(userID, siteID int) (models.Site, error) {
+	resp, err := configs.Client.Get(fmt.Sprintf("%s/site-config/%d?user_id=%d", configs.DBURL, siteID, userID))
+	if err != nil {
+		return models.Site{}, fmt.Errorf("failed to get site: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return models.Site{}, errSiteNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return models.Site{}, fmt.Errorf("db service returned status: %d", resp.StatusCode)
+	}
+
+	var site models.Site
+	if err := json.NewDecoder(resp.Body).Decode(&site); err != nil {
+		return models.Site{}, fmt.Errorf("failed to parse site: %v", err)
+	}
+	return site, nil
+}
+
+// mergeSiteUpdate накладывает PUT/PATCH на сохранённый сайт так же, как это
+// делает db_service: nil - не менять, пустые headers, expected_status и expected - очистить.
+func mergeSiteUpdate(site models.Site, upd models.UpdateSiteRequest) models.Site {
+	setStr := func(dst *string, v *string) {
+		if v != nil {
+			*dst = *v
+		}
+	}
+	setInt := func(dst *int, v *int) {
+		if v != nil {
+			*dst = *v
+		}
+	}
+	setStr(&site.URL, upd.Site)
+	setInt(&site.CheckInterval, upd.Time)
+	setInt(&site.FailureThreshold, upd.FailureThreshold)
+	setInt(&site.WindowSize, upd.WindowSize)
+	setInt(&site.WindowFailures, upd.WindowFailures)
+	if upd.Assertions != nil {
+		site.Assertions = upd.Assertions
+	}
+	setStr(&site.Method, upd.Method)
+	if upd.Headers != nil {
+		site.Headers = upd.Headers
+	}
+	setStr(&site.Body, upd.Body)
+	if upd.ExpectedStatus != nil {
+		site.ExpectedStatus = upd.ExpectedStatus
+	}
+	setStr(&site.Type, upd.Type)
+	setInt(&site.TimeoutMs, upd.TimeoutMs)
+	setStr(&site.Banner, upd.Banner)
+	setInt(&site.Count, upd.Count)
+	setInt(&site.IntervalMs, upd.IntervalMs)
+	setStr(&site.RecordType, upd.RecordType)
+	setStr(&site.Resolver, upd.Resolver)
+	if upd.Expected != nil {
+		site.Expected = upd.Expected
+	}
+	setInt(&site.GracePeriod, upd.GracePeriod)
+	if len(upd.Steps) > 0 {
+		site.Steps = upd.Steps
+	}
+	return site
+}
+
 func (h *Handler) getUserEmail(userID int) (string, error) {
 	// Запрос к DB service для получения email пользователя
 	req, err := http.NewRequest(http.MethodGet,
@@ -489,22 +799,39 @@ func (h *Handler) getUserEmail(userID int) (string, error) {
 	return result.Email, nil
 }
 
-func (h *Handler) pingSite(url string) (*PingResult, error) {
-	configs.APILogger.Printf("ping site: %s", url)
-
-	pingRequest := models.PingRequest{Site: url}
+// pingSite отправляет проверку одному агенту ping_service.
+func (h *Handler) pingSite(agent configs.PingAgent, site models.Site) (*PingResult, error) {
+	configs.APILogger.Printf("ping site: %s (region %s)", site.URL, agent.Region)
+
+	pingRequest := models.PingRequest{
+		Site:           site.URL,
+		Assertions:     site.Assertions,
+		Method:         site.Method,
+		Headers:        site.Headers,
+		Body:           site.Body,
+		ExpectedStatus: site.ExpectedStatus,
+		Type:           site.Type,
+		TimeoutMs:      site.TimeoutMs,
+		Banner:         site.Banner,
+		Count:          site.Count,
+		IntervalMs:     site.IntervalMs,
+		RecordType:     site.RecordType,
+		Resolver:       site.Resolver,
+		Expected:       site.Expected,
+		Steps:          site.Steps,
+	}
 	jsonData, err := json.Marshal(pingRequest)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal ping request: %v", err)
 	}
 
-	req, err := http.NewRequest(http.MethodPost, configs.PingURL+"/ping", bytes.NewReader(jsonData))
+	req, err := http.NewRequest(http.MethodPost, agent.URL+"/ping", bytes.NewReader(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create ping request: %v", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout(site))
 	defer cancel()
 	req = req.WithContext(ctx)
 
@@ -533,15 +860,59 @@ func (h *Handler) pingSite(url string) (*PingResult, error) {
 	return &PingResult{
 		ResponseTime: pr.ResponseTime,
 		Status:       status,
+		Error:        pr.Error,
+		Cert:         pr.Cert,
+		Timings:      pr.Timings,
+		ICMP:         pr.ICMP,
+		DNS:          pr.DNS,
+		Steps:        pr.Steps,
+		Region:       agent.Region,
 	}, nil
 }
 
-func (h *Handler) savePingLog(userID int, site string, responseTime int64, status string) error {
+// pingTimeout - сколько ждать ответа ping_service: цепочка шагов идёт заметно дольше одного запроса.
+func pingTimeout(site models.Site) time.Duration {
+	if site.Type == models.TypeMultistep {
+		return configs.MultistepPingTimeout
+	}
+	return 10 * time.Second
+}
+
+func (h *Handler) savePingLog(userID int, site string, result *PingResult) error {
 	logData := map[string]interface{}{
 		"user_id":   userID,
 		"site":      site,
-		"resp_time": responseTime,
-		"status":    status,
+		"resp_time": result.ResponseTime,
+		"status":    result.Status,
+	}
+	if t := result.Timings; t != nil {
+		logData["dns_ms"] = t.DNSMs
+		logData["connect_ms"] = t.ConnectMs
+		logData["tls_ms"] = t.TLSMs
+		logData["ttfb_ms"] = t.TTFBMs
+		logData["transfer_ms"] = t.TransferMs
+	}
+	if st := result.ICMP; st != nil {
+		logData["packets_sent"] = st.PacketsSent
+		logData["packets_received"] = st.PacketsReceived
+		logData["loss_pct"] = st.LossPct
+		logData["rtt_min_ms"] = st.RTTMinMs
+		logData["rtt_avg_ms"] = st.RTTAvgMs
+		logData["rtt_max_ms"] = st.RTTMaxMs
+		logData["jitter_ms"] = st.JitterMs
+	}
+	if len(result.Steps) > 0 {
+		steps, _ := json.Marshal(result.Steps)
+		logData["steps"] = json.RawMessage(steps)
+	}
+	if result.Region != "" {
+		logData["region"] = result.Region
+	}
+	if result.Maintenance {
+		logData["maintenance"] = true
+	}
+	if !result.CheckedAt.IsZero() {
+		logData["req_time"] = result.CheckedAt.Format(time.RFC3339)
 	}
 
 	jsonData, _ := json.Marshal(logData)
@@ -564,6 +935,195 @@ func (h *Handler) savePingLog(userID int, site string, responseTime int64, statu
 	return nil
 }
 
+// validateThresholds проверяет пороги срабатывания алерта; 0 означает "не задано".
+func validateThresholds(failureThreshold, windowSize, windowFailures int) error {
+	if failureThreshold < 0 || windowSize < 0 || windowFailures < 0 {
+		return fmt.Errorf("thresholds must not be negative")
+	}
+	if windowSize > configs.MaxWindowSize {
+		return fmt.Errorf("window_size must be at most %d", configs.MaxWindowSize)
+	}
+	if windowFailures > 0 && windowSize > 0 && windowFailures > windowSize {
+		return fmt.Errorf("window_failures must not exceed window_size")
+	}
+	return nil
+}
+
+// validateAssertions проверяет настройки проверок тела до сохранения,
+// чтобы опечатка в regex не превращалась в вечный "сайт недоступен".
+func validateAssertions(a *models.Assertions) error {
+	if a == nil {
+		return nil
+	}
+	if a.BodyRegex != "" {
+		if _, err := regexp.Compile(a.BodyRegex); err != nil {
+			return fmt.Errorf("invalid body_regex: %v", err)
+		}
+	}
+	if len(a.JSONEquals) > 0 {
+		if a.JSONPath == "" {
+			return fmt.Errorf("json_equals requires json_path")
+		}
+		if !json.Valid(a.JSONEquals) {
+			return fmt.Errorf("json_equals must be a valid JSON value")
+		}
+	}
+	if a.MaxBodySize < 0 {
+		return fmt.Errorf("max_body_size must not be negative")
+	}
+	return nil
+}
+
+// validateRequestSpec проверяет метод, заголовки и ожидаемые коды ответа проверки; пустые значения допустимы.
+func validateRequestSpec(method string, headers map[string]string, expectedStatus []int) error {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+	default:
+		return fmt.Errorf("unsupported method %q", method)
+	}
+	for name, value := range headers {
+		if name == "" || strings.ContainsAny(name, " \t\r\n:") {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("invalid value for header %q", name)
+		}
+	}
+	for _, code := range expectedStatus {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("invalid expected status %d", code)
+		}
+	}
+	return nil
+}
+
+// Серия ICMP в ping_service: значения по умолчанию и предел длительности,
+// после которого ping_service отклоняет проверку
+const (
+	defaultICMPCount      = 4
+	defaultICMPIntervalMs = 200
+	defaultICMPTimeoutMs  = 1000
+	maxICMPBurstMs        = 10000
+)
+
+// icmpBurstMs - сколько в худшем случае длится серия: ответа на каждый запрос
+// ping_service ждёт до timeout_ms, а следующий отправляет не раньше чем через interval_ms.
+func icmpBurstMs(count, intervalMs, timeoutMs int) int {
+	if count == 0 {
+		count = defaultICMPCount
+	}
+	if intervalMs == 0 {
+		intervalMs = defaultICMPIntervalMs
+	}
+	if timeoutMs == 0 {
+		timeoutMs = defaultICMPTimeoutMs
+	}
+	return (count-1)*max(intervalMs, timeoutMs) + timeoutMs
+}
+
+// monitorSpec - параметры проверки, которые зависят от её типа
+type monitorSpec struct {
+	Type, Site                   string
+	TimeoutMs, Count, IntervalMs int
+	RecordType, Resolver         string
+	Expected                     []string
+	GracePeriod                  int
+	Steps                        []models.Step
+}
+
+// validateMonitor проверяет тип проверки и согласованность с ним адреса. При
+// изменении сайта сюда приходит сохранённый монитор с наложенным PATCH.
+func validateMonitor(m monitorSpec) error {
+	switch m.Type {
+	case "", models.TypeHTTP, models.TypeICMP:
+	case models.TypeTCP:
+		_, port, err := net.SplitHostPort(strings.TrimPrefix(m.Site, "tcp://"))
+		if err != nil {
+			return fmt.Errorf("tcp site must be host:port")
+		}
+		if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+			return fmt.Errorf("invalid port %q", port)
+		}
+	case models.TypeDNS, models.TypeHeartbeat, models.TypeMultistep:
+	default:
+		return fmt.Errorf("unknown type %q", m.Type)
+	}
+	if m.TimeoutMs < 0 || m.TimeoutMs > 30000 {
+		return fmt.Errorf("timeout_ms must be between 0 and 30000")
+	}
+	if m.Count < 0 || m.Count > 20 {
+		return fmt.Errorf("count must be between 0 and 20")
+	}
+	if m.IntervalMs != 0 && (m.IntervalMs < 50 || m.IntervalMs > 5000) {
+		return fmt.Errorf("interval_ms must be between 50 and 5000")
+	}
+	if m.Type == models.TypeICMP {
+		if burst := icmpBurstMs(m.Count, m.IntervalMs, m.TimeoutMs); burst > maxICMPBurstMs {
+			return fmt.Errorf("icmp series takes up to %d ms, max %d: lower count, interval_ms or timeout_ms", burst, maxICMPBurstMs)
+		}
+	}
+	if m.GracePeriod < 0 || m.GracePeriod > configs.MaxGracePeriod {
+		return fmt.Errorf("grace_period must be between 0 and %d", configs.MaxGracePeriod)
+	}
+
+	recordType := strings.ToUpper(m.RecordType)
+	switch recordType {
+	case "", "A", "AAAA", "CNAME", "MX", "TXT":
+	default:
+		return fmt.Errorf("unsupported record_type %q", m.RecordType)
+	}
+	if m.Resolver != "" {
+		host := m.Resolver
+		if h, _, err := net.SplitHostPort(m.Resolver); err == nil {
+			host = h
+		}
+		if net.ParseIP(host) == nil {
+			return fmt.Errorf("resolver must be an IP address with optional port")
+		}
+	}
+	if recordType == "A" || recordType == "AAAA" {
+		for _, rec := range m.Expected {
+			if net.ParseIP(rec) == nil {
+				return fmt.Errorf("expected record %q is not an IP address", rec)
+			}
+		}
+	}
+	return validateSteps(m.Steps)
+}
+
+// validateSteps проверяет описание шагов multistep-проверки; подстановку
+// переменных ping_service проверяет сам и отвечает 400 на неизвестные.
+func validateSteps(steps []models.Step) error {
+	if len(steps) > configs.MaxSteps {
+		return fmt.Errorf("at most %d steps are allowed", configs.MaxSteps)
+	}
+	for i := range steps {
+		step := &steps[i]
+		if step.URL == "" {
+			return fmt.Errorf("step %d: url is required", i+1)
+		}
+		step.Method = strings.ToUpper(step.Method)
+		if err := validateRequestSpec(step.Method, step.Headers, step.ExpectedStatus); err != nil {
+			return fmt.Errorf("step %d: %v", i+1, err)
+		}
+		if err := validateAssertions(step.Assertions); err != nil {
+			return fmt.Errorf("step %d: %v", i+1, err)
+		}
+		for _, ex := range step.Extract {
+			if ex.Var == "" || ex.Path == "" {
+				return fmt.Errorf("step %d: extract needs var and path", i+1)
+			}
+			switch ex.From {
+			case "header", "json", "cookie":
+			default:
+				return fmt.Errorf("step %d: extract from must be header, json or cookie", i+1)
+			}
+		}
+	}
+	return nil
+}
+
 func isValidEmail(email string) bool {
 	_, err := mail.ParseAddress(email)
 	if err != nil {
@@ -578,4 +1138,13 @@ func isValidEmail(email string) bool {
 type PingResult struct {
 	ResponseTime int64
 	Status       string
+	Error        string // причина неудачи от ping_service (таймаут, статус, проваленная проверка тела)
+	Cert         *models.CertInfo
+	Timings      *models.Timings
+	ICMP         *models.ICMPStats
+	DNS          *models.DNSResult
+	Steps        []models.StepResult
+	Region       string    // регион агента; пусто - результат heartbeat
+	CheckedAt    time.Time // общее для всех регионов одной проверки; пусто - время записи лога
+	Maintenance  bool      // проверка пришлась на окно обслуживания
 }
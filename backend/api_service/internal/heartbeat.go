@@ -0,0 +1,251 @@
+package internal
+
+import (
+	"api_service/configs"
+	"api_service/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// heartbeatTokenRe - формат токенов, которые выдаёт db_service: 16 случайных байт в hex
+var heartbeatTokenRe = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// heartbeatLimiter пропускает не больше одного сигнала на токен за minGap.
+// Записи старше minGap вычищаются раз в минуту, чтобы перебор токенов не раздувал карту.
+type heartbeatLimiter struct {
+	minGap time.Duration
+
+	mu    sync.Mutex
+	last  map[string]time.Time
+	swept time.Time
+}
+
+func newHeartbeatLimiter(minGap time.Duration) *heartbeatLimiter {
+	return &heartbeatLimiter{minGap: minGap, last: map[string]time.Time{}}
+}
+
+func (l *heartbeatLimiter) allow(token string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.swept) >= time.Minute {
+		for t, at := range l.last {
+			if now.Sub(at) >= l.minGap {
+				delete(l.last, t)
+			}
+		}
+		l.swept = now
+	}
+
+	if at, ok := l.last[token]; ok && now.Sub(at) < l.minGap {
+		return false
+	}
+	l.last[token] = now
+	return true
+}
+
+// HeartbeatHandler принимает сигнал от cron-задачи: GET/POST /heartbeat/{token}.
+// JWT не нужен - эндпоинт защищён только секретным токеном монитора.
+func (h *Handler) HeartbeatHandler(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodPost && req.Method != http.MethodHead {
+		http.Error(resp, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token := strings.TrimPrefix(req.URL.Path, "/heartbeat/")
+	if !heartbeatTokenRe.MatchString(token) {
+		http.Error(resp, "invalid heartbeat token", http.StatusBadRequest)
+		return
+	}
+	// Эндпоинт открыт, и каждый сигнал - запись в ClickHouse: частые повторы отбрасываем
+	if !h.beats.allow(token, time.Now()) {
+		resp.Header().Set("Retry-After", "1")
+		http.Error(resp, "too many heartbeats", http.StatusTooManyRequests)
+		return
+	}
+
+	dbResp, err := configs.Client.Post(configs.DBURL+"/heartbeat/"+url.PathEscape(token), "application/json", nil)
+	if err != nil {
+		configs.APILogger.Println("record heartbeat failed:", err)
+		http.Error(resp, "internal error", http.StatusInternalServerError)
+		return
+	}
+	defer dbResp.Body.Close()
+
+	if dbResp.StatusCode == http.StatusNotFound {
+		http.Error(resp, "heartbeat not found", http.StatusNotFound)
+		return
+	}
+	if dbResp.StatusCode != http.StatusOK {
+		configs.APILogger.Printf("record heartbeat: db service returned status: %d", dbResp.StatusCode)
+		http.Error(resp, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	var beat struct {
+		UserID int         `json:"user_id"`
+		Site   models.Site `json:"site"`
+	}
+	if err := json.NewDecoder(dbResp.Body).Decode(&beat); err != nil {
+		configs.APILogger.Println("parse heartbeat response failed:", err)
+		http.Error(resp, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	// Сигнал уже сохранён, поэтому ошибки лога и алертов задаче не возвращаем
+	h.observeHeartbeat(beat.UserID, beat.Site, &PingResult{Status: "ok"})
+
+	resp.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(resp).Encode(map[string]string{"status": "ok"})
+}
+
+// observeHeartbeat пишет лог и пропускает результат через StateTracker,
+// как checkSite делает это для результата пинга.
+func (h *Handler) observeHeartbeat(userID int, site models.Site, result *PingResult) {
+	result.Maintenance = h.maintenance.Active(userID, site.ID, time.Now())
+	if err := h.savePingLog(userID, site.URL, result); err != nil {
+		configs.APILogger.Printf("save heartbeat log for site %d failed: %v", site.ID, err)
+	}
+	if result.Maintenance {
+		return
+	}
+
+	tr, err := h.states.Observe(site, result.Status, time.Now())
+	if err != nil {
+		configs.APILogger.Printf("update state of site %d failed: %v", site.ID, err)
+	} else {
+		h.trackIncident(site, result, tr)
+		if tr != nil {
+			h.notifyTransition(userID, site, result, tr)
+		}
+	}
+}
+
+// HeartbeatSweeper периодически ищет heartbeat-мониторы, от которых не пришёл
+// сигнал за check_interval + grace_period, и засчитывает им неудачную проверку.
+// Пока сигнала нет, неудача засчитывается раз в check_interval, так что пороги
+// N подряд / M из K работают так же, как для обычных проверок.
+type HeartbeatSweeper struct {
+	h    *Handler
+	stop chan struct{}
+}
+
+func NewHeartbeatSweeper(h *Handler) *HeartbeatSweeper {
+	return &HeartbeatSweeper{h: h, stop: make(chan struct{})}
+}
+
+// Start запускает поиск пропущенных сигналов в отдельной горутине.
+func (s *HeartbeatSweeper) Start() {
+	go s.loop()
+}
+
+func (s *HeartbeatSweeper) Stop() {
+	close(s.stop)
+}
+
+func (s *HeartbeatSweeper) loop() {
+	tick := time.NewTicker(configs.HeartbeatSweepTick)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-tick.C:
+			s.sweep(now)
+		}
+	}
+}
+
+func (s *HeartbeatSweeper) sweep(now time.Time) {
+	if !s.h.leader.IsLeader() {
+		return // пропуски ищет только лидер, иначе алерт придёт от каждой реплики
+	}
+
+	usersSites, err := getHeartbeatSites()
+	if err != nil {
+		configs.APILogger.Println("heartbeat sweeper: get monitors failed:", err)
+		return
+	}
+
+	for _, us := range usersSites {
+		for _, site := range us.Sites {
+			missed, err := s.h.states.MarkHeartbeatMissed(site, now)
+			if err != nil {
+				configs.APILogger.Printf("heartbeat sweeper: site %d: %v", site.ID, err)
+				continue
+			}
+			if !missed {
+				continue
+			}
+
+			last := "never"
+			if site.LastHeartbeatAt != nil {
+				last = site.LastHeartbeatAt.UTC().Format(time.RFC3339)
+			}
+			configs.APILogger.Printf("heartbeat sweeper: site %d (%s) missed heartbeat, last at %s", site.ID, site.URL, last)
+			s.h.observeHeartbeat(us.UserID, site, &PingResult{
+				ResponseTime: -1,
+				Status:       "bad",
+				Error:        "no heartbeat since " + last,
+			})
+		}
+	}
+}
+
+// MarkHeartbeatMissed проверяет, не истёк ли срок очередного сигнала, и если
+// истёк - запоминает момент пропуска и возвращает true. Первый пропуск
+// засчитывается через check_interval + grace_period после сигнала, следующие -
+// через каждый check_interval после предыдущего пропуска.
+func (t *StateTracker) MarkHeartbeatMissed(site models.Site, now time.Time) (bool, error) {
+	if site.LastHeartbeatAt == nil {
+		return false, nil
+	}
+
+	ts := t.lockSite(site.ID, true)
+	defer ts.mu.Unlock()
+
+	if err := ts.load(site.ID); err != nil {
+		return false, err
+	}
+
+	interval := checkInterval(site)
+	deadline := site.LastHeartbeatAt.Add(interval + time.Duration(site.GracePeriod)*time.Second)
+	if missed := ts.state.HeartbeatMissedAt; missed != nil && missed.After(*site.LastHeartbeatAt) {
+		deadline = missed.Add(interval)
+	}
+	if !now.After(deadline) {
+		return false, nil
+	}
+
+	next := *ts.state
+	at := now.UTC()
+	next.HeartbeatMissedAt = &at
+	if err := ts.save(next); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func getHeartbeatSites() ([]models.UserSites, error) {
+	resp, err := configs.Client.Get(configs.DBURL + "/heartbeats")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get heartbeats: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("db service returned status: %d", resp.StatusCode)
+	}
+
+	var usersSites []models.UserSites
+	if err := json.NewDecoder(resp.Body).Decode(&usersSites); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %v", err)
+	}
+	return usersSites, nil
+}
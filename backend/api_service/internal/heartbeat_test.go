@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// heartbeatDBStub - db_service, который не знает ни одного токена
+func heartbeatDBStub(t *testing.T) (*atomic.Int32, *atomic.Value) {
+	t.Helper()
+	var hits atomic.Int32
+	var path atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		path.Store(r.URL.EscapedPath())
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	useDBStub(t, srv)
+	return &hits, &path
+}
+
+func TestHeartbeatHandlerRejectsMalformedTokens(t *testing.T) {
+	hits, _ := heartbeatDBStub(t)
+	h := &Handler{beats: newHeartbeatLimiter(time.Second)}
+
+	for _, path := range []string{
+		"/heartbeat/",
+		"/heartbeat/abc",
+		"/heartbeat/0123456789abcdef0123456789ABCDEF",
+		"/heartbeat/0123456789abcdef0123456789abcdef0",
+		"/heartbeat/0123456789abcdef0123456789abcde%3F",
+		"/heartbeat/../sites/0123456789abcdef0123456789abcdef",
+		"/heartbeat/0123456789abcdef%2F..%2Fusers%2F12345678",
+	} {
+		rec := httptest.NewRecorder()
+		h.HeartbeatHandler(rec, httptest.NewRequest(http.MethodPost, path, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", path, rec.Code)
+		}
+	}
+	if n := hits.Load(); n != 0 {
+		t.Errorf("db_service got %d requests for malformed tokens", n)
+	}
+}
+
+func TestHeartbeatHandlerDropsRapidBeats(t *testing.T) {
+	hits, path := heartbeatDBStub(t)
+	h := &Handler{beats: newHeartbeatLimiter(time.Second)}
+	const token = "0123456789abcdef0123456789abcdef"
+
+	beat := func() int {
+		rec := httptest.NewRecorder()
+		h.HeartbeatHandler(rec, httptest.NewRequest(http.MethodPost, "/heartbeat/"+token, nil))
+		return rec.Code
+	}
+	if code := beat(); code != http.StatusNotFound {
+		t.Fatalf("first beat: status = %d, want db_service's 404", code)
+	}
+	if p := path.Load(); p != "/heartbeat/"+token {
+		t.Errorf("db_service path = %v", p)
+	}
+	if code := beat(); code != http.StatusTooManyRequests {
+		t.Errorf("second beat: status = %d, want 429", code)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("db_service got %d requests, want 1", n)
+	}
+}
+
+func TestHeartbeatLimiter(t *testing.T) {
+	l := newHeartbeatLimiter(time.Second)
+	start := time.Now()
+
+	if !l.allow("a", start) || !l.allow("b", start) {
+		t.Fatal("first beats must pass")
+	}
+	if l.allow("a", start.Add(500*time.Millisecond)) {
+		t.Error("beat within a second passed")
+	}
+	if !l.allow("a", start.Add(time.Second)) {
+		t.Error("beat a second later was dropped")
+	}
+
+	// Старые записи вычищаются, карта не растёт от перебора токенов
+	l.allow("c", start.Add(2*time.Minute))
+	if _, ok := l.last["b"]; ok || len(l.last) != 1 {
+		t.Errorf("stale tokens kept: %v", l.last)
+	}
+}
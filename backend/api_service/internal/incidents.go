@@ -0,0 +1,221 @@
+package internal
+
+import (
+	"api_service/configs"
+	"api_service/models"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// incidentRef - то, что api_service нужно знать об инциденте из ответа db_service
+type incidentRef struct {
+	ID int `json:"id"`
+}
+
+// trackIncident ведёт инцидент сайта по результату проверки: переход down
+// открывает его, каждая следующая неудача продлевает, recovery закрывает.
+// ID инцидента попадает в tr и оттуда в уведомление. Сбой db_service не
+// мешает уведомлению - оно просто уйдёт без номера инцидента.
+func (h *Handler) trackIncident(site models.Site, result *PingResult, tr *Transition) {
+	switch {
+	case tr != nil && tr.Event == EventDown:
+		firstFailure := tr.FirstFailure
+		if firstFailure.IsZero() {
+			firstFailure = tr.At
+		}
+		id, err := openIncident(site.ID, tr.At, firstFailure, result.Error)
+		if err != nil {
+			configs.APILogger.Printf("open incident for site %d failed: %v", site.ID, err)
+			return
+		}
+		tr.IncidentID = id
+	case tr != nil && tr.Event == EventRecovery:
+		id, err := resolveSiteIncident(site.ID, tr.At)
+		if err != nil {
+			configs.APILogger.Printf("resolve incident for site %d failed: %v", site.ID, err)
+			return
+		}
+		tr.IncidentID = id
+	case result.Status == "bad":
+		if err := recordIncidentFailure(site.ID, time.Now()); err != nil {
+			configs.APILogger.Printf("update incident for site %d failed: %v", site.ID, err)
+		}
+	}
+}
+
+func openIncident(siteID int, openedAt, firstFailure time.Time, rootError string) (int, error) {
+	jsonData, _ := json.Marshal(map[string]interface{}{
+		"site_id":          siteID,
+		"opened_at":        openedAt.UTC(),
+		"first_failure_at": firstFailure.UTC(),
+		"root_error":       rootError,
+	})
+	resp, err := configs.Client.Post(configs.DBURL+"/incidents", "application/json", bytes.NewReader(jsonData))
+	if err != nil {
+		return 0, fmt.Errorf("failed to open incident: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// 200 - инцидент уже был открыт, например до рестарта api_service
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("db service returned status: %d", resp.StatusCode)
+	}
+
+	var in incidentRef
+	if err := json.NewDecoder(resp.Body).Decode(&in); err != nil {
+		return 0, fmt.Errorf("failed to parse incident: %v", err)
+	}
+	return in.ID, nil
+}
+
+func recordIncidentFailure(siteID int, at time.Time) error {
+	return postSiteIncident(siteID, "failure", at, nil)
+}
+
+// resolveSiteIncident закрывает инцидент восстановившегося сайта и возвращает
+// его ID; 0 - открытого инцидента не было (например, его закрыли вручную).
+func resolveSiteIncident(siteID int, at time.Time) (int, error) {
+	var in incidentRef
+	if err := postSiteIncident(siteID, "resolve", at, &in); err != nil {
+		return 0, err
+	}
+	return in.ID, nil
+}
+
+func postSiteIncident(siteID int, action string, at time.Time, out *incidentRef) error {
+	jsonData, _ := json.Marshal(map[string]interface{}{"at": at.UTC()})
+	resp, err := configs.Client.Post(fmt.Sprintf("%s/site-incident/%d/%s", configs.DBURL, siteID, action),
+		"application/json", bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to %s incident: %v", action, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent, http.StatusNotFound: // 404 - незакрытого инцидента нет
+		return nil
+	default:
+		return fmt.Errorf("db service returned status: %d", resp.StatusCode)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to parse incident: %v", err)
+		}
+	}
+	return nil
+}
+
+// IncidentsHandler - инциденты сайтов пользователя:
+//
+//	GET  /incidents?site_id=&status=open|acknowledged|resolved
+//	GET  /incidents/{id}                     - с хронологией
+//	POST /incidents/{id}/ack                 - взять в работу
+//	POST /incidents/{id}/resolve             - закрыть вручную
+//	POST /incidents/{id}/comments            - комментарий
+//
+// Тело POST - {"message": "..."}, для ack и resolve необязательное.
+// Автором действия записывается email пользователя.
+func (h *Handler) IncidentsHandler(resp http.ResponseWriter, req *http.Request) {
+	userID, err := h.verifyJWT(req)
+	if err != nil {
+		http.Error(resp, "unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	pathParts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	if len(pathParts) > 3 {
+		http.Error(resp, "invalid URL", http.StatusBadRequest)
+		return
+	}
+
+	params := url.Values{}
+	params.Set("user_id", strconv.Itoa(userID))
+
+	if len(pathParts) == 1 {
+		if req.Method != http.MethodGet {
+			http.Error(resp, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		for _, p := range []string{"site_id", "status"} {
+			if v := req.URL.Query().Get(p); v != "" {
+				params.Set(p, v)
+			}
+		}
+		dbReq, err := http.NewRequest(http.MethodGet, configs.DBURL+"/incidents?"+params.Encode(), nil)
+		if err != nil {
+			http.Error(resp, "internal error", http.StatusInternalServerError)
+			return
+		}
+		h.proxyToDB(resp, dbReq)
+		return
+	}
+
+	incidentID, err := strconv.Atoi(pathParts[1])
+	if err != nil {
+		http.Error(resp, "invalid incident ID", http.StatusBadRequest)
+		return
+	}
+	dbURL := fmt.Sprintf("%s/incidents/%d", configs.DBURL, incidentID)
+
+	if len(pathParts) == 2 {
+		if req.Method != http.MethodGet {
+			http.Error(resp, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		dbReq, err := http.NewRequest(http.MethodGet, dbURL+"?"+params.Encode(), nil)
+		if err != nil {
+			http.Error(resp, "internal error", http.StatusInternalServerError)
+			return
+		}
+		h.proxyToDB(resp, dbReq)
+		return
+	}
+
+	action := pathParts[2]
+	if action != "ack" && action != "resolve" && action != "comments" {
+		http.Error(resp, "not found", http.StatusNotFound)
+		return
+	}
+	if req.Method != http.MethodPost {
+		http.Error(resp, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		Message string `json:"message"`
+	}
+	if req.ContentLength != 0 {
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			http.Error(resp, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+	if action == "comments" && strings.TrimSpace(body.Message) == "" {
+		http.Error(resp, "message is required", http.StatusBadRequest)
+		return
+	}
+
+	author, err := h.getUserEmail(userID)
+	if err != nil {
+		configs.APILogger.Printf("get user email failed: %v", err)
+		http.Error(resp, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	jsonData, _ := json.Marshal(map[string]string{"by": author, "message": body.Message})
+	dbReq, err := http.NewRequest(http.MethodPost, dbURL+"/"+action+"?"+params.Encode(), bytes.NewReader(jsonData))
+	if err != nil {
+		http.Error(resp, "internal error", http.StatusInternalServerError)
+		return
+	}
+	dbReq.Header.Set("Content-Type", "application/json")
+
+	h.proxyToDB(resp, dbReq)
+}
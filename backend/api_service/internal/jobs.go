@@ -0,0 +1,326 @@
+package internal
+
+import (
+	"api_service/configs"
+	"api_service/models"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Статусы прогона
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded" // все проверки выполнены, даже если часть сайтов недоступна
+	JobFailed    = "failed"    // прогон не удалось начать, например не получили список сайтов
+	JobCancelled = "cancelled"
+	JobTimedOut  = "timed_out"
+)
+
+const JobKindPingAll = "ping_all"
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobFinished = errors.New("job already finished")
+)
+
+// Job - один прогон проверок всех сайтов
+type Job struct {
+	ID         int         `json:"id"`
+	Kind       string      `json:"kind"`
+	Status     string      `json:"status"`
+	StartedAt  time.Time   `json:"started_at"`
+	FinishedAt *time.Time  `json:"finished_at,omitempty"`
+	Summary    *RunSummary `json:"summary,omitempty"`
+	Error      string      `json:"error,omitempty"`
+
+	cancel context.CancelFunc
+}
+
+// JobRunner выполняет прогоны /pingAll внутри процесса: не больше одного
+// одновременно, каждый со своим таймаутом и возможностью отмены. Прогоны
+// идут только на лидере, как и плановые проверки, иначе сайт проверялся бы
+// дважды. История последних прогонов хранится в памяти лидера и после
+// рестарта или смены лидера пропадает - результаты самих проверок при этом
+// остаются в логах пингов.
+type JobRunner struct {
+	h *Handler
+
+	mu      sync.Mutex
+	nextID  int
+	current *Job
+	history []*Job // старые в начале
+	skipped int    // запуски, отброшенные с последнего состоявшегося прогона
+}
+
+func NewJobRunner(h *Handler) *JobRunner {
+	return &JobRunner{h: h, nextID: 1}
+}
+
+// StartPingAll запускает прогон в фоне. Если предыдущий прогон ещё идёт,
+// новый не запускается: возвращается текущий прогон и false.
+func (r *JobRunner) StartPingAll() (Job, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.current != nil {
+		r.skipped++
+		return *r.current, false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), configs.JobTimeout)
+	job := &Job{
+		ID:        r.nextID,
+		Kind:      JobKindPingAll,
+		Status:    JobRunning,
+		StartedAt: time.Now().UTC(),
+		cancel:    cancel,
+	}
+	r.nextID++
+	r.current = job
+	r.history = append(r.history, job)
+	if len(r.history) > configs.JobHistorySize {
+		r.history = r.history[len(r.history)-configs.JobHistorySize:]
+	}
+
+	go r.run(ctx, job)
+	return *job, true
+}
+
+func (r *JobRunner) run(ctx context.Context, job *Job) {
+	defer job.cancel()
+
+	usersSites, err := r.h.getAllUsersSites()
+	if err != nil {
+		configs.APILogger.Printf("job %d: get all users sites failed: %v", job.ID, err)
+		r.finish(job, nil, err)
+		return
+	}
+
+	var jobs []CheckJob
+	for _, userSite := range usersSites {
+		for _, site := range userSite.Sites {
+			if site.Type == models.TypeHeartbeat {
+				continue // пропущенные сигналы ищет HeartbeatSweeper
+			}
+			jobs = append(jobs, CheckJob{UserID: userSite.UserID, Site: site})
+		}
+	}
+
+	summary := r.h.pool.Run(ctx, jobs)
+	r.finish(job, &summary, ctx.Err())
+}
+
+// finish фиксирует итог прогона и освобождает место для следующего.
+func (r *JobRunner) finish(job *Job, summary *RunSummary, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC()
+	job.FinishedAt = &now
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		job.Status = JobTimedOut
+		job.Error = "timed out after " + configs.JobTimeout.String()
+	case errors.Is(err, context.Canceled):
+		job.Status = JobCancelled
+	case err != nil:
+		job.Status = JobFailed
+		job.Error = err.Error()
+	default:
+		job.Status = JobSucceeded
+	}
+
+	if summary != nil {
+		summary.SkippedRuns = r.skipped
+		job.Summary = summary
+		configs.APILogger.Printf("job %d %s: total=%d ok=%d failed=%d cancelled=%d skipped_runs=%d duration=%dms",
+			job.ID, job.Status, summary.Total, summary.Successful, summary.Failed, summary.Cancelled,
+			summary.SkippedRuns, summary.DurationMs)
+	}
+	r.skipped = 0
+	r.current = nil
+}
+
+// Cancel отменяет идущий прогон. Начатые проверки доводятся до конца,
+// остальные не запускаются; статус сменится, когда прогон остановится.
+func (r *JobRunner) Cancel(id int) (Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job := r.find(id)
+	if job == nil {
+		return Job{}, ErrJobNotFound
+	}
+	if job.Status != JobRunning {
+		return *job, ErrJobFinished
+	}
+	job.cancel()
+	configs.APILogger.Printf("job %d: cancel requested", id)
+	return *job, nil
+}
+
+// CancelCurrent отменяет идущий прогон, если он есть: реплика перестала быть лидером.
+func (r *JobRunner) CancelCurrent() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.current != nil {
+		r.current.cancel()
+		configs.APILogger.Printf("job %d: cancelled, replica is no longer the leader", r.current.ID)
+	}
+}
+
+func (r *JobRunner) Get(id int) (Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job := r.find(id)
+	if job == nil {
+		return Job{}, ErrJobNotFound
+	}
+	return *job, nil
+}
+
+// List возвращает историю прогонов, новые первыми.
+func (r *JobRunner) List() []Job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	jobs := make([]Job, 0, len(r.history))
+	for i := len(r.history) - 1; i >= 0; i-- {
+		jobs = append(jobs, *r.history[i])
+	}
+	return jobs
+}
+
+// find ищет прогон в истории; вызывается под r.mu.
+func (r *JobRunner) find(id int) *Job {
+	for _, job := range r.history {
+		if job.ID == id {
+			return job
+		}
+	}
+	return nil
+}
+
+// checkInternalToken пускает только запросы с заголовком X-Internal-Token,
+// совпадающим с INTERNAL_API_TOKEN. Без настроенного токена эндпоинты выключены.
+func checkInternalToken(resp http.ResponseWriter, req *http.Request) bool {
+	if configs.InternalAPIToken == "" {
+		http.Error(resp, "internal API is disabled", http.StatusForbidden)
+		return false
+	}
+	token := req.Header.Get("X-Internal-Token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(configs.InternalAPIToken)) != 1 {
+		configs.APILogger.Printf("internal API: rejected %s %s from %s", req.Method, req.URL.Path, req.RemoteAddr)
+		http.Error(resp, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// rejectFollower отвечает 409 с ID лидера, если реплика - не лидер: прогоны
+// и их история есть только у лидера.
+func (h *Handler) rejectFollower(resp http.ResponseWriter) bool {
+	if h.leader.IsLeader() {
+		return false
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(http.StatusConflict)
+	json.NewEncoder(resp).Encode(map[string]interface{}{
+		"message": "This replica is not the leader, send the request to the leader",
+		"leader":  h.leader.Holder(),
+	})
+	return true
+}
+
+// PingAllHandler запускает внеплановый прогон всех сайтов: POST /pingAll.
+// Ответ приходит сразу, ход прогона смотрят через /jobs/{id}.
+func (h *Handler) PingAllHandler(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(resp, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !checkInternalToken(resp, req) || h.rejectFollower(resp) {
+		return
+	}
+
+	job, started := h.jobs.StartPingAll()
+	resp.Header().Set("Content-Type", "application/json")
+
+	// Прогоны не накапливаются: если предыдущий ещё идёт, новый пропускаем
+	if !started {
+		configs.APILogger.Printf("pingAll skipped: job %d still in progress", job.ID)
+		resp.WriteHeader(http.StatusConflict)
+		json.NewEncoder(resp).Encode(map[string]interface{}{
+			"message": "Ping skipped: previous run still in progress",
+			"job":     job,
+		})
+		return
+	}
+
+	configs.APILogger.Printf("pingAll: job %d started", job.ID)
+	resp.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(resp).Encode(map[string]interface{}{
+		"message": "Ping started",
+		"job":     job,
+	})
+}
+
+// JobsHandler - история и отмена прогонов:
+// GET /jobs, GET /jobs/{id}, DELETE /jobs/{id}.
+func (h *Handler) JobsHandler(resp http.ResponseWriter, req *http.Request) {
+	if !checkInternalToken(resp, req) || h.rejectFollower(resp) {
+		return
+	}
+
+	rest := strings.Trim(strings.TrimPrefix(req.URL.Path, "/jobs"), "/")
+	if rest == "" {
+		if req.Method != http.MethodGet {
+			http.Error(resp, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		resp.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(resp).Encode(h.jobs.List())
+		return
+	}
+
+	id, err := strconv.Atoi(rest)
+	if err != nil {
+		http.Error(resp, "invalid job id", http.StatusBadRequest)
+		return
+	}
+
+	var job Job
+	switch req.Method {
+	case http.MethodGet:
+		job, err = h.jobs.Get(id)
+	case http.MethodDelete:
+		job, err = h.jobs.Cancel(id)
+	default:
+		http.Error(resp, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch {
+	case errors.Is(err, ErrJobNotFound):
+		http.Error(resp, "job not found", http.StatusNotFound)
+		return
+	case errors.Is(err, ErrJobFinished):
+		http.Error(resp, "job already finished", http.StatusConflict)
+		return
+	}
+
+	resp.Header().Set("Content-Type", "application/json")
+	if req.Method == http.MethodDelete {
+		resp.WriteHeader(http.StatusAccepted)
+	}
+	json.NewEncoder(resp).Encode(job)
+}
@@ -0,0 +1,179 @@
+package internal
+
+import (
+	"api_service/configs"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// LeaderElector выбирает среди реплик api_service одну, которая планирует
+// проверки и ищет пропущенные heartbeat-сигналы. Лидерство - это аренда
+// в db_service с TTL: лидер продлевает её каждую треть TTL, остальные
+// реплики с той же частотой пытаются её забрать. Если лидер упал, роль
+// переходит к другой реплике не позже чем через TTL, при штатной
+// остановке - сразу, потому что аренда освобождается.
+type LeaderElector struct {
+	h *Handler
+
+	mu     sync.Mutex
+	leader bool
+	until  time.Time // до какого момента аренда гарантированно наша
+	holder string    // текущий лидер по последнему ответу db_service
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewLeaderElector(h *Handler) *LeaderElector {
+	return &LeaderElector{
+		h:    h,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// Start запускает продление аренды в отдельной горутине.
+func (e *LeaderElector) Start() {
+	go e.loop()
+}
+
+// Stop прекращает продление и отдаёт аренду, чтобы другая реплика
+// подхватила роль, не дожидаясь истечения TTL.
+func (e *LeaderElector) Stop() {
+	close(e.stop)
+	<-e.done
+
+	e.mu.Lock()
+	wasLeader := e.leader
+	e.leader = false
+	e.mu.Unlock()
+
+	if wasLeader {
+		if err := releaseLease(configs.SchedulerLease, configs.InstanceID); err != nil {
+			configs.APILogger.Println("leader: release lease failed:", err)
+			return
+		}
+		configs.APILogger.Printf("leader: %s released lease", configs.InstanceID)
+	}
+}
+
+// IsLeader - держит ли реплика аренду прямо сейчас. Если продлить аренду
+// не удаётся, лидерство заканчивается вместе с её сроком.
+func (e *LeaderElector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader && time.Now().Before(e.until)
+}
+
+// Holder возвращает InstanceID текущего лидера; пусто, если db_service ещё не ответил.
+func (e *LeaderElector) Holder() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.holder
+}
+
+func (e *LeaderElector) loop() {
+	defer close(e.done)
+
+	e.renew()
+	tick := time.NewTicker(configs.LeaseTTL / 3)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-tick.C:
+			e.renew()
+		}
+	}
+}
+
+func (e *LeaderElector) renew() {
+	// Срок отсчитываем от момента до запроса: db_service продлил аренду не раньше
+	start := time.Now()
+	lease, err := acquireLease(configs.SchedulerLease, configs.InstanceID, configs.LeaseTTL)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err != nil {
+		configs.APILogger.Println("leader: renew lease failed:", err)
+		if e.leader && !time.Now().Before(e.until) {
+			e.leader = false
+			e.holder = ""
+			configs.APILogger.Printf("leader: %s lost leadership, lease expired", configs.InstanceID)
+			e.h.jobs.CancelCurrent()
+		}
+		return
+	}
+
+	switch {
+	case lease.Acquired && !e.leader:
+		// Пока реплика была ведомой, состояния сайтов меняла другая - кэш устарел
+		e.h.states.Reset()
+		configs.APILogger.Printf("leader: %s became leader", configs.InstanceID)
+	case !lease.Acquired && e.leader:
+		configs.APILogger.Printf("leader: %s lost leadership to %s", configs.InstanceID, lease.Holder)
+		// Дальше сайты проверяет новый лидер; прогон /pingAll здесь дублировал бы его
+		e.h.jobs.CancelCurrent()
+	}
+	e.leader = lease.Acquired
+	e.holder = lease.Holder
+	if lease.Acquired {
+		e.until = start.Add(configs.LeaseTTL)
+	}
+}
+
+type lease struct {
+	Name      string    `json:"name"`
+	Holder    string    `json:"holder"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Acquired  bool      `json:"acquired"`
+}
+
+func acquireLease(name, holder string, ttl time.Duration) (*lease, error) {
+	jsonData, _ := json.Marshal(map[string]interface{}{
+		"holder": holder,
+		"ttl_ms": ttl.Milliseconds(),
+	})
+	resp, err := configs.Client.Post(configs.DBURL+"/lease/"+url.PathEscape(name), "application/json", bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire lease: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("db service returned status: %d", resp.StatusCode)
+	}
+
+	var l lease
+	if err := json.NewDecoder(resp.Body).Decode(&l); err != nil {
+		return nil, fmt.Errorf("failed to parse lease: %v", err)
+	}
+	return &l, nil
+}
+
+func releaseLease(name, holder string) error {
+	req, err := http.NewRequest(http.MethodDelete,
+		configs.DBURL+"/lease/"+url.PathEscape(name)+"?holder="+url.QueryEscape(holder), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := configs.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to release lease: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("db service returned status: %d", resp.StatusCode)
+	}
+	return nil
+}
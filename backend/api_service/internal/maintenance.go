@@ -0,0 +1,186 @@
+package internal
+
+import (
+	"api_service/configs"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaintenanceWindowsHandler - окна обслуживания пользователя:
+//
+//	GET  /maintenance-windows
+//	POST /maintenance-windows         {"title", "starts_at", "ends_at", "recurrence", "until", "site_ids"}
+//	GET/PUT/DELETE /maintenance-windows/{id}
+//
+// Пустой site_ids - окно для всех мониторов пользователя. Пока окно идёт,
+// проверки пишутся в логи с пометкой maintenance и алерты не отправляются.
+func (h *Handler) MaintenanceWindowsHandler(resp http.ResponseWriter, req *http.Request) {
+	userID, err := h.verifyJWT(req)
+	if err != nil {
+		http.Error(resp, "unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	pathParts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	target := configs.DBURL + "/maintenance-windows"
+	switch len(pathParts) {
+	case 1:
+		if req.Method != http.MethodGet && req.Method != http.MethodPost {
+			http.Error(resp, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+	case 2:
+		windowID, err := strconv.Atoi(pathParts[1])
+		if err != nil {
+			http.Error(resp, "invalid maintenance window ID", http.StatusBadRequest)
+			return
+		}
+		switch req.Method {
+		case http.MethodGet, http.MethodPut, http.MethodDelete:
+		default:
+			http.Error(resp, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		target += "/" + strconv.Itoa(windowID)
+	default:
+		http.Error(resp, "invalid URL", http.StatusBadRequest)
+		return
+	}
+
+	var body io.Reader
+	if req.Method == http.MethodPost || req.Method == http.MethodPut {
+		body = req.Body
+	}
+	dbReq, err := http.NewRequest(req.Method, target+"?user_id="+strconv.Itoa(userID), body)
+	if err != nil {
+		http.Error(resp, "internal error", http.StatusInternalServerError)
+		return
+	}
+	dbReq.Header.Set("Content-Type", "application/json")
+
+	h.proxyToDB(resp, dbReq)
+	if req.Method != http.MethodGet {
+		// Остальные реплики увидят изменения через MaintenanceRefresh
+		h.maintenance.invalidate()
+		h.status.reset()
+	}
+}
+
+// maintenanceOccurrence - одно окно из ответа db_service /maintenance/active
+type maintenanceOccurrence struct {
+	UserID   int       `json:"user_id"`
+	AllSites bool      `json:"all_sites"`
+	SiteIDs  []int     `json:"site_ids"`
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+}
+
+// MaintenanceCalendar - окна обслуживания всех пользователей на ближайшее
+// время. Перечитывается из db_service не чаще раза в MaintenanceRefresh, чтобы
+// не спрашивать базу на каждой проверке. Если db_service недоступен, работает
+// по последнему загруженному расписанию.
+type MaintenanceCalendar struct {
+	mu       sync.Mutex
+	windows  []maintenanceOccurrence // заменяется целиком, поэтому читается без c.mu
+	loadedAt time.Time               // пусто - нужно перечитать
+	loading  bool                    // расписание уже перечитывает другая проверка
+	gen      int                     // растёт при invalidate: загруженное раньше расписание устарело
+}
+
+func NewMaintenanceCalendar() *MaintenanceCalendar {
+	return &MaintenanceCalendar{}
+}
+
+// Active - идёт ли у сайта окно обслуживания в момент at.
+func (c *MaintenanceCalendar) Active(userID, siteID int, at time.Time) bool {
+	now := time.Now()
+	c.mu.Lock()
+	if now.Sub(c.loadedAt) >= configs.MaintenanceRefresh && !c.loading {
+		// Перечитывает одна проверка, остальные не ждут db_service и
+		// работают по прежнему расписанию
+		c.loading = true
+		c.mu.Unlock()
+		c.reload(now)
+		c.mu.Lock()
+	}
+	windows := c.windows
+	c.mu.Unlock()
+
+	for _, w := range windows {
+		if w.UserID != userID || at.Before(w.StartsAt) || !at.Before(w.EndsAt) {
+			continue
+		}
+		if w.AllSites {
+			return true
+		}
+		for _, id := range w.SiteIDs {
+			if id == siteID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// reload загружает окна на MaintenanceLookahead в обе стороны от now: с запасом,
+// чтобы устаревшее после ошибки расписание ещё какое-то время было верным.
+// Вызывается без c.mu и только из-под флага c.loading.
+func (c *MaintenanceCalendar) reload(now time.Time) {
+	c.mu.Lock()
+	gen := c.gen
+	c.mu.Unlock()
+
+	from, to := now.Add(-configs.MaintenanceLookahead), now.Add(configs.MaintenanceLookahead)
+	windows, err := fetchMaintenance(from, to)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loading = false
+	// Следующая попытка - не раньше чем через MaintenanceRefresh, даже после ошибки,
+	// но если расписание поменяли во время загрузки - сразу
+	if gen == c.gen {
+		c.loadedAt = now
+	}
+	if err != nil {
+		configs.APILogger.Printf("load maintenance windows failed, keeping previous schedule: %v", err)
+		return
+	}
+	c.windows = windows
+}
+
+// invalidate заставляет перечитать расписание при следующей проверке.
+func (c *MaintenanceCalendar) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loadedAt = time.Time{}
+	c.gen++
+}
+
+func fetchMaintenance(from, to time.Time) ([]maintenanceOccurrence, error) {
+	params := url.Values{}
+	params.Set("from", from.UTC().Format(time.RFC3339))
+	params.Set("to", to.UTC().Format(time.RFC3339))
+
+	resp, err := configs.Client.Get(configs.DBURL + "/maintenance/active?" + params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get maintenance windows: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("db service returned status: %d", resp.StatusCode)
+	}
+
+	var windows []maintenanceOccurrence
+	if err := json.NewDecoder(resp.Body).Decode(&windows); err != nil {
+		return nil, fmt.Errorf("failed to parse maintenance windows: %v", err)
+	}
+	return windows, nil
+}
@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"api_service/configs"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// maintenanceStub - db_service, отдающий одно окно для сайта 1 пользователя 1.
+// Пока gate не закрыт, запросы к нему висят.
+func maintenanceStub(t *testing.T, gate chan struct{}) *atomic.Int32 {
+	t.Helper()
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		<-gate
+		json.NewEncoder(w).Encode([]maintenanceOccurrence{{
+			UserID: 1, SiteIDs: []int{1},
+			StartsAt: time.Now().Add(-time.Hour), EndsAt: time.Now().Add(time.Hour),
+		}})
+	}))
+	t.Cleanup(srv.Close)
+
+	useDBStub(t, srv)
+	return &hits
+}
+
+// useDBStub направляет запросы к db_service на srv. DBURL - константа,
+// поэтому подменяется клиент.
+func useDBStub(t *testing.T, srv *httptest.Server) {
+	t.Helper()
+	savedClient, savedLogger := configs.Client, configs.APILogger
+	configs.Client = &http.Client{Transport: redirectTo(srv.Listener.Addr().String())}
+	configs.APILogger = log.New(io.Discard, "", 0)
+	t.Cleanup(func() { configs.Client, configs.APILogger = savedClient, savedLogger })
+}
+
+// redirectTo отправляет все запросы на addr, сохраняя путь и параметры
+type redirectTo string
+
+func (addr redirectTo) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Host = string(addr)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// Пока одна проверка ждёт db_service, остальные работают по прежнему расписанию
+func TestMaintenanceReloadDoesNotBlockChecks(t *testing.T) {
+	gate := make(chan struct{})
+	hits := maintenanceStub(t, gate)
+	c := NewMaintenanceCalendar()
+
+	loaded := make(chan bool)
+	go func() { loaded <- c.Active(1, 1, time.Now()) }()
+	for hits.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	done := make(chan bool)
+	go func() { done <- c.Active(1, 1, time.Now()) }()
+	select {
+	case active := <-done:
+		if active {
+			t.Error("window is active before the schedule has loaded")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Active waited for the reload in another check")
+	}
+
+	close(gate)
+	if !<-loaded {
+		t.Error("window is not active after the reload")
+	}
+	if !c.Active(1, 1, time.Now()) || hits.Load() != 1 {
+		t.Errorf("schedule reloaded %d times, want once per MaintenanceRefresh", hits.Load())
+	}
+}
+
+// Окно, созданное во время загрузки, не должно ждать следующего MaintenanceRefresh
+func TestMaintenanceInvalidateDuringReload(t *testing.T) {
+	gate := make(chan struct{})
+	hits := maintenanceStub(t, gate)
+	c := NewMaintenanceCalendar()
+
+	loaded := make(chan struct{})
+	go func() {
+		c.Active(1, 1, time.Now())
+		close(loaded)
+	}()
+	for hits.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	c.invalidate()
+	close(gate)
+	<-loaded
+
+	c.Active(1, 1, time.Now())
+	if n := hits.Load(); n != 2 {
+		t.Errorf("schedule loaded %d times, want a reload after invalidate", n)
+	}
+}
@@ -0,0 +1,228 @@
+package internal
+
+import (
+	"api_service/models"
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CheckJob - одна проверка сайта конкретного пользователя.
+type CheckJob struct {
+	UserID int
+	Site   models.Site
+}
+
+type checkFunc func(job CheckJob) (*PingResult, error)
+
+// Pool - ограниченный пул воркеров для проверок сайтов.
+//
+// Одновременно выполняется не больше workers проверок, а к одному хосту -
+// не больше perHost запросов с паузой не меньше hostDelay между стартами,
+// чтобы не устраивать нагрузку на сайты, которые пользователи добавили по
+// нескольку раз с разными путями. Слот хоста проверка получает до того, как
+// попасть в очередь: воркер берёт только готовые к запуску проверки и не
+// простаивает в ожидании занятого хоста, пока другие хосты ждут в очереди.
+type Pool struct {
+	check checkFunc
+	queue chan poolTask
+	hosts *hostLimiter
+}
+
+type poolTask struct {
+	job    CheckJob
+	result chan poolResult
+}
+
+type poolResult struct {
+	res *PingResult
+	err error
+}
+
+// RunSummary - итог прогона пачки проверок.
+type RunSummary struct {
+	StartedAt   time.Time `json:"started_at"`
+	DurationMs  int64     `json:"duration_ms"`
+	Total       int       `json:"total"`
+	Successful  int       `json:"successful"`
+	Failed      int       `json:"failed"`
+	Cancelled   int       `json:"cancelled"`    // проверки, не начатые из-за отмены или таймаута прогона
+	SkippedRuns int       `json:"skipped_runs"` // запуски, отброшенные из-за незавершённого предыдущего
+}
+
+func NewPool(workers, perHost int, hostDelay time.Duration, check checkFunc) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	p := &Pool{
+		check: check,
+		queue: make(chan poolTask),
+		hosts: newHostLimiter(perHost, hostDelay),
+	}
+	for i := 0; i < workers; i++ {
+		go p.worker()
+	}
+	return p
+}
+
+func (p *Pool) worker() {
+	for task := range p.queue {
+		res, err := p.check(task.job)
+		task.result <- poolResult{res: res, err: err}
+	}
+}
+
+// Do ждёт слот хоста, ставит проверку в очередь пула и ждёт её результата.
+// Если ctx отменён раньше, чем проверку взял воркер, она не выполняется;
+// уже начатая проверка доводится до конца.
+func (p *Pool) Do(ctx context.Context, job CheckJob) (*PingResult, error) {
+	host := hostOf(job.Site.URL)
+	if err := p.hosts.acquire(ctx, host); err != nil {
+		return nil, err
+	}
+	defer p.hosts.release(host)
+
+	task := poolTask{job: job, result: make(chan poolResult, 1)}
+	select {
+	case p.queue <- task:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	r := <-task.result
+	return r.res, r.err
+}
+
+// Run прогоняет все проверки через пул и возвращает сводку.
+// После отмены ctx оставшиеся в очереди проверки считаются в Cancelled.
+func (p *Pool) Run(ctx context.Context, jobs []CheckJob) RunSummary {
+	summary := RunSummary{StartedAt: time.Now().UTC(), Total: len(jobs)}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		wg.Add(1)
+		go func(job CheckJob) {
+			defer wg.Done()
+			res, err := p.Do(ctx, job)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err != nil && err == ctx.Err():
+				summary.Cancelled++
+			case err != nil || res.Status == "bad":
+				summary.Failed++
+			default:
+				summary.Successful++
+			}
+		}(job)
+	}
+	wg.Wait()
+
+	summary.DurationMs = time.Since(summary.StartedAt).Milliseconds()
+	return summary
+}
+
+// hostLimiter ограничивает параллелизм и частоту запросов к одному хосту.
+type hostLimiter struct {
+	perHost int
+	delay   time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+type hostState struct {
+	slots     chan struct{}
+	lastStart time.Time
+	users     int // сколько воркеров сейчас держат или ждут слот
+}
+
+func newHostLimiter(perHost int, delay time.Duration) *hostLimiter {
+	if perHost < 1 {
+		perHost = 1
+	}
+	return &hostLimiter{
+		perHost: perHost,
+		delay:   delay,
+		hosts:   map[string]*hostState{},
+	}
+}
+
+// acquire занимает слот хоста и выдерживает паузу после предыдущего старта.
+// При отмене ctx возвращает ctx.Err() и слот не держит.
+func (l *hostLimiter) acquire(ctx context.Context, host string) error {
+	l.mu.Lock()
+	st, ok := l.hosts[host]
+	if !ok {
+		st = &hostState{slots: make(chan struct{}, l.perHost)}
+		l.hosts[host] = st
+	}
+	st.users++
+	l.mu.Unlock()
+
+	select {
+	case st.slots <- struct{}{}:
+	case <-ctx.Done():
+		l.leave(host, st, false)
+		return ctx.Err()
+	}
+
+	// Выдерживаем паузу между стартами запросов к одному хосту
+	for {
+		l.mu.Lock()
+		wait := st.lastStart.Add(l.delay).Sub(time.Now())
+		if wait <= 0 {
+			st.lastStart = time.Now()
+			l.mu.Unlock()
+			return nil
+		}
+		l.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			l.leave(host, st, true)
+			return ctx.Err()
+		}
+	}
+}
+
+func (l *hostLimiter) release(host string) {
+	l.mu.Lock()
+	st := l.hosts[host]
+	l.mu.Unlock()
+	l.leave(host, st, true)
+}
+
+// leave освобождает слот (если он был занят) и забывает хост, когда он никому не нужен.
+func (l *hostLimiter) leave(host string, st *hostState, holdsSlot bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if holdsSlot {
+		<-st.slots
+	}
+	st.users--
+	// Не копим состояние по хостам, которые больше никто не проверяет
+	if st.users == 0 && time.Since(st.lastStart) >= l.delay {
+		delete(l.hosts, host)
+	}
+}
+
+// hostOf достаёт хост из адреса сайта; адрес может быть без схемы.
+func hostOf(site string) string {
+	raw := site
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return site
+	}
+	return strings.ToLower(u.Hostname())
+}
@@ -0,0 +1,116 @@
+package internal
+
+import (
+	"api_service/models"
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Проверки горячего хоста упираются в perHost, но не должны занимать
+// воркеры, пока проверки других хостов стоят в очереди
+func TestPoolHotHostDoesNotBlockOthers(t *testing.T) {
+	const hotJobs = 8
+
+	release := make(chan struct{})
+	var hotRunning, hotMax atomic.Int32
+	pool := NewPool(4, 1, 0, func(job CheckJob) (*PingResult, error) {
+		if hostOf(job.Site.URL) == "hot.test" {
+			n := hotRunning.Add(1)
+			defer hotRunning.Add(-1)
+			for {
+				max := hotMax.Load()
+				if n <= max || hotMax.CompareAndSwap(max, n) {
+					break
+				}
+			}
+			<-release
+		}
+		return &PingResult{Status: "good"}, nil
+	})
+
+	var hot sync.WaitGroup
+	for i := 0; i < hotJobs; i++ {
+		hot.Add(1)
+		go func(i int) {
+			defer hot.Done()
+			pool.Do(context.Background(), CheckJob{Site: models.Site{URL: fmt.Sprintf("https://hot.test/page%d", i)}})
+		}(i)
+	}
+	// Даём горячим проверкам разойтись по пулу
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	cold := []CheckJob{
+		{Site: models.Site{URL: "https://a.test"}},
+		{Site: models.Site{URL: "b.test/health"}},
+		{Site: models.Site{URL: "http://c.test:8080"}},
+	}
+	summary := pool.Run(ctx, cold)
+	if summary.Successful != len(cold) {
+		t.Errorf("cold hosts: %+v, want all %d successful while the hot host is busy", summary, len(cold))
+	}
+
+	close(release)
+	hot.Wait()
+	if n := hotMax.Load(); n != 1 {
+		t.Errorf("hot host ran %d checks at once, perHost is 1", n)
+	}
+}
+
+func TestPoolHostDelay(t *testing.T) {
+	const delay = 30 * time.Millisecond
+
+	var mu sync.Mutex
+	var starts []time.Time
+	pool := NewPool(4, 4, delay, func(job CheckJob) (*PingResult, error) {
+		mu.Lock()
+		starts = append(starts, time.Now())
+		mu.Unlock()
+		return &PingResult{Status: "good"}, nil
+	})
+
+	jobs := make([]CheckJob, 4)
+	for i := range jobs {
+		jobs[i] = CheckJob{Site: models.Site{URL: fmt.Sprintf("https://slow.test/%d", i)}}
+	}
+	summary := pool.Run(context.Background(), jobs)
+	if summary.Successful != len(jobs) {
+		t.Fatalf("summary = %+v", summary)
+	}
+
+	// Разброс планировщика - пара миллисекунд, пауза почти целиком должна сохраниться
+	for i := 1; i < len(starts); i++ {
+		if gap := starts[i].Sub(starts[i-1]); gap < delay-5*time.Millisecond {
+			t.Errorf("gap between starts %d and %d = %v, want about %v", i-1, i, gap, delay)
+		}
+	}
+}
+
+func TestPoolCancelledWhileWaitingForHost(t *testing.T) {
+	release := make(chan struct{})
+	pool := NewPool(2, 1, 0, func(job CheckJob) (*PingResult, error) {
+		<-release
+		return &PingResult{Status: "good"}, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	jobs := []CheckJob{
+		{Site: models.Site{URL: "https://busy.test/1"}},
+		{Site: models.Site{URL: "https://busy.test/2"}},
+		{Site: models.Site{URL: "https://busy.test/3"}},
+	}
+	go func() {
+		<-ctx.Done()
+		close(release)
+	}()
+	summary := pool.Run(ctx, jobs)
+	if summary.Successful != 1 || summary.Cancelled != 2 {
+		t.Errorf("summary = %+v, want 1 successful and 2 cancelled", summary)
+	}
+}
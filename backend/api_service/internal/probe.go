@@ -0,0 +1,112 @@
+package internal
+
+import (
+	"api_service/configs"
+	"api_service/models"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// regionResult - ответ одного агента ping_service
+type regionResult struct {
+	agent  configs.PingAgent
+	result *PingResult
+	err    error // агент недоступен или отказал; о сайте это ничего не говорит
+}
+
+// probeAgents выбирает агентов для проверки сайта: PROBE_FANOUT штук подряд
+// со сдвигом по ID сайта, чтобы при неполном fan-out нагрузка делилась между
+// агентами, а сайт всегда проверялся из одних и тех же регионов.
+func probeAgents(siteID int) []configs.PingAgent {
+	agents := configs.PingAgents
+	n := configs.ProbeFanout
+	if n <= 0 || n > len(agents) {
+		n = len(agents)
+	}
+	start := siteID % len(agents)
+	if start < 0 {
+		start = -start
+	}
+
+	out := make([]configs.PingAgent, 0, n)
+	for i := 0; i < n; i++ {
+		out = append(out, agents[(start+i)%len(agents)])
+	}
+	return out
+}
+
+// probeQuorum - сколько регионов из n должны согласиться, что сайт недоступен.
+func probeQuorum(n int) int {
+	q := configs.ProbeQuorum
+	if q <= 0 {
+		q = n/2 + 1
+	}
+	if q > n {
+		q = n
+	}
+	return q
+}
+
+// probeSite отправляет проверку выбранным агентам параллельно и сводит ответы.
+// Неудача засчитывается, только если её увидели не меньше quorum регионов:
+// сбой сети у одного агента не выглядит как падение всех сайтов. Если ответило
+// меньше агентов, чем нужно для кворума, итога нет и возвращается ошибка.
+// Ответы регионов возвращаются в порядке агентов, в том числе при ошибке.
+func (h *Handler) probeSite(site models.Site) ([]regionResult, *PingResult, error) {
+	agents := probeAgents(site.ID)
+	results := make([]regionResult, len(agents))
+	// Общее время проверки: по нему статистика собирает ответы регионов в одну проверку
+	checkedAt := time.Now().UTC()
+
+	var wg sync.WaitGroup
+	for i, agent := range agents {
+		wg.Add(1)
+		go func(i int, agent configs.PingAgent) {
+			defer wg.Done()
+			res, err := h.pingSite(agent, site)
+			if res != nil {
+				res.CheckedAt = checkedAt
+			}
+			results[i] = regionResult{agent: agent, result: res, err: err}
+		}(i, agent)
+	}
+	wg.Wait()
+
+	quorum := probeQuorum(len(agents))
+	var answered int
+	var failedRegions, agentErrors []string
+	for _, r := range results {
+		if r.err != nil {
+			agentErrors = append(agentErrors, fmt.Sprintf("%s: %v", r.agent.Region, r.err))
+			continue
+		}
+		answered++
+		if r.result.Status == "bad" {
+			failedRegions = append(failedRegions, r.agent.Region)
+		}
+	}
+
+	if answered < quorum {
+		return results, nil, fmt.Errorf("only %d of %d regions answered, quorum is %d: %s",
+			answered, len(agents), quorum, strings.Join(agentErrors, "; "))
+	}
+
+	status := "ok"
+	if len(failedRegions) >= quorum {
+		status = "bad"
+	} else if len(failedRegions) > 0 {
+		configs.APILogger.Printf("site %d (%s) failed in %s, below quorum %d of %d",
+			site.ID, site.URL, strings.Join(failedRegions, ","), quorum, len(agents))
+	}
+
+	// Итог представляет первый по порядку регион, согласный с решением:
+	// так сертификат и DNS-записи для алертов берутся всегда из одного места
+	for _, r := range results {
+		if r.err == nil && r.result.Status == status {
+			return results, r.result, nil
+		}
+	}
+	return results, nil, fmt.Errorf("no region result for status %s", status)
+}
@@ -0,0 +1,199 @@
+package internal
+
+import (
+	"api_service/configs"
+	"api_service/models"
+	"context"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Scheduler запускает проверку каждого сайта с его собственным check_interval.
+//
+// Для каждого сайта хранится время следующего запуска. Моменты запуска
+// привязаны к детерминированной фазе внутри интервала (хэш от ID сайта),
+// поэтому после рестарта расписание восстанавливается само, а сайты
+// с одинаковым интервалом не пингуются все в одну секунду.
+type Scheduler struct {
+	h *Handler
+
+	mu      sync.Mutex
+	entries map[int]*scheduleEntry // ключ - ID сайта
+
+	stop chan struct{}
+}
+
+type scheduleEntry struct {
+	userID  int
+	site    models.Site
+	nextRun time.Time
+	running bool
+	overdue bool // уже сообщили в лог, что проверка не успевает за интервалом
+}
+
+func NewScheduler(h *Handler) *Scheduler {
+	return &Scheduler{
+		h:       h,
+		entries: map[int]*scheduleEntry{},
+		stop:    make(chan struct{}),
+	}
+}
+
+// Start запускает цикл планировщика в отдельной горутине.
+func (s *Scheduler) Start() {
+	go s.loop()
+}
+
+func (s *Scheduler) Stop() {
+	close(s.stop)
+}
+
+func (s *Scheduler) loop() {
+	s.refresh(time.Now())
+
+	tick := time.NewTicker(configs.SchedulerTick)
+	defer tick.Stop()
+	refresh := time.NewTicker(configs.SitesRefreshInterval)
+	defer refresh.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-refresh.C:
+			s.refresh(now)
+		case now := <-tick.C:
+			s.dispatchDue(now)
+		}
+	}
+}
+
+// refresh синхронизирует расписание со списком сайтов в db_service:
+// добавляет новые сайты, убирает удалённые и пересчитывает фазу при смене интервала.
+func (s *Scheduler) refresh(now time.Time) {
+	usersSites, err := s.h.getAllUsersSites()
+	if err != nil {
+		configs.APILogger.Println("scheduler: refresh sites failed:", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[int]bool)
+	for _, us := range usersSites {
+		for _, site := range us.Sites {
+			if site.Type == models.TypeHeartbeat {
+				continue // сигналы приходят сами, их проверяет HeartbeatSweeper
+			}
+			seen[site.ID] = true
+
+			e, ok := s.entries[site.ID]
+			if !ok {
+				s.entries[site.ID] = &scheduleEntry{
+					userID:  us.UserID,
+					site:    site,
+					nextRun: nextSlot(site.ID, checkInterval(site), now),
+				}
+				continue
+			}
+
+			intervalChanged := checkInterval(e.site) != checkInterval(site)
+			e.userID = us.UserID
+			e.site = site
+			if intervalChanged {
+				e.nextRun = nextSlot(site.ID, checkInterval(site), now)
+			}
+		}
+	}
+
+	for id := range s.entries {
+		if !seen[id] {
+			delete(s.entries, id)
+		}
+	}
+}
+
+// dispatchDue отправляет в пул проверки сайтов, у которых наступило время.
+// Сайт, чья предыдущая проверка ещё не закончилась, пропускается.
+// Проверки запускает только лидер; ведомая реплика лишь сдвигает расписание,
+// чтобы после перехода лидерства не проверять разом все пропущенные сайты.
+func (s *Scheduler) dispatchDue(now time.Time) {
+	leader := s.h.leader.IsLeader()
+
+	s.mu.Lock()
+	var due []*scheduleEntry
+	for _, e := range s.entries {
+		if now.Before(e.nextRun) {
+			continue
+		}
+		if !leader {
+			e.nextRun = nextSlot(e.site.ID, checkInterval(e.site), now.Add(time.Millisecond))
+			continue
+		}
+		if e.running {
+			if !e.overdue {
+				e.overdue = true
+				configs.APILogger.Printf("scheduler: site %d (%s) is due but previous check is still running", e.site.ID, e.site.URL)
+			}
+			continue
+		}
+		e.running = true
+		due = append(due, e)
+	}
+	s.mu.Unlock()
+
+	for _, e := range due {
+		go s.run(e)
+	}
+}
+
+func (s *Scheduler) run(e *scheduleEntry) {
+	s.mu.Lock()
+	userID, site := e.userID, e.site
+	s.mu.Unlock()
+
+	if _, err := s.h.pool.Do(context.Background(), CheckJob{UserID: userID, Site: site}); err != nil {
+		configs.APILogger.Printf("scheduler: check site %d (%s) failed: %v", site.ID, site.URL, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.running = false
+	e.overdue = false
+
+	interval := checkInterval(e.site)
+	e.nextRun = e.nextRun.Add(interval)
+	if now := time.Now(); e.nextRun.Before(now) {
+		// Проверка заняла больше интервала - не догоняем пропущенные слоты
+		e.nextRun = nextSlot(e.site.ID, interval, now)
+	}
+}
+
+// checkInterval возвращает интервал проверки сайта с учётом значения по умолчанию и минимума.
+func checkInterval(site models.Site) time.Duration {
+	sec := site.CheckInterval
+	if sec <= 0 {
+		sec = configs.DefaultCheckInterval
+	}
+	if sec < configs.MinCheckInterval {
+		sec = configs.MinCheckInterval
+	}
+	return time.Duration(sec) * time.Second
+}
+
+// nextSlot возвращает ближайший момент не раньше now, попадающий в фазу сайта:
+// начало интервала + смещение, детерминированно вычисленное по ID сайта.
+func nextSlot(siteID int, interval time.Duration, now time.Time) time.Time {
+	h := fnv.New32a()
+	h.Write([]byte(strconv.Itoa(siteID)))
+	offset := time.Duration(int64(h.Sum32())%interval.Milliseconds()) * time.Millisecond
+
+	slot := now.Truncate(interval).Add(offset)
+	if slot.Before(now) {
+		slot = slot.Add(interval)
+	}
+	return slot
+}
@@ -0,0 +1,109 @@
+package internal
+
+import (
+	"api_service/configs"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// getSiteStats отдаёт uptime, инциденты, MTTR/MTBF и перцентили времени ответа
+// сайта: GET /checker/{id}/stats?window=24h|7d|30d или ?from=&to= (RFC3339).
+// Считает db_service агрегатами ClickHouse по ping_logs. Проверки в окнах
+// обслуживания не учитываются, если не передан include_maintenance=true.
+func (h *Handler) getSiteStats(resp http.ResponseWriter, req *http.Request, userID, siteID int) {
+	query := req.URL.Query()
+	from, to, err := statsWindow(query, time.Now().UTC())
+	if err != nil {
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	params := url.Values{}
+	params.Set("user_id", strconv.Itoa(userID))
+	params.Set("from", from.Format(time.RFC3339))
+	params.Set("to", to.Format(time.RFC3339))
+	if v := query.Get("include_maintenance"); v != "" {
+		params.Set("include_maintenance", v)
+	}
+	if region := query.Get("region"); region != "" {
+		// В одном регионе неудачная проверка - это просто неудачный ответ агента
+		params.Set("region", region)
+	} else {
+		params.Set("quorum", strconv.Itoa(probeQuorum(len(probeAgents(siteID)))))
+	}
+
+	dbReq, err := http.NewRequest(http.MethodGet,
+		fmt.Sprintf("%s/stats/%d?%s", configs.DBURL, siteID, params.Encode()), nil)
+	if err != nil {
+		http.Error(resp, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	h.proxyToDB(resp, dbReq)
+}
+
+// statsWindow разбирает окно статистики: window - длительность вида 24h, 7d
+// или 30d, отсчитанная назад от to (по умолчанию сейчас); либо явные from и to.
+// Без параметров - последние 24 часа.
+func statsWindow(query url.Values, now time.Time) (time.Time, time.Time, error) {
+	to := now
+	if v := query.Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("to must be RFC3339")
+		}
+		to = t.UTC()
+	}
+
+	var from time.Time
+	switch {
+	case query.Get("from") != "":
+		if query.Get("window") != "" {
+			return time.Time{}, time.Time{}, errors.New("use either window or from/to")
+		}
+		t, err := time.Parse(time.RFC3339, query.Get("from"))
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("from must be RFC3339")
+		}
+		from = t.UTC()
+	default:
+		window := query.Get("window")
+		if window == "" {
+			window = "24h"
+		}
+		d, err := parseWindow(window)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		from = to.Add(-d)
+	}
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, errors.New("from must be before to")
+	}
+	if to.Sub(from) > configs.MaxStatsWindow {
+		return time.Time{}, time.Time{}, fmt.Errorf("window must be at most %d days", int(configs.MaxStatsWindow.Hours()/24))
+	}
+	return from, to, nil
+}
+
+// parseWindow понимает дни ("7d") и всё, что понимает time.ParseDuration ("24h", "90m").
+func parseWindow(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid window %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid window %q", s)
+	}
+	return d, nil
+}
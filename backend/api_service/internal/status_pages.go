@@ -0,0 +1,325 @@
+package internal
+
+import (
+	"api_service/configs"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StatusPagesHandler - управление страницами статуса пользователя:
+//
+//	GET  /status-pages
+//	POST /status-pages                {"title", "sites": [{"site_id", "label"}]}
+//	GET/PUT/PATCH/DELETE /status-pages/{id}
+//
+// Публичный адрес страницы - /status/{slug}; сайты проверяет db_service:
+// добавить на страницу можно только свои.
+func (h *Handler) StatusPagesHandler(resp http.ResponseWriter, req *http.Request) {
+	userID, err := h.verifyJWT(req)
+	if err != nil {
+		http.Error(resp, "unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	pathParts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	target := configs.DBURL + "/status-pages"
+	switch len(pathParts) {
+	case 1:
+		if req.Method != http.MethodGet && req.Method != http.MethodPost {
+			http.Error(resp, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+	case 2:
+		pageID, err := strconv.Atoi(pathParts[1])
+		if err != nil {
+			http.Error(resp, "invalid status page ID", http.StatusBadRequest)
+			return
+		}
+		switch req.Method {
+		case http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			http.Error(resp, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		target += "/" + strconv.Itoa(pageID)
+	default:
+		http.Error(resp, "invalid URL", http.StatusBadRequest)
+		return
+	}
+
+	var body io.Reader
+	if req.Method == http.MethodPost || req.Method == http.MethodPut || req.Method == http.MethodPatch {
+		body = req.Body
+	}
+	dbReq, err := http.NewRequest(req.Method, target+"?user_id="+strconv.Itoa(userID), body)
+	if err != nil {
+		http.Error(resp, "internal error", http.StatusInternalServerError)
+		return
+	}
+	dbReq.Header.Set("Content-Type", "application/json")
+
+	h.proxyToDB(resp, dbReq)
+	if req.Method != http.MethodGet {
+		// Изменения видны на публичной странице сразу, по крайней мере на этой реплике
+		h.status.reset()
+	}
+}
+
+// PublicStatusHandler - публичная страница статуса, без авторизации:
+// GET /status/{slug} - HTML, GET /status/{slug}.json - те же данные в JSON.
+// Страница показывает только то, что выбрал её владелец; ответы кэшируются
+// на StatusPageCacheTTL, чтобы посетители не нагружали ClickHouse.
+func (h *Handler) PublicStatusHandler(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(resp, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	slug, asJSON := strings.CutSuffix(strings.TrimPrefix(req.URL.Path, "/status/"), ".json")
+	if slug == "" || strings.Contains(slug, "/") {
+		http.NotFound(resp, req)
+		return
+	}
+
+	data, err := h.status.get(slug)
+	if err == errStatusPageNotFound {
+		http.NotFound(resp, req)
+		return
+	}
+	if err != nil {
+		configs.APILogger.Printf("status page %s failed: %v", slug, err)
+		http.Error(resp, "status temporarily unavailable", http.StatusBadGateway)
+		return
+	}
+
+	resp.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(configs.StatusPageCacheTTL.Seconds())))
+	if asJSON {
+		resp.Header().Set("Content-Type", "application/json")
+		resp.Write(data)
+		return
+	}
+
+	var page struct {
+		publicStatus
+		Slug string
+	}
+	if err := json.Unmarshal(data, &page.publicStatus); err != nil {
+		configs.APILogger.Printf("status page %s: failed to parse status: %v", slug, err)
+		http.Error(resp, "internal error", http.StatusInternalServerError)
+		return
+	}
+	page.Slug = slug
+
+	resp.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := statusPageTemplate.Execute(resp, page); err != nil {
+		configs.APILogger.Printf("status page %s: render failed: %v", slug, err)
+	}
+}
+
+// publicStatus - ответ db_service /public-status/{slug}, нужен только для HTML
+type publicStatus struct {
+	Title     string    `json:"title"`
+	Status    string    `json:"status"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Monitors  []struct {
+		Name      string   `json:"name"`
+		Status    string   `json:"status"`
+		UptimePct *float64 `json:"uptime_pct"`
+		Days      []struct {
+			Date      string   `json:"date"`
+			UptimePct *float64 `json:"uptime_pct"`
+		} `json:"days"`
+	} `json:"monitors"`
+	Incidents []struct {
+		ID       int       `json:"id"`
+		Monitor  string    `json:"monitor"`
+		Status   string    `json:"status"`
+		OpenedAt time.Time `json:"opened_at"`
+	} `json:"incidents"`
+}
+
+var errStatusPageNotFound = errors.New("status page not found")
+
+// statusCache хранит ответы db_service для публичных страниц по slug.
+// Несуществующие страницы не кэшируются, поэтому перебор адресов не
+// раздувает кэш.
+type statusCache struct {
+	mu    sync.Mutex
+	pages map[string]cachedStatus
+}
+
+type cachedStatus struct {
+	data    []byte
+	fetched time.Time
+}
+
+func newStatusCache() *statusCache {
+	return &statusCache{pages: map[string]cachedStatus{}}
+}
+
+func (c *statusCache) get(slug string) ([]byte, error) {
+	now := time.Now()
+	c.mu.Lock()
+	cached, ok := c.pages[slug]
+	c.mu.Unlock()
+	if ok && now.Sub(cached.fetched) < configs.StatusPageCacheTTL {
+		return cached.data, nil
+	}
+
+	data, err := fetchPublicStatus(slug)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for s, p := range c.pages {
+		if now.Sub(p.fetched) >= configs.StatusPageCacheTTL {
+			delete(c.pages, s)
+		}
+	}
+	c.pages[slug] = cachedStatus{data: data, fetched: now}
+	return data, nil
+}
+
+func (c *statusCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pages = map[string]cachedStatus{}
+}
+
+func fetchPublicStatus(slug string) ([]byte, error) {
+	params := url.Values{}
+	params.Set("days", strconv.Itoa(configs.StatusPageDays))
+	// Кворум одинаков для всех сайтов: он зависит только от числа агентов
+	params.Set("quorum", strconv.Itoa(probeQuorum(len(probeAgents(0)))))
+
+	resp, err := configs.Client.Get(configs.DBURL + "/public-status/" + url.PathEscape(slug) + "?" + params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errStatusPageNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("db service returned status: %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+var statusPageTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"pageText": func(status string) string {
+		switch status {
+		case "major_outage":
+			return "Все сервисы недоступны"
+		case "partial_outage":
+			return "Часть сервисов недоступна"
+		case "maintenance":
+			return "Идут плановые работы"
+		default:
+			return "Все сервисы работают"
+		}
+	},
+	"monitorText": func(status string) string {
+		switch status {
+		case "up":
+			return "Работает"
+		case "down":
+			return "Недоступен"
+		case "maintenance":
+			return "Плановые работы"
+		default:
+			return "Нет данных"
+		}
+	},
+	"barClass": func(pct *float64) string {
+		switch {
+		case pct == nil:
+			return "none"
+		case *pct >= 99.9:
+			return "ok"
+		case *pct >= 95:
+			return "warn"
+		default:
+			return "bad"
+		}
+	},
+	"pct": func(pct *float64) string {
+		if pct == nil {
+			return "нет данных"
+		}
+		return fmt.Sprintf("%.2f%%", *pct)
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{.Title}}</title>
+    <style>
+        body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; margin: 0; padding: 20px; background-color: #f5f5f5; color: #212529; }
+        .container { max-width: 800px; margin: 0 auto; }
+        h1 { font-size: 28px; }
+        .banner { color: white; padding: 16px 20px; border-radius: 8px; font-size: 18px; font-weight: bold; margin-bottom: 20px; }
+        .banner.operational { background-color: #28a745; }
+        .banner.partial_outage { background-color: #fd7e14; }
+        .banner.major_outage { background-color: #dc3545; }
+        .banner.maintenance { background-color: #007bff; }
+        .card { background-color: #ffffff; border-radius: 8px; padding: 20px; margin-bottom: 16px; box-shadow: 0 4px 6px rgba(0, 0, 0, 0.1); }
+        .incident { border-left: 4px solid #dc3545; }
+        .row { display: flex; justify-content: space-between; margin-bottom: 10px; }
+        .name { font-weight: bold; }
+        .state.up { color: #28a745; }
+        .state.down { color: #dc3545; }
+        .state.maintenance { color: #007bff; }
+        .state.unknown { color: #6c757d; }
+        .bars { display: flex; gap: 2px; height: 34px; }
+        .bar { flex: 1; border-radius: 2px; }
+        .bar.ok { background-color: #28a745; }
+        .bar.warn { background-color: #fd7e14; }
+        .bar.bad { background-color: #dc3545; }
+        .bar.none { background-color: #dee2e6; }
+        .uptime, .footer { color: #6c757d; font-size: 14px; margin-top: 8px; }
+        .footer { text-align: center; margin-top: 24px; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <h1>{{.Title}}</h1>
+        <div class="banner {{.Status}}">{{pageText .Status}}</div>
+        {{range .Incidents}}
+        <div class="card incident">
+            <div class="row">
+                <span class="name">Инцидент #{{.ID}}: {{.Monitor}}</span>
+                <span>{{if eq .Status "acknowledged"}}Команда работает над проблемой{{else}}Выясняем причину{{end}}</span>
+            </div>
+            <div class="uptime">Начался {{.OpenedAt.Format "02.01.2006 15:04"}} UTC</div>
+        </div>
+        {{end}}
+        {{range .Monitors}}
+        <div class="card">
+            <div class="row">
+                <span class="name">{{.Name}}</span>
+                <span class="state {{.Status}}">{{monitorText .Status}}</span>
+            </div>
+            <div class="bars">{{range .Days}}<span class="bar {{barClass .UptimePct}}" title="{{.Date}}: {{pct .UptimePct}}"></span>{{end}}</div>
+            <div class="uptime">Аптайм за {{len .Days}} дн.: {{pct .UptimePct}}</div>
+        </div>
+        {{end}}
+        <div class="footer">
+            Обновлено {{.UpdatedAt.Format "02.01.2006 15:04"}} UTC · <a href="{{.Slug}}.json">JSON</a> · PingTower
+        </div>
+    </div>
+</body>
+</html>
+`))
@@ -1,5 +1,10 @@
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type AuthReq struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
@@ -16,19 +21,61 @@ type Site struct {
 	ID            int    `json:"id"`
 	URL           string `json:"url"` // ВАЖНО: именно "url"
 	CheckInterval int    `json:"check_interval"`
+	// Сайт считается упавшим после FailureThreshold неудач подряд
+	// или WindowFailures неудач среди последних WindowSize проверок (0 - не используется)
+	FailureThreshold int         `json:"failure_threshold"`
+	WindowSize       int         `json:"window_size"`
+	WindowFailures   int         `json:"window_failures"`
+	Assertions       *Assertions `json:"assertions,omitempty"`
+	// HTTP-запрос проверки; пустой ExpectedStatus - успехом считается любой код < 400
+	Method         string            `json:"method,omitempty"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	Body           string            `json:"body,omitempty"`
+	ExpectedStatus []int             `json:"expected_status,omitempty"`
+	// Тип проверки: http (по умолчанию), tcp, icmp или dns; для tcp URL - это host:port, для icmp и dns - хост
+	Type       string `json:"type,omitempty"`
+	TimeoutMs  int    `json:"timeout_ms,omitempty"`
+	Banner     string `json:"banner,omitempty"`
+	Count      int    `json:"count,omitempty"`       // icmp: echo-запросов в серии
+	IntervalMs int    `json:"interval_ms,omitempty"` // icmp: пауза между запросами
+	// dns: тип записи (A, AAAA, CNAME, MX, TXT), резолвер host:port и ожидаемый набор записей
+	RecordType string   `json:"record_type,omitempty"`
+	Resolver   string   `json:"resolver,omitempty"`
+	Expected   []string `json:"expected,omitempty"`
+	// heartbeat: токен push-URL /heartbeat/{token}, допустимое опоздание (сек) и последний сигнал
+	HeartbeatToken  string     `json:"heartbeat_token,omitempty"`
+	GracePeriod     int        `json:"grace_period,omitempty"`
+	LastHeartbeatAt *time.Time `json:"last_heartbeat_at,omitempty"`
+	Steps           []Step     `json:"steps,omitempty"` // multistep: шаги по порядку
 }
 
+// Типы проверок
+const (
+	TypeHTTP      = "http"
+	TypeTCP       = "tcp"
+	TypeICMP      = "icmp"
+	TypeDNS       = "dns"
+	TypeHeartbeat = "heartbeat" // не пингуется: задача сама присылает сигнал раз в check_interval
+	TypeMultistep = "multistep" // цепочка HTTP-шагов с переменными между ними
+)
+
 type UserSites struct {
 	UserID int    `json:"user_id"`
 	Sites  []Site `json:"sites"`
 }
 
 type PingLog struct {
-	ID       int    `json:"id"` // ← добавь это поле
-	ReqTime  string `json:"req_time"`
-	RespTime int64  `json:"resp_time"`
-	Status   string `json:"status"`
-	Site     string `json:"site"`
+	ID        int          `json:"id"` // ← добавь это поле
+	ReqTime   string       `json:"req_time"`
+	RespTime  int64        `json:"resp_time"`
+	Status    string       `json:"status"`
+	Site      string       `json:"site"`
+	Timings                // dns_ms, connect_ms, ... на одном уровне с остальными полями
+	ICMPStats              // packets_sent, loss_pct, ... (для icmp-проверок)
+	Steps     []StepResult `json:"steps,omitempty"`  // для multistep-проверок
+	Region    string       `json:"region,omitempty"` // регион агента ping_service
+	// Проверка пришлась на окно обслуживания
+	Maintenance bool `json:"maintenance,omitempty"`
 }
 
 type CheckerRequest struct {
@@ -36,22 +83,217 @@ type CheckerRequest struct {
 }
 
 type AddSiteRequest struct {
-	UserID int    `json:"user_id"`
-	Site   string `json:"site"`
-	Time   int    `json:"time,omitempty"`
+	UserID           int               `json:"user_id"`
+	Site             string            `json:"site"`
+	Time             int               `json:"time,omitempty"`
+	FailureThreshold int               `json:"failure_threshold,omitempty"`
+	WindowSize       int               `json:"window_size,omitempty"`
+	WindowFailures   int               `json:"window_failures,omitempty"`
+	Assertions       *Assertions       `json:"assertions,omitempty"`
+	Method           string            `json:"method,omitempty"`
+	Headers          map[string]string `json:"headers,omitempty"`
+	Body             string            `json:"body,omitempty"`
+	ExpectedStatus   []int             `json:"expected_status,omitempty"`
+	Type             string            `json:"type,omitempty"`
+	TimeoutMs        int               `json:"timeout_ms,omitempty"`
+	Banner           string            `json:"banner,omitempty"`
+	Count            int               `json:"count,omitempty"`
+	IntervalMs       int               `json:"interval_ms,omitempty"`
+	RecordType       string            `json:"record_type,omitempty"`
+	Resolver         string            `json:"resolver,omitempty"`
+	Expected         []string          `json:"expected,omitempty"`
+	GracePeriod      int               `json:"grace_period,omitempty"`
+	Steps            []Step            `json:"steps,omitempty"`
+}
+
+// UpdateSiteRequest - тело PUT/PATCH /checker/{id}; nil-поля не меняются
+type UpdateSiteRequest struct {
+	Site             *string     `json:"site,omitempty"`
+	Time             *int        `json:"time,omitempty"`
+	FailureThreshold *int        `json:"failure_threshold,omitempty"`
+	WindowSize       *int        `json:"window_size,omitempty"`
+	WindowFailures   *int        `json:"window_failures,omitempty"`
+	Assertions       *Assertions `json:"assertions,omitempty"` // {} - убрать все проверки
+	Method           *string     `json:"method,omitempty"`
+	// Без omitempty: nil уходит как null ("не менять"), а {} / [] - очистить
+	Headers        map[string]string `json:"headers"`
+	Body           *string           `json:"body,omitempty"`
+	ExpectedStatus []int             `json:"expected_status"`
+	Type           *string           `json:"type,omitempty"`
+	TimeoutMs      *int              `json:"timeout_ms,omitempty"`
+	Banner         *string           `json:"banner,omitempty"`
+	Count          *int              `json:"count,omitempty"`
+	IntervalMs     *int              `json:"interval_ms,omitempty"`
+	RecordType     *string           `json:"record_type,omitempty"`
+	Resolver       *string           `json:"resolver,omitempty"`
+	Expected       []string          `json:"expected"` // как Headers: null - не менять, [] - не сверять
+	GracePeriod    *int              `json:"grace_period,omitempty"`
+	Steps          []Step            `json:"steps,omitempty"` // заменяет все шаги; пустой - не менять
 }
 
+// Notification - сообщение в топик notification-alerts
 type Notification struct {
-	Email string `json:"email"`
-	Site  string `json:"site"`
-	Time  int64  `json:"time"`
+	Email        string `json:"email"`
+	Site         string `json:"site"`
+	Event        string `json:"event"` // down | recovery
+	Time         string `json:"time"`  // RFC3339, момент перехода
+	ResponseTime int64  `json:"response_time"`
+	DownSince    string `json:"down_since,omitempty"`  // для recovery: начало сбоя
+	Duration     int64  `json:"duration,omitempty"`    // для recovery: длительность сбоя, сек
+	IncidentID   int    `json:"incident_id,omitempty"` // для down и recovery: инцидент этого сбоя
+	Error        string `json:"error,omitempty"`       // для down: причина неудачи последней проверки
+	// Для cert_expiry / cert_invalid
+	CertIssuer   string `json:"cert_issuer,omitempty"`
+	CertNotAfter string `json:"cert_not_after,omitempty"` // RFC3339
+	DaysLeft     *int   `json:"days_left,omitempty"`
+	CertError    string `json:"cert_error,omitempty"`
+	// Для dns_changed
+	RecordType string   `json:"record_type,omitempty"`
+	OldRecords []string `json:"old_records,omitempty"`
+	NewRecords []string `json:"new_records,omitempty"`
+	// Каналы пользователя для этого сайта, помимо email
+	Channels []NotificationChannel `json:"channels,omitempty"`
+}
+
+// Типы каналов уведомлений
+const (
+	ChannelWebhook  = "webhook"
+	ChannelSlack    = "slack"
+	ChannelTelegram = "telegram"
+)
+
+// NotificationChannel - канал доставки из db_service /site-channels; config зависит от type
+type NotificationChannel struct {
+	ID     int             `json:"id"`
+	Type   string          `json:"type"`
+	Config json.RawMessage `json:"config"`
+}
+
+// WebhookConfig - настройки webhook-канала
+type WebhookConfig struct {
+	URL          string            `json:"url"`
+	Method       string            `json:"method,omitempty"` // POST по умолчанию
+	Headers      map[string]string `json:"headers,omitempty"`
+	BodyTemplate string            `json:"body_template,omitempty"` // text/template над уведомлением; пусто - само уведомление
+	Secret       string            `json:"secret,omitempty"`        // ключ HMAC-SHA256 для X-PingTower-Signature
+}
+
+// SlackConfig - настройки Slack-канала (incoming webhook)
+type SlackConfig struct {
+	WebhookURL string `json:"webhook_url"`
+}
+
+// TelegramConfig - настройки Telegram-канала: бот пользователя и чат, куда он пишет
+type TelegramConfig struct {
+	BotToken string `json:"bot_token"`
+	ChatID   string `json:"chat_id"` // числовой ID или @username канала
+}
+
+// Assertions - проверки тела ответа, выполняются в ping_service
+type Assertions struct {
+	BodyContains    string          `json:"body_contains,omitempty"`
+	BodyNotContains string          `json:"body_not_contains,omitempty"`
+	BodyRegex       string          `json:"body_regex,omitempty"`
+	JSONPath        string          `json:"json_path,omitempty"`
+	JSONEquals      json.RawMessage `json:"json_equals,omitempty"`
+	MaxBodySize     int64           `json:"max_body_size,omitempty"`
 }
 
 type PingRequest struct {
-	Site string `json:"site"`
+	Site           string            `json:"site"`
+	Assertions     *Assertions       `json:"assertions,omitempty"`
+	Method         string            `json:"method,omitempty"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	Body           string            `json:"body,omitempty"`
+	ExpectedStatus []int             `json:"expected_status,omitempty"`
+	Type           string            `json:"type,omitempty"`
+	TimeoutMs      int               `json:"timeout_ms,omitempty"`
+	Banner         string            `json:"banner,omitempty"`
+	Count          int               `json:"count,omitempty"`
+	IntervalMs     int               `json:"interval_ms,omitempty"`
+	RecordType     string            `json:"record_type,omitempty"`
+	Resolver       string            `json:"resolver,omitempty"`
+	Expected       []string          `json:"expected,omitempty"`
+	Steps          []Step            `json:"steps,omitempty"`
 }
 
 type PingResponse struct {
-	ResponseTime int64  `json:"response_time"`
-	Status       string `json:"status"`
+	ResponseTime int64        `json:"response_time"`
+	Status       string       `json:"status"`
+	Error        string       `json:"error,omitempty"`
+	Cert         *CertInfo    `json:"cert,omitempty"`
+	Timings      *Timings     `json:"timings,omitempty"`
+	ICMP         *ICMPStats   `json:"icmp,omitempty"`
+	DNS          *DNSResult   `json:"dns,omitempty"`
+	Steps        []StepResult `json:"steps,omitempty"`
+}
+
+// Step - один HTTP-запрос multistep-проверки; в URL, заголовках и теле
+// подставляются {{переменные}}, извлечённые предыдущими шагами
+type Step struct {
+	Name           string            `json:"name,omitempty"`
+	URL            string            `json:"url"`
+	Method         string            `json:"method,omitempty"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	Body           string            `json:"body,omitempty"`
+	ExpectedStatus []int             `json:"expected_status,omitempty"`
+	Assertions     *Assertions       `json:"assertions,omitempty"`
+	Extract        []Extract         `json:"extract,omitempty"`
+}
+
+// Extract - переменная из ответа шага
+type Extract struct {
+	Var  string `json:"var"`
+	From string `json:"from"` // header | json | cookie
+	Path string `json:"path"` // имя заголовка или cookie, путь JSON
+}
+
+// StepResult - итог шага multistep-проверки от ping_service
+type StepResult struct {
+	Name         string   `json:"name,omitempty"`
+	URL          string   `json:"url"`
+	Method       string   `json:"method"`
+	StatusCode   int      `json:"status_code,omitempty"`
+	ResponseTime int64    `json:"response_time"` // -1 - шаг не прошёл
+	Error        string   `json:"error,omitempty"`
+	Timings      *Timings `json:"timings,omitempty"`
+	Extracted    []string `json:"extracted,omitempty"`
+}
+
+// DNSResult - записи, которые вернул резолвер dns-проверки (нормализованы и отсортированы)
+type DNSResult struct {
+	RecordType string   `json:"record_type"`
+	Resolver   string   `json:"resolver,omitempty"`
+	Records    []string `json:"records"`
+}
+
+// ICMPStats - итог серии echo-запросов icmp-проверки
+type ICMPStats struct {
+	PacketsSent     int     `json:"packets_sent"`
+	PacketsReceived int     `json:"packets_received"`
+	LossPct         float64 `json:"loss_pct"`
+	RTTMinMs        float64 `json:"rtt_min_ms"`
+	RTTAvgMs        float64 `json:"rtt_avg_ms"`
+	RTTMaxMs        float64 `json:"rtt_max_ms"`
+	JitterMs        float64 `json:"jitter_ms"`
+}
+
+// Timings - из чего сложилось время ответа, мс
+type Timings struct {
+	DNSMs      int64 `json:"dns_ms"`
+	ConnectMs  int64 `json:"connect_ms"`
+	TLSMs      int64 `json:"tls_ms"`
+	TTFBMs     int64 `json:"ttfb_ms"`
+	TransferMs int64 `json:"transfer_ms"`
+}
+
+// CertInfo - TLS-сертификат сайта из ответа ping_service
+type CertInfo struct {
+	Issuer    string   `json:"issuer"`
+	Subject   string   `json:"subject"`
+	DNSNames  []string `json:"dns_names,omitempty"`
+	NotBefore string   `json:"not_before"`
+	NotAfter  string   `json:"not_after"`
+	DaysLeft  int      `json:"days_left"`
+	Error     string   `json:"error,omitempty"`
 }
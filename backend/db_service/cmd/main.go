@@ -27,12 +27,33 @@ func main() {
 	handler := internal.NewHandler(store)
 
 	http.HandleFunc("/user", handler.UserHandler)
+	http.HandleFunc("/user/verify", handler.VerifyUserHandler) // POST
 	http.HandleFunc("/user/sites/", handler.UserSitesHandler)
 	http.HandleFunc("/checker/", handler.CheckerHandler)
 	http.HandleFunc("/checkers", handler.CheckersHandler)
+	http.HandleFunc("/site-config/", handler.SiteConfigHandler)       // GET
 	http.HandleFunc("/all-users-sites", handler.AllUsersSitesHandler) // GET
 	http.HandleFunc("/ping", handler.PingHandler)                     // POST
 	http.HandleFunc("/user/", handler.UserEmailHandler)
+	http.HandleFunc("/site-state/", handler.SiteStateHandler)                   // GET, PUT
+	http.HandleFunc("/cert-logs", handler.CertLogsHandler)                      // POST
+	http.HandleFunc("/cert-logs/", handler.CertLogsHandler)                     // GET
+	http.HandleFunc("/heartbeat/", handler.HeartbeatHandler)                    // POST
+	http.HandleFunc("/heartbeats", handler.HeartbeatsHandler)                   // GET
+	http.HandleFunc("/lease/", handler.LeaseHandler)                            // POST, DELETE
+	http.HandleFunc("/stats/", handler.StatsHandler)                            // GET
+	http.HandleFunc("/incidents", handler.IncidentsHandler)                     // GET, POST
+	http.HandleFunc("/incidents/", handler.IncidentsHandler)                    // GET, POST
+	http.HandleFunc("/site-incident/", handler.SiteIncidentHandler)             // POST
+	http.HandleFunc("/status-pages", handler.StatusPagesHandler)                // GET, POST
+	http.HandleFunc("/status-pages/", handler.StatusPagesHandler)               // GET, PUT, DELETE
+	http.HandleFunc("/public-status/", handler.PublicStatusHandler)             // GET
+	http.HandleFunc("/maintenance-windows", handler.MaintenanceWindowsHandler)  // GET, POST
+	http.HandleFunc("/maintenance-windows/", handler.MaintenanceWindowsHandler) // GET, PUT, DELETE
+	http.HandleFunc("/maintenance/active", handler.MaintenanceActiveHandler)    // GET
+	http.HandleFunc("/notification-channels", handler.ChannelsHandler)          // GET, POST
+	http.HandleFunc("/notification-channels/", handler.ChannelsHandler)         // GET, PUT, DELETE
+	http.HandleFunc("/site-channels/", handler.SiteChannelsHandler)             // GET
 
 	configs.DBLogger.Println("Server starting on :8083")
 	err = http.ListenAndServe(":8083", nil)
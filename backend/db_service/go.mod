@@ -5,6 +5,7 @@ go 1.24.6
 require (
 	github.com/ClickHouse/clickhouse-go/v2 v2.40.3
 	github.com/lib/pq v1.10.9
+	golang.org/x/crypto v0.42.0
 )
 
 require (
@@ -0,0 +1,135 @@
+package internal
+
+import (
+	"database/sql"
+	"db_service/configs"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CertLog - TLS-сертификат сайта, увиденный при проверке
+type CertLog struct {
+	UserID    int       `json:"user_id"`
+	SiteID    int       `json:"site_id,omitempty"`
+	Site      string    `json:"site"`
+	CheckedAt time.Time `json:"checked_at"`
+	Issuer    string    `json:"issuer"`
+	Subject   string    `json:"subject"`
+	DNSNames  []string  `json:"dns_names"`
+	NotAfter  time.Time `json:"not_after"`
+	DaysLeft  int       `json:"days_left"`
+	Error     string    `json:"error,omitempty"` // ошибка проверки цепочки
+}
+
+func (s *Storage) AddCertLog(l CertLog) error {
+	if l.DNSNames == nil {
+		l.DNSNames = []string{}
+	}
+	_, err := s.ch.Exec(`
+		INSERT INTO cert_logs (user_id, site, checked_at, issuer, subject, dns_names, not_after, days_left, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, l.UserID, l.Site, l.CheckedAt, l.Issuer, l.Subject, l.DNSNames, l.NotAfter, l.DaysLeft, l.Error)
+	return err
+}
+
+// GetCertLogs возвращает историю сертификата сайта, новые записи первыми.
+func (s *Storage) GetCertLogs(userID, siteID int) ([]CertLog, error) {
+	var site string
+	err := s.psql.QueryRow(`
+		SELECT site FROM user_sites WHERE id = $1 AND user_id = $2
+	`, siteID, userID).Scan(&site)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.ch.Query(`
+		SELECT checked_at, issuer, subject, dns_names, not_after, days_left, error
+		FROM cert_logs
+		WHERE user_id = ? AND site = ?
+		ORDER BY checked_at DESC
+	`, userID, site)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	logs := []CertLog{}
+	for rows.Next() {
+		l := CertLog{UserID: userID, SiteID: siteID, Site: site}
+		if err := rows.Scan(&l.CheckedAt, &l.Issuer, &l.Subject, &l.DNSNames, &l.NotAfter, &l.DaysLeft, &l.Error); err != nil {
+			return nil, err
+		}
+		logs = append(logs, l)
+	}
+	return logs, rows.Err()
+}
+
+// CertLogsHandler: POST /cert-logs, GET /cert-logs/{site_id}?user_id=
+func (h *Handler) CertLogsHandler(w http.ResponseWriter, r *http.Request) {
+	configs.DBLogger.Printf("➡️ CertLogsHandler %s %s", r.Method, r.URL.String())
+
+	switch r.Method {
+	case http.MethodPost:
+		var l CertLog
+		if err := json.NewDecoder(r.Body).Decode(&l); err != nil {
+			configs.DBLogger.Println("❌ CertLogsHandler POST: decode error:", err)
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if l.UserID == 0 || l.Site == "" || l.NotAfter.IsZero() {
+			http.Error(w, "user_id, site and not_after are required", http.StatusBadRequest)
+			return
+		}
+		if l.CheckedAt.IsZero() {
+			l.CheckedAt = time.Now().UTC()
+		}
+		if err := h.store.AddCertLog(l); err != nil {
+			configs.DBLogger.Println("❌ CertLogsHandler POST: AddCertLog error:", err)
+			http.Error(w, fmt.Sprintf("Error saving cert log: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{"message": "cert log saved"})
+
+	case http.MethodGet:
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(parts) != 2 {
+			http.Error(w, "Invalid URL", http.StatusBadRequest)
+			return
+		}
+		siteID, err := strconv.Atoi(parts[1])
+		if err != nil {
+			http.Error(w, "Invalid site id", http.StatusBadRequest)
+			return
+		}
+		userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+		if err != nil || userID == 0 {
+			http.Error(w, "user_id query param required", http.StatusBadRequest)
+			return
+		}
+
+		logs, err := h.store.GetCertLogs(userID, siteID)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "site not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			configs.DBLogger.Println("❌ CertLogsHandler GET: GetCertLogs error:", err)
+			http.Error(w, fmt.Sprintf("Error getting cert logs: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(logs)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
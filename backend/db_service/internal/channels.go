@@ -0,0 +1,371 @@
+package internal
+
+import (
+	"database/sql"
+	"db_service/configs"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrChannelNotFound = errors.New("notification channel not found")
+
+const maxChannelSites = 100
+
+// NotificationChannel - канал, в который уходят уведомления владельца помимо
+// email. Формат Config зависит от Type и проверяется в api_service.
+type NotificationChannel struct {
+	ID        int             `json:"id"`
+	Type      string          `json:"type"`
+	Name      string          `json:"name"`
+	Config    json.RawMessage `json:"config"`
+	Enabled   bool            `json:"enabled"`
+	SiteIDs   []int           `json:"site_ids"`  // пусто - все мониторы пользователя
+	AllSites  bool            `json:"all_sites"` // вычисляется из site_ids
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// SiteChannel - канал для доставки уведомления о конкретном сайте
+type SiteChannel struct {
+	ID     int             `json:"id"`
+	Type   string          `json:"type"`
+	Config json.RawMessage `json:"config"`
+}
+
+func (s *Storage) CreateChannel(userID int, c NotificationChannel) (NotificationChannel, error) {
+	tx, err := s.psql.Begin()
+	if err != nil {
+		return c, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO notification_channels (user_id, type, name, config, enabled, all_sites)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id
+	`, userID, c.Type, c.Name, jsonParam(c.Config), c.Enabled, len(c.SiteIDs) == 0).Scan(&id)
+	if err != nil {
+		return c, err
+	}
+	if err := setChannelSites(tx, userID, id, c.SiteIDs); err != nil {
+		return c, err
+	}
+	if err := tx.Commit(); err != nil {
+		return c, err
+	}
+	return s.GetChannel(userID, id)
+}
+
+// UpdateChannel заменяет канал целиком, включая состав мониторов.
+func (s *Storage) UpdateChannel(userID, id int, c NotificationChannel) (NotificationChannel, error) {
+	tx, err := s.psql.Begin()
+	if err != nil {
+		return c, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE notification_channels
+		SET type = $3, name = $4, config = $5, enabled = $6, all_sites = $7
+		WHERE id = $1 AND user_id = $2
+	`, id, userID, c.Type, c.Name, jsonParam(c.Config), c.Enabled, len(c.SiteIDs) == 0)
+	if err != nil {
+		return c, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return c, ErrChannelNotFound
+	}
+	if err := setChannelSites(tx, userID, id, c.SiteIDs); err != nil {
+		return c, err
+	}
+	if err := tx.Commit(); err != nil {
+		return c, err
+	}
+	return s.GetChannel(userID, id)
+}
+
+// setChannelSites заменяет мониторы канала; указать можно только свои сайты.
+func setChannelSites(tx *sql.Tx, userID, channelID int, siteIDs []int) error {
+	if _, err := tx.Exec(`DELETE FROM notification_channel_sites WHERE channel_id = $1`, channelID); err != nil {
+		return err
+	}
+	for _, siteID := range siteIDs {
+		res, err := tx.Exec(`
+			INSERT INTO notification_channel_sites (channel_id, site_id)
+			SELECT $1, id FROM user_sites WHERE id = $2 AND user_id = $3
+		`, channelID, siteID, userID)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return fmt.Errorf("%w: %d", ErrSiteNotFound, siteID)
+		}
+	}
+	return nil
+}
+
+func (s *Storage) DeleteChannel(userID, id int) error {
+	res, err := s.psql.Exec(`DELETE FROM notification_channels WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrChannelNotFound
+	}
+	return nil
+}
+
+func (s *Storage) GetChannel(userID, id int) (NotificationChannel, error) {
+	channels, err := s.getChannels(`c.user_id = $1 AND c.id = $2`, userID, id)
+	if err != nil {
+		return NotificationChannel{}, err
+	}
+	if len(channels) == 0 {
+		return NotificationChannel{}, ErrChannelNotFound
+	}
+	return channels[0], nil
+}
+
+func (s *Storage) GetChannels(userID int) ([]NotificationChannel, error) {
+	return s.getChannels(`c.user_id = $1`, userID)
+}
+
+func (s *Storage) getChannels(where string, args ...any) ([]NotificationChannel, error) {
+	rows, err := s.psql.Query(`
+		SELECT c.id, c.type, c.name, c.config, c.enabled, c.all_sites, c.created_at, cs.site_id
+		FROM notification_channels c
+		LEFT JOIN notification_channel_sites cs ON cs.channel_id = c.id
+		WHERE `+where+`
+		ORDER BY c.id, cs.site_id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	channels := []NotificationChannel{}
+	for rows.Next() {
+		var c NotificationChannel
+		var config []byte
+		var siteID sql.NullInt64
+		if err := rows.Scan(&c.ID, &c.Type, &c.Name, &config, &c.Enabled, &c.AllSites, &c.CreatedAt, &siteID); err != nil {
+			return nil, err
+		}
+		if n := len(channels); n == 0 || channels[n-1].ID != c.ID {
+			c.Config = config
+			c.SiteIDs = []int{}
+			channels = append(channels, c)
+		}
+		if siteID.Valid {
+			last := &channels[len(channels)-1]
+			last.SiteIDs = append(last.SiteIDs, int(siteID.Int64))
+		}
+	}
+	return channels, rows.Err()
+}
+
+// GetSiteChannels возвращает включённые каналы, в которые уходят уведомления о сайте.
+func (s *Storage) GetSiteChannels(userID, siteID int) ([]SiteChannel, error) {
+	rows, err := s.psql.Query(`
+		SELECT c.id, c.type, c.config
+		FROM notification_channels c
+		WHERE c.user_id = $1 AND c.enabled
+			AND (c.all_sites OR EXISTS (
+				SELECT 1 FROM notification_channel_sites cs WHERE cs.channel_id = c.id AND cs.site_id = $2
+			))
+		ORDER BY c.id
+	`, userID, siteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	channels := []SiteChannel{}
+	for rows.Next() {
+		var c SiteChannel
+		var config []byte
+		if err := rows.Scan(&c.ID, &c.Type, &config); err != nil {
+			return nil, err
+		}
+		c.Config = config
+		channels = append(channels, c)
+	}
+	return channels, rows.Err()
+}
+
+// ChannelsHandler - каналы уведомлений пользователя:
+//
+//	GET  /notification-channels?user_id=
+//	POST /notification-channels?user_id=    {"type", "name", "config", "enabled", "site_ids"}
+//	GET/PUT/DELETE /notification-channels/{id}?user_id=
+func (h *Handler) ChannelsHandler(w http.ResponseWriter, r *http.Request) {
+	configs.DBLogger.Printf("➡️ ChannelsHandler %s %s", r.Method, r.URL.String())
+
+	userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+	if err != nil || userID == 0 {
+		http.Error(w, "user_id query param required", http.StatusBadRequest)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) > 2 {
+		http.Error(w, "Invalid URL", http.StatusBadRequest)
+		return
+	}
+
+	if len(parts) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			channels, err := h.store.GetChannels(userID)
+			if err != nil {
+				configs.DBLogger.Println("❌ ChannelsHandler GET: GetChannels error:", err)
+				http.Error(w, fmt.Sprintf("Error getting notification channels: %v", err), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(channels)
+		case http.MethodPost:
+			c, ok := decodeChannel(w, r)
+			if !ok {
+				return
+			}
+			c, err := h.store.CreateChannel(userID, c)
+			if err != nil {
+				writeChannel(w, c, err)
+				return
+			}
+			configs.DBLogger.Printf("✅ %s channel %d created for user_id=%d", c.Type, c.ID, userID)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(c)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	id, err := strconv.Atoi(parts[1])
+	if err != nil {
+		http.Error(w, "Invalid channel id", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		c, err := h.store.GetChannel(userID, id)
+		writeChannel(w, c, err)
+	case http.MethodPut:
+		c, ok := decodeChannel(w, r)
+		if !ok {
+			return
+		}
+		c, err := h.store.UpdateChannel(userID, id, c)
+		writeChannel(w, c, err)
+	case http.MethodDelete:
+		if err := h.store.DeleteChannel(userID, id); err != nil {
+			writeChannel(w, NotificationChannel{}, err)
+			return
+		}
+		configs.DBLogger.Printf("✅ notification channel %d deleted", id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// decodeChannel читает и проверяет тело запроса; при ошибке сам отвечает клиенту.
+// Без enabled канал создаётся включённым.
+func decodeChannel(w http.ResponseWriter, r *http.Request) (NotificationChannel, bool) {
+	var body struct {
+		NotificationChannel
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		configs.DBLogger.Println("❌ ChannelsHandler: decode error:", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return NotificationChannel{}, false
+	}
+	c := body.NotificationChannel
+	c.Enabled = body.Enabled == nil || *body.Enabled
+
+	c.Name = strings.TrimSpace(c.Name)
+	switch {
+	case c.Type == "" || len(c.Type) > 16:
+		http.Error(w, "type is required", http.StatusBadRequest)
+		return c, false
+	case len(c.Name) > 255:
+		http.Error(w, "name must be at most 255 characters", http.StatusBadRequest)
+		return c, false
+	case !strings.HasPrefix(strings.TrimSpace(string(c.Config)), "{"):
+		http.Error(w, "config must be a JSON object", http.StatusBadRequest)
+		return c, false
+	case len(c.SiteIDs) > maxChannelSites:
+		http.Error(w, fmt.Sprintf("at most %d sites per channel", maxChannelSites), http.StatusBadRequest)
+		return c, false
+	}
+	seen := map[int]bool{}
+	for _, id := range c.SiteIDs {
+		if seen[id] {
+			http.Error(w, fmt.Sprintf("duplicate site_id %d", id), http.StatusBadRequest)
+			return c, false
+		}
+		seen[id] = true
+	}
+	if c.SiteIDs == nil {
+		c.SiteIDs = []int{}
+	}
+	return c, true
+}
+
+func writeChannel(w http.ResponseWriter, c NotificationChannel, err error) {
+	switch {
+	case errors.Is(err, ErrChannelNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, ErrSiteNotFound):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		configs.DBLogger.Println("❌ ChannelsHandler error:", err)
+		http.Error(w, fmt.Sprintf("Error saving notification channel: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
+// SiteChannelsHandler: GET /site-channels/{site_id}?user_id=
+// Включённые каналы, в которые api_service отправляет уведомления о сайте.
+func (h *Handler) SiteChannelsHandler(w http.ResponseWriter, r *http.Request) {
+	configs.DBLogger.Printf("➡️ SiteChannelsHandler %s %s", r.Method, r.URL.String())
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	siteID, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/site-channels/"))
+	if err != nil {
+		http.Error(w, "Invalid site id", http.StatusBadRequest)
+		return
+	}
+	userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+	if err != nil || userID == 0 {
+		http.Error(w, "user_id query param required", http.StatusBadRequest)
+		return
+	}
+
+	channels, err := h.store.GetSiteChannels(userID, siteID)
+	if err != nil {
+		configs.DBLogger.Println("❌ SiteChannelsHandler: GetSiteChannels error:", err)
+		http.Error(w, fmt.Sprintf("Error getting notification channels: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(channels)
+}
@@ -1,12 +1,15 @@
 package internal
 
 import (
+	"database/sql"
 	"db_service/configs"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Handler struct {
@@ -72,6 +75,43 @@ func (h *Handler) UserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// POST /user/verify  {email, password}
+func (h *Handler) VerifyUserHandler(w http.ResponseWriter, r *http.Request) {
+	configs.DBLogger.Printf("➡️ VerifyUserHandler %s %s", r.Method, r.URL.String())
+
+	if r.Method != http.MethodPost {
+		configs.DBLogger.Printf("❌ VerifyUserHandler: method not allowed %s", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var creds struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
+		configs.DBLogger.Println("❌ VerifyUserHandler: decode error:", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	configs.DBLogger.Printf("📥 VerifyUser email=%s", creds.Email)
+
+	id, err := h.store.VerifyUser(creds.Email, creds.Password)
+	if errors.Is(err, ErrInvalidCredentials) {
+		configs.DBLogger.Printf("❌ VerifyUserHandler: invalid credentials email=%s", creds.Email)
+		http.Error(w, "invalid email or password", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		configs.DBLogger.Println("❌ VerifyUserHandler: VerifyUser error:", err)
+		http.Error(w, fmt.Sprintf("Error verifying user: %v", err), http.StatusInternalServerError)
+		return
+	}
+	configs.DBLogger.Printf("✅ VerifyUserHandler: user id=%d", id)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"id": id, "email": creds.Email})
+}
+
 // GET /all-users-sites
 func (h *Handler) AllUsersSitesHandler(w http.ResponseWriter, r *http.Request) {
 	configs.DBLogger.Printf("➡️ AllUsersSitesHandler %s %s", r.Method, r.URL.String())
@@ -103,10 +143,8 @@ func (h *Handler) PingHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var body struct {
-		UserID   int    `json:"user_id"`
-		Site     string `json:"site"`
-		RespTime int64  `json:"resp_time"`
-		Status   string `json:"status"`
+		UserID int `json:"user_id"`
+		PingLog
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		configs.DBLogger.Println("❌ PingHandler: decode error:", err)
@@ -121,7 +159,7 @@ func (h *Handler) PingHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "user_id and site are required", http.StatusBadRequest)
 		return
 	}
-	if err := h.store.AddPingLog(body.UserID, body.Site, body.RespTime, body.Status); err != nil {
+	if err := h.store.AddPingLog(body.UserID, body.PingLog); err != nil {
 		configs.DBLogger.Println("❌ PingHandler: AddPingLog error:", err)
 		http.Error(w, fmt.Sprintf("Error saving ping log: %v", err), http.StatusInternalServerError)
 		return
@@ -217,11 +255,13 @@ func (h *Handler) UserSitesHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// CheckerHandler получает логи конкретного сайта
+// CheckerHandler обрабатывает /checker/{id}: логи (GET), изменение (PUT/PATCH) и удаление (DELETE) сайта
 func (h *Handler) CheckerHandler(w http.ResponseWriter, r *http.Request) {
 	configs.DBLogger.Printf("➡️ CheckerHandler %s %s", r.Method, r.URL.String())
 
-	if r.Method != http.MethodGet {
+	switch r.Method {
+	case http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
 		configs.DBLogger.Printf("❌ CheckerHandler: method not allowed %s", r.Method)
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -239,6 +279,15 @@ func (h *Handler) CheckerHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	switch r.Method {
+	case http.MethodPut, http.MethodPatch:
+		h.updateSite(w, r, siteID)
+		return
+	case http.MethodDelete:
+		h.deleteSite(w, r, siteID)
+		return
+	}
+
 	// сначала попробуем query ?user_id=
 	var userID int
 	if uid := r.URL.Query().Get("user_id"); uid != "" {
@@ -262,7 +311,36 @@ func (h *Handler) CheckerHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	configs.DBLogger.Printf("📥 CheckerHandler: user_id=%d site_id=%d", userID, siteID)
 
-	logs, err := h.store.GetSiteLogs(userID, siteID)
+	q, err := parseLogQuery(r.URL.Query(), time.Now().UTC())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Агрегаты для графиков: ?bucket=1m|5m|1h
+	if q.Bucket > 0 {
+		buckets, err := h.store.GetLogBuckets(userID, siteID, q)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "site not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			configs.DBLogger.Println("❌ CheckerHandler: GetLogBuckets error:", err)
+			http.Error(w, fmt.Sprintf("Error getting log buckets: %v", err), http.StatusInternalServerError)
+			return
+		}
+		configs.DBLogger.Printf("✅ CheckerHandler: buckets=%d", len(buckets))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(buckets)
+		return
+	}
+
+	logs, next, err := h.store.GetSiteLogs(userID, siteID, q)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "site not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		configs.DBLogger.Println("❌ CheckerHandler: GetSiteLogs error:", err)
 		http.Error(w, fmt.Sprintf("Error getting logs: %v", err), http.StatusInternalServerError)
@@ -270,10 +348,134 @@ func (h *Handler) CheckerHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	configs.DBLogger.Printf("✅ CheckerHandler: logs=%d", len(logs))
 
+	// Курсор следующей страницы - в заголовке, тело остаётся массивом логов
+	if next != "" {
+		w.Header().Set("X-Next-Cursor", next)
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(logs)
 }
 
+// PUT/PATCH /checker/{id}?user_id=  {site, time, failure_threshold, window_size, window_failures}
+// PUT требует как минимум site и time, PATCH меняет только переданные поля.
+func (h *Handler) updateSite(w http.ResponseWriter, r *http.Request, siteID int) {
+	userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+	if err != nil || userID == 0 {
+		configs.DBLogger.Printf("❌ updateSite: invalid user_id query %q", r.URL.Query().Get("user_id"))
+		http.Error(w, "user_id query param required", http.StatusBadRequest)
+		return
+	}
+
+	var body SiteUpdate
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		configs.DBLogger.Println("❌ updateSite: decode error:", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if r.Method == http.MethodPut && (body.Site == nil || body.CheckInterval == nil) {
+		http.Error(w, "site and time are required for PUT", http.StatusBadRequest)
+		return
+	}
+	if body.empty() {
+		http.Error(w, "nothing to update", http.StatusBadRequest)
+		return
+	}
+	if body.Site != nil && *body.Site == "" {
+		http.Error(w, "site must not be empty", http.StatusBadRequest)
+		return
+	}
+	configs.DBLogger.Printf("📥 updateSite: user_id=%d site_id=%d", userID, siteID)
+
+	info, err := h.store.UpdateUserSite(userID, siteID, body)
+	switch {
+	case errors.Is(err, ErrSiteNotFound):
+		http.Error(w, "site not found", http.StatusNotFound)
+		return
+	case errors.Is(err, ErrSiteExists):
+		http.Error(w, "site already exists", http.StatusConflict)
+		return
+	case err != nil:
+		configs.DBLogger.Println("❌ updateSite: UpdateUserSite error:", err)
+		http.Error(w, fmt.Sprintf("Error updating site: %v", err), http.StatusInternalServerError)
+		return
+	}
+	configs.DBLogger.Printf("✅ updateSite: site_id=%d updated", siteID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+// SiteConfigHandler - GET /site-config/{site_id}?user_id=: настройки проверки
+// сайта; api_service накладывает на них PATCH, чтобы проверить результат целиком.
+func (h *Handler) SiteConfigHandler(w http.ResponseWriter, r *http.Request) {
+	configs.DBLogger.Printf("➡️ SiteConfigHandler %s %s", r.Method, r.URL.String())
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	siteID, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/site-config/"))
+	if err != nil {
+		http.Error(w, "Invalid site ID", http.StatusBadRequest)
+		return
+	}
+	userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+	if err != nil || userID == 0 {
+		http.Error(w, "user_id query param required", http.StatusBadRequest)
+		return
+	}
+
+	info, err := h.store.GetUserSite(userID, siteID)
+	if errors.Is(err, ErrSiteNotFound) {
+		http.Error(w, "site not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		configs.DBLogger.Println("❌ SiteConfigHandler: GetUserSite error:", err)
+		http.Error(w, fmt.Sprintf("Error getting site: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+// DELETE /checker/{id}?user_id=&logs=keep|archive|purge
+func (h *Handler) deleteSite(w http.ResponseWriter, r *http.Request, siteID int) {
+	q := r.URL.Query()
+	userID, err := strconv.Atoi(q.Get("user_id"))
+	if err != nil || userID == 0 {
+		configs.DBLogger.Printf("❌ deleteSite: invalid user_id query %q", q.Get("user_id"))
+		http.Error(w, "user_id query param required", http.StatusBadRequest)
+		return
+	}
+
+	logsMode := q.Get("logs")
+	if logsMode == "" {
+		logsMode = LogsKeep
+	}
+	if logsMode != LogsKeep && logsMode != LogsArchive && logsMode != LogsPurge {
+		http.Error(w, "logs must be one of keep, archive, purge", http.StatusBadRequest)
+		return
+	}
+	configs.DBLogger.Printf("📥 deleteSite: user_id=%d site_id=%d logs=%s", userID, siteID, logsMode)
+
+	err = h.store.DeleteUserSite(userID, siteID, logsMode)
+	if errors.Is(err, ErrSiteNotFound) {
+		http.Error(w, "site not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		configs.DBLogger.Println("❌ deleteSite: DeleteUserSite error:", err)
+		http.Error(w, fmt.Sprintf("Error deleting site: %v", err), http.StatusInternalServerError)
+		return
+	}
+	configs.DBLogger.Println("✅ deleteSite: site deleted")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Site deleted", "logs": logsMode})
+}
+
 // CheckersHandler обрабатывает GET и POST для /checkers
 func (h *Handler) CheckersHandler(w http.ResponseWriter, r *http.Request) {
 	configs.DBLogger.Printf("➡️ CheckersHandler %s %s", r.Method, r.URL.String())
@@ -328,9 +530,27 @@ func (h *Handler) getUserLogs(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) addSiteWithCheck(w http.ResponseWriter, r *http.Request) {
 	// Логироваться будет из вызывающего CheckersHandler
 	var siteData struct {
-		Site   string `json:"site"`
-		Time   int    `json:"time"`
-		UserID int    `json:"user_id"`
+		Site             string          `json:"site"`
+		Time             int             `json:"time"`
+		UserID           int             `json:"user_id"`
+		FailureThreshold int             `json:"failure_threshold"`
+		WindowSize       int             `json:"window_size"`
+		WindowFailures   int             `json:"window_failures"`
+		Assertions       json.RawMessage `json:"assertions"`
+		Method           string          `json:"method"`
+		Headers          json.RawMessage `json:"headers"`
+		Body             string          `json:"body"`
+		ExpectedStatus   json.RawMessage `json:"expected_status"`
+		Type             string          `json:"type"`
+		TimeoutMs        int             `json:"timeout_ms"`
+		Banner           string          `json:"banner"`
+		Count            int             `json:"count"`
+		IntervalMs       int             `json:"interval_ms"`
+		RecordType       string          `json:"record_type"`
+		Resolver         string          `json:"resolver"`
+		Expected         json.RawMessage `json:"expected"`
+		GracePeriod      int             `json:"grace_period"`
+		Steps            json.RawMessage `json:"steps"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&siteData); err != nil {
@@ -341,7 +561,28 @@ func (h *Handler) addSiteWithCheck(w http.ResponseWriter, r *http.Request) {
 	configs.DBLogger.Printf("📥 addSiteWithCheck: user_id=%d site=%s time=%d", siteData.UserID, siteData.Site, siteData.Time)
 
 	// Добавляем сайт и сразу создаем запись для проверки
-	err := h.store.AddSiteWithCheck(siteData.UserID, siteData.Site, siteData.Time)
+	err := h.store.AddSiteWithCheck(siteData.UserID, SiteInfo{
+		URL:              siteData.Site,
+		CheckInterval:    siteData.Time,
+		FailureThreshold: siteData.FailureThreshold,
+		WindowSize:       siteData.WindowSize,
+		WindowFailures:   siteData.WindowFailures,
+		Assertions:       siteData.Assertions,
+		Method:           siteData.Method,
+		Headers:          siteData.Headers,
+		Body:             siteData.Body,
+		ExpectedStatus:   siteData.ExpectedStatus,
+		Type:             siteData.Type,
+		TimeoutMs:        siteData.TimeoutMs,
+		Banner:           siteData.Banner,
+		Count:            siteData.Count,
+		IntervalMs:       siteData.IntervalMs,
+		RecordType:       siteData.RecordType,
+		Resolver:         siteData.Resolver,
+		Expected:         siteData.Expected,
+		GracePeriod:      siteData.GracePeriod,
+		Steps:            siteData.Steps,
+	})
 	if err != nil {
 		configs.DBLogger.Println("❌ addSiteWithCheck: AddSiteWithCheck error:", err)
 		http.Error(w, fmt.Sprintf("Error adding site: %v", err), http.StatusInternalServerError)
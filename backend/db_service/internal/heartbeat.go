@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"db_service/configs"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// newHeartbeatToken - 128 случайных бит: токен в push-URL - единственная защита эндпоинта
+func newHeartbeatToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate heartbeat token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// RecordHeartbeat отмечает сигнал heartbeat-монитора и возвращает его владельца и настройки.
+func (s *Storage) RecordHeartbeat(token string, at time.Time) (int, SiteInfo, error) {
+	var userID int
+	info, err := scanSite(scanPrefix{s.psql.QueryRow(`
+		UPDATE user_sites SET last_heartbeat_at = $2
+		WHERE heartbeat_token = $1 AND monitor_type = $3
+		RETURNING user_id, `+siteColumns,
+		token, at, MonitorHeartbeat), &userID})
+	if err == sql.ErrNoRows {
+		return 0, info, ErrSiteNotFound
+	}
+	return userID, info, err
+}
+
+// GetHeartbeatSites возвращает heartbeat-мониторы, сгруппированные по владельцам.
+func (s *Storage) GetHeartbeatSites() ([]UserSites, error) {
+	rows, err := s.psql.Query(`SELECT user_id, `+siteColumns+` FROM user_sites
+		WHERE monitor_type = $1 ORDER BY user_id, id`, MonitorHeartbeat)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []UserSites{}
+	for rows.Next() {
+		var uid int
+		info, err := scanSite(scanPrefix{rows, &uid})
+		if err != nil {
+			return nil, err
+		}
+		if n := len(out); n == 0 || out[n-1].UserID != uid {
+			out = append(out, UserSites{UserID: uid, Sites: []SiteInfo{}})
+		}
+		out[len(out)-1].Sites = append(out[len(out)-1].Sites, info)
+	}
+	return out, rows.Err()
+}
+
+// HeartbeatHandler: POST /heartbeat/{token} - сигнал от задачи, проксируется из api_service
+func (h *Handler) HeartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token := strings.TrimPrefix(r.URL.Path, "/heartbeat/")
+	if token == "" || strings.Contains(token, "/") {
+		http.Error(w, "Invalid URL", http.StatusBadRequest)
+		return
+	}
+
+	userID, site, err := h.store.RecordHeartbeat(token, time.Now().UTC())
+	if errors.Is(err, ErrSiteNotFound) {
+		http.Error(w, "heartbeat not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		configs.DBLogger.Println("❌ HeartbeatHandler: RecordHeartbeat error:", err)
+		http.Error(w, fmt.Sprintf("Error recording heartbeat: %v", err), http.StatusInternalServerError)
+		return
+	}
+	configs.DBLogger.Printf("💓 heartbeat site_id=%d user_id=%d", site.ID, userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		UserID int      `json:"user_id"`
+		Site   SiteInfo `json:"site"`
+	}{userID, site})
+}
+
+// HeartbeatsHandler: GET /heartbeats - все heartbeat-мониторы для поиска пропущенных сигналов
+func (h *Handler) HeartbeatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sites, err := h.store.GetHeartbeatSites()
+	if err != nil {
+		configs.DBLogger.Println("❌ HeartbeatsHandler: GetHeartbeatSites error:", err)
+		http.Error(w, fmt.Sprintf("Error getting heartbeats: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sites)
+}
@@ -0,0 +1,528 @@
+package internal
+
+import (
+	"database/sql"
+	"db_service/configs"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrIncidentNotFound     = errors.New("incident not found")
+	ErrIncidentResolved     = errors.New("incident already resolved")
+	ErrIncidentAcknowledged = errors.New("incident already acknowledged")
+)
+
+// Статусы инцидента; хранится не статус, а времена подтверждения и закрытия
+const (
+	IncidentOpen         = "open"
+	IncidentAcknowledged = "acknowledged"
+	IncidentResolved     = "resolved"
+)
+
+// Записи хронологии инцидента
+const (
+	IncidentEventOpened       = "opened"
+	IncidentEventAcknowledged = "acknowledged"
+	IncidentEventComment      = "comment"
+	IncidentEventResolved     = "resolved"
+)
+
+// incidentStatusFilters - условие WHERE для фильтра ?status=
+var incidentStatusFilters = map[string]string{
+	IncidentOpen:         "i.resolved_at IS NULL AND i.acknowledged_at IS NULL",
+	IncidentAcknowledged: "i.resolved_at IS NULL AND i.acknowledged_at IS NOT NULL",
+	IncidentResolved:     "i.resolved_at IS NOT NULL",
+}
+
+// Incident - сбой сайта от срабатывания порога до восстановления.
+// Открывает и закрывает его api_service по переходам down/recovery,
+// пользователь может подтвердить его, закрыть вручную и оставить комментарий.
+type Incident struct {
+	ID             int             `json:"id"`
+	SiteID         int             `json:"site_id"`
+	Site           string          `json:"site"`
+	Status         string          `json:"status"` // open | acknowledged | resolved
+	OpenedAt       time.Time       `json:"opened_at"`
+	FirstFailureAt time.Time       `json:"first_failure_at"`
+	LastFailureAt  time.Time       `json:"last_failure_at"`
+	ResolvedAt     *time.Time      `json:"resolved_at"`
+	ResolvedBy     string          `json:"resolved_by,omitempty"` // пусто - закрыт автоматически, когда сайт восстановился
+	RootError      string          `json:"root_error"`
+	AcknowledgedAt *time.Time      `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy string          `json:"acknowledged_by,omitempty"`
+	Timeline       []IncidentEvent `json:"timeline,omitempty"` // только в GET /incidents/{id}
+}
+
+// IncidentEvent - запись хронологии инцидента
+type IncidentEvent struct {
+	At      time.Time `json:"at"`
+	Kind    string    `json:"kind"`             // opened | acknowledged | comment | resolved
+	Author  string    `json:"author,omitempty"` // пусто - действие api_service
+	Message string    `json:"message,omitempty"`
+}
+
+const incidentColumns = `
+	i.id, i.site_id, s.site, i.opened_at, i.first_failure_at, i.last_failure_at,
+	i.resolved_at, i.resolved_by, i.root_error, i.acknowledged_at, i.acknowledged_by
+	FROM incidents i JOIN user_sites s ON s.id = i.site_id`
+
+func scanIncident(row interface{ Scan(...any) error }) (Incident, error) {
+	var in Incident
+	err := row.Scan(&in.ID, &in.SiteID, &in.Site, &in.OpenedAt, &in.FirstFailureAt, &in.LastFailureAt,
+		&in.ResolvedAt, &in.ResolvedBy, &in.RootError, &in.AcknowledgedAt, &in.AcknowledgedBy)
+	switch {
+	case in.ResolvedAt != nil:
+		in.Status = IncidentResolved
+	case in.AcknowledgedAt != nil:
+		in.Status = IncidentAcknowledged
+	default:
+		in.Status = IncidentOpen
+	}
+	return in, err
+}
+
+// OpenIncident открывает инцидент сайта. Если незакрытый уже есть (например,
+// переход down пришёл повторно после рестарта), возвращает его и created = false.
+func (s *Storage) OpenIncident(in Incident) (Incident, bool, error) {
+	tx, err := s.psql.Begin()
+	if err != nil {
+		return in, false, err
+	}
+	defer tx.Rollback()
+
+	created := true
+	err = tx.QueryRow(`
+		INSERT INTO incidents (site_id, opened_at, first_failure_at, last_failure_at, root_error)
+		VALUES ($1, $2, $3, $2, $4)
+		ON CONFLICT (site_id) WHERE resolved_at IS NULL DO NOTHING
+		RETURNING id
+	`, in.SiteID, in.OpenedAt, in.FirstFailureAt, in.RootError).Scan(&in.ID)
+	if err == sql.ErrNoRows {
+		created = false
+		err = tx.QueryRow(`SELECT id FROM incidents WHERE site_id = $1 AND resolved_at IS NULL`, in.SiteID).Scan(&in.ID)
+	}
+	if err != nil {
+		return in, false, err
+	}
+	if created {
+		if err := addIncidentEvent(tx, in.ID, in.OpenedAt, IncidentEventOpened, "", in.RootError); err != nil {
+			return in, false, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return in, false, err
+	}
+
+	opened, err := scanIncident(s.psql.QueryRow(`SELECT `+incidentColumns+` WHERE i.id = $1`, in.ID))
+	return opened, created, err
+}
+
+// RecordIncidentFailure продлевает незакрытый инцидент сайта очередной неудачной
+// проверкой. Если инцидента нет (порог ещё не сработал), ничего не делает.
+func (s *Storage) RecordIncidentFailure(siteID int, at time.Time) error {
+	_, err := s.psql.Exec(`
+		UPDATE incidents SET last_failure_at = GREATEST(last_failure_at, $2)
+		WHERE site_id = $1 AND resolved_at IS NULL
+	`, siteID, at)
+	return err
+}
+
+// ResolveSiteIncident закрывает незакрытый инцидент сайта, когда тот восстановился.
+func (s *Storage) ResolveSiteIncident(siteID int, at time.Time) (Incident, error) {
+	tx, err := s.psql.Begin()
+	if err != nil {
+		return Incident{}, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRow(`
+		UPDATE incidents SET resolved_at = $2
+		WHERE site_id = $1 AND resolved_at IS NULL
+		RETURNING id
+	`, siteID, at).Scan(&id)
+	if err == sql.ErrNoRows {
+		return Incident{}, ErrIncidentNotFound
+	}
+	if err != nil {
+		return Incident{}, err
+	}
+	if err := addIncidentEvent(tx, id, at, IncidentEventResolved, "", ""); err != nil {
+		return Incident{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Incident{}, err
+	}
+	return scanIncident(s.psql.QueryRow(`SELECT `+incidentColumns+` WHERE i.id = $1`, id))
+}
+
+// GetIncidents возвращает инциденты пользователя, новые первыми.
+// siteID = 0 и status = "" - без фильтра.
+func (s *Storage) GetIncidents(userID, siteID int, status string) ([]Incident, error) {
+	conds := []string{"s.user_id = $1"}
+	args := []any{userID}
+	if siteID != 0 {
+		args = append(args, siteID)
+		conds = append(conds, fmt.Sprintf("i.site_id = $%d", len(args)))
+	}
+	if status != "" {
+		conds = append(conds, incidentStatusFilters[status])
+	}
+
+	rows, err := s.psql.Query(`SELECT `+incidentColumns+`
+		WHERE `+strings.Join(conds, " AND ")+`
+		ORDER BY i.opened_at DESC, i.id DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	incidents := []Incident{}
+	for rows.Next() {
+		in, err := scanIncident(rows)
+		if err != nil {
+			return nil, err
+		}
+		incidents = append(incidents, in)
+	}
+	return incidents, rows.Err()
+}
+
+// GetIncident возвращает инцидент пользователя вместе с хронологией.
+func (s *Storage) GetIncident(userID, id int) (Incident, error) {
+	in, err := scanIncident(s.psql.QueryRow(`SELECT `+incidentColumns+`
+		WHERE i.id = $1 AND s.user_id = $2`, id, userID))
+	if err == sql.ErrNoRows {
+		return in, ErrIncidentNotFound
+	}
+	if err != nil {
+		return in, err
+	}
+
+	rows, err := s.psql.Query(`
+		SELECT at, kind, author, message FROM incident_events
+		WHERE incident_id = $1
+		ORDER BY at, id
+	`, id)
+	if err != nil {
+		return in, err
+	}
+	defer rows.Close()
+
+	in.Timeline = []IncidentEvent{}
+	for rows.Next() {
+		var e IncidentEvent
+		if err := rows.Scan(&e.At, &e.Kind, &e.Author, &e.Message); err != nil {
+			return in, err
+		}
+		in.Timeline = append(in.Timeline, e)
+	}
+	return in, rows.Err()
+}
+
+// AcknowledgeIncident отмечает, что by взялся за незакрытый инцидент.
+func (s *Storage) AcknowledgeIncident(userID, id int, by, message string) (Incident, error) {
+	return s.updateIncident(userID, id, IncidentEventAcknowledged, by, message, `
+		UPDATE incidents i SET acknowledged_at = $3, acknowledged_by = $4
+		FROM user_sites s
+		WHERE i.id = $1 AND s.id = i.site_id AND s.user_id = $2
+			AND i.resolved_at IS NULL AND i.acknowledged_at IS NULL
+		RETURNING i.id
+	`)
+}
+
+// ResolveIncident закрывает инцидент вручную. Если сайт всё ещё недоступен,
+// новый инцидент откроется только после его восстановления и следующего сбоя.
+func (s *Storage) ResolveIncident(userID, id int, by, message string) (Incident, error) {
+	return s.updateIncident(userID, id, IncidentEventResolved, by, message, `
+		UPDATE incidents i SET resolved_at = $3, resolved_by = $4
+		FROM user_sites s
+		WHERE i.id = $1 AND s.id = i.site_id AND s.user_id = $2
+			AND i.resolved_at IS NULL
+		RETURNING i.id
+	`)
+}
+
+// CommentIncident добавляет комментарий в хронологию, в том числе закрытого инцидента.
+func (s *Storage) CommentIncident(userID, id int, by, message string) (Incident, error) {
+	if _, err := s.GetIncident(userID, id); err != nil {
+		return Incident{}, err
+	}
+	return s.updateIncident(userID, id, IncidentEventComment, by, message, "")
+}
+
+// updateIncident выполняет действие пользователя (query с параметрами
+// id, user_id, время, автор; пустой - только запись) и пишет его в хронологию.
+// Если query не задел ни одной строки, выясняет почему: инцидента нет или он
+// в другом статусе.
+func (s *Storage) updateIncident(userID, id int, kind, by, message, query string) (Incident, error) {
+	tx, err := s.psql.Begin()
+	if err != nil {
+		return Incident{}, err
+	}
+	defer tx.Rollback()
+
+	at := time.Now().UTC()
+	if query != "" {
+		var updated int
+		err = tx.QueryRow(query, id, userID, at, by).Scan(&updated)
+		if err == sql.ErrNoRows {
+			in, err := s.GetIncident(userID, id)
+			switch {
+			case err != nil:
+				return in, err
+			case in.Status == IncidentResolved:
+				return in, ErrIncidentResolved
+			default:
+				return in, ErrIncidentAcknowledged
+			}
+		}
+		if err != nil {
+			return Incident{}, err
+		}
+	}
+	if err := addIncidentEvent(tx, id, at, kind, by, message); err != nil {
+		return Incident{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Incident{}, err
+	}
+	return s.GetIncident(userID, id)
+}
+
+func addIncidentEvent(tx *sql.Tx, incidentID int, at time.Time, kind, author, message string) error {
+	_, err := tx.Exec(`
+		INSERT INTO incident_events (incident_id, at, kind, author, message)
+		VALUES ($1, $2, $3, $4, $5)
+	`, incidentID, at, kind, author, message)
+	return err
+}
+
+// IncidentsHandler:
+//
+//	POST /incidents                             - открыть инцидент (api_service)
+//	GET  /incidents?user_id=&site_id=&status=   - список
+//	GET  /incidents/{id}?user_id=               - инцидент с хронологией
+//	POST /incidents/{id}/{ack|resolve|comments}?user_id= {"by", "message"}
+func (h *Handler) IncidentsHandler(w http.ResponseWriter, r *http.Request) {
+	configs.DBLogger.Printf("➡️ IncidentsHandler %s %s", r.Method, r.URL.String())
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) == 1 {
+		switch r.Method {
+		case http.MethodPost:
+			h.openIncident(w, r)
+		case http.MethodGet:
+			h.listIncidents(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	if len(parts) > 3 {
+		http.Error(w, "Invalid URL", http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.Atoi(parts[1])
+	if err != nil {
+		http.Error(w, "Invalid incident id", http.StatusBadRequest)
+		return
+	}
+	userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+	if err != nil || userID == 0 {
+		http.Error(w, "user_id query param required", http.StatusBadRequest)
+		return
+	}
+
+	if len(parts) == 2 {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		in, err := h.store.GetIncident(userID, id)
+		writeIncident(w, in, err, "GetIncident")
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		By      string `json:"by"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		configs.DBLogger.Println("❌ IncidentsHandler POST: decode error:", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.By == "" {
+		http.Error(w, "by is required", http.StatusBadRequest)
+		return
+	}
+
+	var in Incident
+	switch parts[2] {
+	case "ack":
+		in, err = h.store.AcknowledgeIncident(userID, id, req.By, req.Message)
+	case "resolve":
+		in, err = h.store.ResolveIncident(userID, id, req.By, req.Message)
+	case "comments":
+		if strings.TrimSpace(req.Message) == "" {
+			http.Error(w, "message is required", http.StatusBadRequest)
+			return
+		}
+		in, err = h.store.CommentIncident(userID, id, req.By, req.Message)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if err == nil {
+		configs.DBLogger.Printf("✅ incident %d: %s by %s", id, parts[2], req.By)
+	}
+	writeIncident(w, in, err, parts[2])
+}
+
+func (h *Handler) openIncident(w http.ResponseWriter, r *http.Request) {
+	var in Incident
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		configs.DBLogger.Println("❌ IncidentsHandler POST: decode error:", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if in.SiteID == 0 {
+		http.Error(w, "site_id is required", http.StatusBadRequest)
+		return
+	}
+	if in.OpenedAt.IsZero() {
+		in.OpenedAt = time.Now().UTC()
+	}
+	if in.FirstFailureAt.IsZero() {
+		in.FirstFailureAt = in.OpenedAt
+	}
+	configs.DBLogger.Printf("📥 OpenIncident site_id=%d error=%q", in.SiteID, in.RootError)
+
+	opened, created, err := h.store.OpenIncident(in)
+	if err != nil {
+		configs.DBLogger.Println("❌ IncidentsHandler POST: OpenIncident error:", err)
+		http.Error(w, fmt.Sprintf("Error opening incident: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		configs.DBLogger.Printf("✅ incident %d opened for site_id=%d", opened.ID, opened.SiteID)
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(opened)
+}
+
+func (h *Handler) listIncidents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	userID, err := strconv.Atoi(query.Get("user_id"))
+	if err != nil || userID == 0 {
+		http.Error(w, "user_id query param required", http.StatusBadRequest)
+		return
+	}
+	var siteID int
+	if v := query.Get("site_id"); v != "" {
+		if siteID, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "Invalid site_id", http.StatusBadRequest)
+			return
+		}
+	}
+	status := query.Get("status")
+	if _, ok := incidentStatusFilters[status]; status != "" && !ok {
+		http.Error(w, "status must be one of open, acknowledged, resolved", http.StatusBadRequest)
+		return
+	}
+
+	incidents, err := h.store.GetIncidents(userID, siteID, status)
+	if err != nil {
+		configs.DBLogger.Println("❌ IncidentsHandler GET: GetIncidents error:", err)
+		http.Error(w, fmt.Sprintf("Error getting incidents: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(incidents)
+}
+
+// writeIncident отдаёт инцидент или переводит ошибку хранилища в статус ответа.
+func writeIncident(w http.ResponseWriter, in Incident, err error, op string) {
+	switch {
+	case errors.Is(err, ErrIncidentNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, ErrIncidentResolved), errors.Is(err, ErrIncidentAcknowledged):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		configs.DBLogger.Printf("❌ IncidentsHandler %s error: %v", op, err)
+		http.Error(w, fmt.Sprintf("Error updating incident: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(in)
+}
+
+// SiteIncidentHandler ведёт незакрытый инцидент сайта по результатам проверок:
+//
+//	POST /site-incident/{site_id}/failure {"at"} - очередная неудачная проверка, 204
+//	POST /site-incident/{site_id}/resolve {"at"} - сайт восстановился; 404, если инцидента нет
+func (h *Handler) SiteIncidentHandler(w http.ResponseWriter, r *http.Request) {
+	configs.DBLogger.Printf("➡️ SiteIncidentHandler %s %s", r.Method, r.URL.String())
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 3 {
+		http.Error(w, "Invalid URL", http.StatusBadRequest)
+		return
+	}
+	siteID, err := strconv.Atoi(parts[1])
+	if err != nil {
+		http.Error(w, "Invalid site id", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		At time.Time `json:"at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		configs.DBLogger.Println("❌ SiteIncidentHandler: decode error:", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.At.IsZero() {
+		req.At = time.Now().UTC()
+	}
+
+	switch parts[2] {
+	case "failure":
+		if err := h.store.RecordIncidentFailure(siteID, req.At); err != nil {
+			configs.DBLogger.Println("❌ SiteIncidentHandler: RecordIncidentFailure error:", err)
+			http.Error(w, fmt.Sprintf("Error updating incident: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case "resolve":
+		in, err := h.store.ResolveSiteIncident(siteID, req.At)
+		if err == nil {
+			configs.DBLogger.Printf("✅ incident %d resolved, site_id=%d recovered", in.ID, siteID)
+		}
+		writeIncident(w, in, err, "ResolveSiteIncident")
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"database/sql"
+	"db_service/configs"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Lease - аренда именованной роли (например, планировщика) одной из реплик
+type Lease struct {
+	Name      string    `json:"name"`
+	Holder    string    `json:"holder"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Acquired  bool      `json:"acquired"` // запросивший держит аренду после этого вызова
+}
+
+// AcquireLease захватывает или продлевает аренду. Чужую аренду можно забрать,
+// только когда она истекла. Время считается по часам Postgres, поэтому
+// расхождение часов между репликами не влияет на выбор лидера.
+func (s *Storage) AcquireLease(name, holder string, ttl time.Duration) (Lease, error) {
+	lease := Lease{Name: name}
+	err := s.psql.QueryRow(`
+		INSERT INTO leases (name, holder, expires_at)
+		VALUES ($1, $2, NOW() AT TIME ZONE 'UTC' + $3::double precision * INTERVAL '1 millisecond')
+		ON CONFLICT (name) DO UPDATE
+		SET holder = EXCLUDED.holder, expires_at = EXCLUDED.expires_at
+		WHERE leases.holder = EXCLUDED.holder OR leases.expires_at < NOW() AT TIME ZONE 'UTC'
+		RETURNING holder, expires_at
+	`, name, holder, ttl.Milliseconds()).Scan(&lease.Holder, &lease.ExpiresAt)
+	if err == nil {
+		lease.Acquired = true
+		return lease, nil
+	}
+	if err != sql.ErrNoRows {
+		return lease, err
+	}
+
+	// Аренду держит другая реплика
+	err = s.psql.QueryRow(`SELECT holder, expires_at FROM leases WHERE name = $1`, name).
+		Scan(&lease.Holder, &lease.ExpiresAt)
+	return lease, err
+}
+
+// ReleaseLease отдаёт аренду, если её держит holder, чтобы другая реплика
+// могла забрать роль, не дожидаясь истечения.
+func (s *Storage) ReleaseLease(name, holder string) error {
+	_, err := s.psql.Exec(`DELETE FROM leases WHERE name = $1 AND holder = $2`, name, holder)
+	return err
+}
+
+// LeaseHandler: POST /lease/{name} - захватить или продлить аренду,
+// DELETE /lease/{name}?holder=... - отдать её.
+func (h *Handler) LeaseHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/lease/")
+	if name == "" || strings.Contains(name, "/") {
+		http.Error(w, "Invalid URL", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		var req struct {
+			Holder string `json:"holder"`
+			TTLMs  int64  `json:"ttl_ms"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			configs.DBLogger.Println("❌ LeaseHandler POST: decode error:", err)
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if req.Holder == "" || req.TTLMs <= 0 {
+			http.Error(w, "holder and positive ttl_ms are required", http.StatusBadRequest)
+			return
+		}
+
+		lease, err := h.store.AcquireLease(name, req.Holder, time.Duration(req.TTLMs)*time.Millisecond)
+		if err != nil {
+			configs.DBLogger.Println("❌ LeaseHandler POST: AcquireLease error:", err)
+			http.Error(w, fmt.Sprintf("Error acquiring lease: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(lease)
+
+	case http.MethodDelete:
+		holder := r.URL.Query().Get("holder")
+		if holder == "" {
+			http.Error(w, "holder is required", http.StatusBadRequest)
+			return
+		}
+		if err := h.store.ReleaseLease(name, holder); err != nil {
+			configs.DBLogger.Println("❌ LeaseHandler DELETE: ReleaseLease error:", err)
+			http.Error(w, fmt.Sprintf("Error releasing lease: %v", err), http.StatusInternalServerError)
+			return
+		}
+		configs.DBLogger.Printf("✅ lease %s released by %s", name, holder)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,475 @@
+package internal
+
+import (
+	"database/sql"
+	"db_service/configs"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrMaintenanceNotFound = errors.New("maintenance window not found")
+
+// Повторение окна обслуживания
+const (
+	RecurrenceNone   = ""
+	RecurrenceDaily  = "daily"
+	RecurrenceWeekly = "weekly"
+)
+
+const (
+	maxMaintenanceSites = 100
+	maxMaintenanceRange = 31 * 24 * time.Hour // окно выборки /maintenance/active
+)
+
+var recurrencePeriods = map[string]time.Duration{
+	RecurrenceDaily:  24 * time.Hour,
+	RecurrenceWeekly: 7 * 24 * time.Hour,
+}
+
+// MaintenanceWindow - плановые работы: пока идёт окно, проверки мониторов
+// пишутся в ping_logs с пометкой maintenance, а алерты не отправляются.
+// Повторяющееся окно начинается каждые сутки или неделю в то же время (UTC),
+// что и первое, последний раз - не позже Until.
+type MaintenanceWindow struct {
+	ID         int        `json:"id"`
+	Title      string     `json:"title"`
+	StartsAt   time.Time  `json:"starts_at"`
+	EndsAt     time.Time  `json:"ends_at"`
+	Recurrence string     `json:"recurrence"` // "" | daily | weekly
+	Until      *time.Time `json:"until,omitempty"`
+	SiteIDs    []int      `json:"site_ids"`  // пусто - все мониторы пользователя
+	AllSites   bool       `json:"all_sites"` // вычисляется из site_ids
+	CreatedAt  time.Time  `json:"created_at"`
+	UserID     int        `json:"-"`
+}
+
+// MaintenanceOccurrence - одно конкретное окно, по нему api_service глушит алерты
+type MaintenanceOccurrence struct {
+	WindowID int       `json:"window_id"`
+	UserID   int       `json:"user_id"`
+	AllSites bool      `json:"all_sites"`
+	SiteIDs  []int     `json:"site_ids"`
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+}
+
+// occurrences возвращает повторы окна, пересекающиеся с [from, to).
+func (w MaintenanceWindow) occurrences(from, to time.Time) []MaintenanceOccurrence {
+	dur := w.EndsAt.Sub(w.StartsAt)
+	period, recurring := recurrencePeriods[w.Recurrence]
+	if !recurring {
+		// Разовое окно - единственный повтор
+		period = 0
+	}
+
+	var k int64
+	if recurring && from.After(w.EndsAt) {
+		k = int64(from.Sub(w.EndsAt) / period)
+	}
+
+	occ := []MaintenanceOccurrence{}
+	for ; ; k++ {
+		start := w.StartsAt.Add(time.Duration(k) * period)
+		if !start.Before(to) || (w.Until != nil && start.After(*w.Until)) {
+			break
+		}
+		if end := start.Add(dur); end.After(from) {
+			occ = append(occ, MaintenanceOccurrence{
+				WindowID: w.ID,
+				UserID:   w.UserID,
+				AllSites: w.AllSites,
+				SiteIDs:  w.SiteIDs,
+				StartsAt: start,
+				EndsAt:   end,
+			})
+		}
+		if !recurring {
+			break
+		}
+	}
+	return occ
+}
+
+// covers - попадает ли проверка сайта в момент at в окно
+func (o MaintenanceOccurrence) covers(siteID int, at time.Time) bool {
+	if at.Before(o.StartsAt) || !at.Before(o.EndsAt) {
+		return false
+	}
+	if o.AllSites {
+		return true
+	}
+	for _, id := range o.SiteIDs {
+		if id == siteID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Storage) CreateMaintenanceWindow(userID int, w MaintenanceWindow) (MaintenanceWindow, error) {
+	tx, err := s.psql.Begin()
+	if err != nil {
+		return w, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO maintenance_windows (user_id, title, starts_at, ends_at, recurrence, until, all_sites)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id
+	`, userID, w.Title, w.StartsAt.UTC(), w.EndsAt.UTC(), w.Recurrence, utcOrNil(w.Until), len(w.SiteIDs) == 0).Scan(&id)
+	if err != nil {
+		return w, err
+	}
+	if err := setMaintenanceSites(tx, userID, id, w.SiteIDs); err != nil {
+		return w, err
+	}
+	if err := tx.Commit(); err != nil {
+		return w, err
+	}
+	return s.GetMaintenanceWindow(userID, id)
+}
+
+// UpdateMaintenanceWindow заменяет окно целиком, включая состав мониторов.
+func (s *Storage) UpdateMaintenanceWindow(userID, id int, w MaintenanceWindow) (MaintenanceWindow, error) {
+	tx, err := s.psql.Begin()
+	if err != nil {
+		return w, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE maintenance_windows
+		SET title = $3, starts_at = $4, ends_at = $5, recurrence = $6, until = $7, all_sites = $8
+		WHERE id = $1 AND user_id = $2
+	`, id, userID, w.Title, w.StartsAt.UTC(), w.EndsAt.UTC(), w.Recurrence, utcOrNil(w.Until), len(w.SiteIDs) == 0)
+	if err != nil {
+		return w, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return w, ErrMaintenanceNotFound
+	}
+	if err := setMaintenanceSites(tx, userID, id, w.SiteIDs); err != nil {
+		return w, err
+	}
+	if err := tx.Commit(); err != nil {
+		return w, err
+	}
+	return s.GetMaintenanceWindow(userID, id)
+}
+
+// setMaintenanceSites заменяет мониторы окна; как и на странице статуса,
+// указать можно только свои сайты.
+func setMaintenanceSites(tx *sql.Tx, userID, windowID int, siteIDs []int) error {
+	if _, err := tx.Exec(`DELETE FROM maintenance_window_sites WHERE window_id = $1`, windowID); err != nil {
+		return err
+	}
+	for _, siteID := range siteIDs {
+		res, err := tx.Exec(`
+			INSERT INTO maintenance_window_sites (window_id, site_id)
+			SELECT $1, id FROM user_sites WHERE id = $2 AND user_id = $3
+		`, windowID, siteID, userID)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return fmt.Errorf("%w: %d", ErrSiteNotFound, siteID)
+		}
+	}
+	return nil
+}
+
+func (s *Storage) DeleteMaintenanceWindow(userID, id int) error {
+	res, err := s.psql.Exec(`DELETE FROM maintenance_windows WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrMaintenanceNotFound
+	}
+	return nil
+}
+
+func (s *Storage) GetMaintenanceWindow(userID, id int) (MaintenanceWindow, error) {
+	windows, err := s.getMaintenanceWindows(`m.user_id = $1 AND m.id = $2`, userID, id)
+	if err != nil {
+		return MaintenanceWindow{}, err
+	}
+	if len(windows) == 0 {
+		return MaintenanceWindow{}, ErrMaintenanceNotFound
+	}
+	return windows[0], nil
+}
+
+func (s *Storage) GetMaintenanceWindows(userID int) ([]MaintenanceWindow, error) {
+	return s.getMaintenanceWindows(`m.user_id = $1`, userID)
+}
+
+// GetMaintenanceOccurrences возвращает окна, идущие в [from, to); userID 0 - всех пользователей.
+func (s *Storage) GetMaintenanceOccurrences(userID int, from, to time.Time) ([]MaintenanceOccurrence, error) {
+	// Грубый отбор в SQL, точный - в occurrences: повтор, начавшийся не позже
+	// until, может закончиться и после него
+	where := `m.starts_at < $1 AND (m.recurrence <> '' OR m.ends_at > $2)
+		AND (m.until IS NULL OR m.until + (m.ends_at - m.starts_at) > $2)`
+	args := []any{to.UTC(), from.UTC()}
+	if userID != 0 {
+		where += ` AND m.user_id = $3`
+		args = append(args, userID)
+	}
+	windows, err := s.getMaintenanceWindows(where, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	occ := []MaintenanceOccurrence{}
+	for _, w := range windows {
+		occ = append(occ, w.occurrences(from, to)...)
+	}
+	return occ, nil
+}
+
+func (s *Storage) getMaintenanceWindows(where string, args ...any) ([]MaintenanceWindow, error) {
+	rows, err := s.psql.Query(`
+		SELECT m.id, m.user_id, m.title, m.starts_at, m.ends_at, m.recurrence, m.until, m.all_sites, m.created_at, ms.site_id
+		FROM maintenance_windows m
+		LEFT JOIN maintenance_window_sites ms ON ms.window_id = m.id
+		WHERE `+where+`
+		ORDER BY m.id, ms.site_id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	windows := []MaintenanceWindow{}
+	for rows.Next() {
+		var w MaintenanceWindow
+		var until sql.NullTime
+		var siteID sql.NullInt64
+		if err := rows.Scan(&w.ID, &w.UserID, &w.Title, &w.StartsAt, &w.EndsAt, &w.Recurrence, &until,
+			&w.AllSites, &w.CreatedAt, &siteID); err != nil {
+			return nil, err
+		}
+		if n := len(windows); n == 0 || windows[n-1].ID != w.ID {
+			w.StartsAt, w.EndsAt = w.StartsAt.UTC(), w.EndsAt.UTC()
+			if until.Valid {
+				u := until.Time.UTC()
+				w.Until = &u
+			}
+			w.SiteIDs = []int{}
+			windows = append(windows, w)
+		}
+		if siteID.Valid {
+			last := &windows[len(windows)-1]
+			last.SiteIDs = append(last.SiteIDs, int(siteID.Int64))
+		}
+	}
+	return windows, rows.Err()
+}
+
+func utcOrNil(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
+
+// MaintenanceWindowsHandler - окна обслуживания пользователя:
+//
+//	GET  /maintenance-windows?user_id=
+//	POST /maintenance-windows?user_id=    {"title", "starts_at", "ends_at", "recurrence", "until", "site_ids"}
+//	GET/PUT/DELETE /maintenance-windows/{id}?user_id=
+func (h *Handler) MaintenanceWindowsHandler(w http.ResponseWriter, r *http.Request) {
+	configs.DBLogger.Printf("➡️ MaintenanceWindowsHandler %s %s", r.Method, r.URL.String())
+
+	userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+	if err != nil || userID == 0 {
+		http.Error(w, "user_id query param required", http.StatusBadRequest)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) > 2 {
+		http.Error(w, "Invalid URL", http.StatusBadRequest)
+		return
+	}
+
+	if len(parts) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			windows, err := h.store.GetMaintenanceWindows(userID)
+			if err != nil {
+				configs.DBLogger.Println("❌ MaintenanceWindowsHandler GET: GetMaintenanceWindows error:", err)
+				http.Error(w, fmt.Sprintf("Error getting maintenance windows: %v", err), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(windows)
+		case http.MethodPost:
+			mw, ok := decodeMaintenanceWindow(w, r)
+			if !ok {
+				return
+			}
+			mw, err := h.store.CreateMaintenanceWindow(userID, mw)
+			if err != nil {
+				writeMaintenanceWindow(w, mw, err)
+				return
+			}
+			configs.DBLogger.Printf("✅ maintenance window %d created for user_id=%d", mw.ID, userID)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(mw)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	id, err := strconv.Atoi(parts[1])
+	if err != nil {
+		http.Error(w, "Invalid maintenance window id", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		mw, err := h.store.GetMaintenanceWindow(userID, id)
+		writeMaintenanceWindow(w, mw, err)
+	case http.MethodPut:
+		mw, ok := decodeMaintenanceWindow(w, r)
+		if !ok {
+			return
+		}
+		mw, err := h.store.UpdateMaintenanceWindow(userID, id, mw)
+		writeMaintenanceWindow(w, mw, err)
+	case http.MethodDelete:
+		if err := h.store.DeleteMaintenanceWindow(userID, id); err != nil {
+			writeMaintenanceWindow(w, MaintenanceWindow{}, err)
+			return
+		}
+		configs.DBLogger.Printf("✅ maintenance window %d deleted", id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// decodeMaintenanceWindow читает и проверяет тело запроса; при ошибке сам отвечает клиенту.
+func decodeMaintenanceWindow(w http.ResponseWriter, r *http.Request) (MaintenanceWindow, bool) {
+	var mw MaintenanceWindow
+	if err := json.NewDecoder(r.Body).Decode(&mw); err != nil {
+		configs.DBLogger.Println("❌ MaintenanceWindowsHandler: decode error:", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return mw, false
+	}
+	if err := validateMaintenanceWindow(&mw); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return mw, false
+	}
+	return mw, true
+}
+
+func validateMaintenanceWindow(mw *MaintenanceWindow) error {
+	mw.Title = strings.TrimSpace(mw.Title)
+	if len(mw.Title) > 255 {
+		return errors.New("title must be at most 255 characters")
+	}
+	if mw.StartsAt.IsZero() || mw.EndsAt.IsZero() {
+		return errors.New("starts_at and ends_at are required")
+	}
+	if !mw.StartsAt.Before(mw.EndsAt) {
+		return errors.New("ends_at must be after starts_at")
+	}
+
+	period, recurring := recurrencePeriods[mw.Recurrence]
+	switch {
+	case mw.Recurrence != RecurrenceNone && !recurring:
+		return errors.New("recurrence must be one of daily, weekly or empty")
+	case recurring && mw.EndsAt.Sub(mw.StartsAt) > period:
+		return fmt.Errorf("a %s window must not be longer than its period", mw.Recurrence)
+	case !recurring && mw.Until != nil:
+		return errors.New("until is only allowed for recurring windows")
+	case mw.Until != nil && mw.Until.Before(mw.StartsAt):
+		return errors.New("until must not be before starts_at")
+	}
+
+	if len(mw.SiteIDs) > maxMaintenanceSites {
+		return fmt.Errorf("at most %d sites per window", maxMaintenanceSites)
+	}
+	seen := map[int]bool{}
+	for _, id := range mw.SiteIDs {
+		if seen[id] {
+			return fmt.Errorf("duplicate site_id %d", id)
+		}
+		seen[id] = true
+	}
+	if mw.SiteIDs == nil {
+		mw.SiteIDs = []int{}
+	}
+	return nil
+}
+
+func writeMaintenanceWindow(w http.ResponseWriter, mw MaintenanceWindow, err error) {
+	switch {
+	case errors.Is(err, ErrMaintenanceNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, ErrSiteNotFound):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		configs.DBLogger.Println("❌ MaintenanceWindowsHandler error:", err)
+		http.Error(w, fmt.Sprintf("Error saving maintenance window: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mw)
+}
+
+// MaintenanceActiveHandler: GET /maintenance/active?from=&to=[&user_id=]
+// Окна всех пользователей, идущие в [from, to): по ним api_service решает,
+// глушить ли алерты проверки.
+func (h *Handler) MaintenanceActiveHandler(w http.ResponseWriter, r *http.Request) {
+	configs.DBLogger.Printf("➡️ MaintenanceActiveHandler %s %s", r.Method, r.URL.String())
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	from, errFrom := time.Parse(time.RFC3339, query.Get("from"))
+	to, errTo := time.Parse(time.RFC3339, query.Get("to"))
+	if errFrom != nil || errTo != nil || !from.Before(to) {
+		http.Error(w, "from and to must be RFC3339 and from < to", http.StatusBadRequest)
+		return
+	}
+	if to.Sub(from) > maxMaintenanceRange {
+		http.Error(w, fmt.Sprintf("range must be at most %d days", int(maxMaintenanceRange.Hours()/24)), http.StatusBadRequest)
+		return
+	}
+	var userID int
+	if v := query.Get("user_id"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid user_id", http.StatusBadRequest)
+			return
+		}
+		userID = n
+	}
+
+	occ, err := h.store.GetMaintenanceOccurrences(userID, from.UTC(), to.UTC())
+	if err != nil {
+		configs.DBLogger.Println("❌ MaintenanceActiveHandler: GetMaintenanceOccurrences error:", err)
+		http.Error(w, fmt.Sprintf("Error getting maintenance windows: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(occ)
+}
@@ -1,9 +1,43 @@
 package internal
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type SiteInfo struct {
 	ID            int    `json:"id"`
 	URL           string `json:"url"`
 	CheckInterval int    `json:"check_interval"`
+	// Сайт считается упавшим после FailureThreshold неудач подряд
+	// или WindowFailures неудач среди последних WindowSize проверок (0 - не используется)
+	FailureThreshold int `json:"failure_threshold"`
+	WindowSize       int `json:"window_size"`
+	WindowFailures   int `json:"window_failures"`
+	// Проверки тела ответа, хранятся как есть и передаются в ping_service
+	Assertions json.RawMessage `json:"assertions,omitempty"`
+	// HTTP-запрос проверки; пустой ExpectedStatus - успехом считается любой код < 400
+	Method         string          `json:"method"`
+	Headers        json.RawMessage `json:"headers,omitempty"`
+	Body           string          `json:"body,omitempty"`
+	ExpectedStatus json.RawMessage `json:"expected_status,omitempty"`
+	// Тип проверки: http (по умолчанию), tcp, icmp или dns; для tcp URL - это host:port, для icmp и dns - хост
+	Type      string `json:"type"`
+	TimeoutMs int    `json:"timeout_ms,omitempty"`
+	Banner    string `json:"banner,omitempty"`
+	// Для icmp: число echo-запросов в серии и пауза между ними
+	Count      int `json:"count,omitempty"`
+	IntervalMs int `json:"interval_ms,omitempty"`
+	// Для dns: тип записи, резолвер host:port и ожидаемый набор записей
+	RecordType string          `json:"record_type,omitempty"`
+	Resolver   string          `json:"resolver,omitempty"`
+	Expected   json.RawMessage `json:"expected,omitempty"`
+	// Для heartbeat: токен push-URL, допустимое опоздание сигнала (сек) и время последнего сигнала
+	HeartbeatToken  string     `json:"heartbeat_token,omitempty"`
+	GracePeriod     int        `json:"grace_period,omitempty"`
+	LastHeartbeatAt *time.Time `json:"last_heartbeat_at,omitempty"`
+	// Для multistep: шаги проверки, хранятся как есть и передаются в ping_service
+	Steps json.RawMessage `json:"steps,omitempty"`
 }
 
 type UserSites struct {
@@ -0,0 +1,197 @@
+package internal
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultLogLimit = 500
+	maxLogLimit     = 5000
+	maxLogBuckets   = 5000           // точек на графике за один запрос
+	defaultLogRange = 24 * time.Hour // окно агрегатов, если не задан from
+)
+
+// logBuckets - допустимые шаги агрегатов
+var logBuckets = map[string]time.Duration{
+	"1m": time.Minute,
+	"5m": 5 * time.Minute,
+	"1h": time.Hour,
+}
+
+// LogQuery - фильтры и страница для логов сайта
+type LogQuery struct {
+	From, To time.Time // пустое значение - без ограничения
+	Region   string
+	Limit    int
+	Cursor   *logCursor
+	Bucket   time.Duration // 0 - сырые логи
+}
+
+// logCursor - место, с которого продолжается выдача: логи не новее Before,
+// из которых Skip самых новых уже отданы (у логов одной проверки из разных
+// регионов одинаковый req_time, поэтому одного времени недостаточно).
+type logCursor struct {
+	Before time.Time
+	Skip   int
+}
+
+func (c logCursor) encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", c.Before.Unix(), c.Skip)))
+}
+
+func decodeLogCursor(s string) (*logCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	sec, skip, ok := strings.Cut(string(raw), ":")
+	unix, err1 := strconv.ParseInt(sec, 10, 64)
+	n, err2 := strconv.Atoi(skip)
+	if !ok || err1 != nil || err2 != nil || n < 0 {
+		return nil, errors.New("invalid cursor")
+	}
+	return &logCursor{Before: time.Unix(unix, 0).UTC(), Skip: n}, nil
+}
+
+// parseLogQuery разбирает from/to (RFC3339), region, limit, cursor и bucket.
+func parseLogQuery(query url.Values, now time.Time) (LogQuery, error) {
+	q := LogQuery{Region: query.Get("region"), Limit: defaultLogLimit}
+
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &q.From}, {"to", &q.To}} {
+		if v := query.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return q, fmt.Errorf("%s must be RFC3339", p.name)
+			}
+			*p.dst = t.UTC()
+		}
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return q, errors.New("from must be before to")
+	}
+
+	if v := query.Get("bucket"); v != "" {
+		d, ok := logBuckets[v]
+		if !ok {
+			return q, fmt.Errorf("bucket must be one of 1m, 5m, 1h")
+		}
+		q.Bucket = d
+		if query.Get("cursor") != "" || query.Get("limit") != "" {
+			return q, errors.New("cursor and limit are not supported with bucket")
+		}
+		if q.To.IsZero() {
+			q.To = now
+		}
+		if q.From.IsZero() {
+			q.From = q.To.Add(-defaultLogRange)
+		}
+		if q.To.Sub(q.From)/d > maxLogBuckets {
+			return q, fmt.Errorf("too many buckets: at most %d per request, use a larger bucket or a shorter range", maxLogBuckets)
+		}
+		return q, nil
+	}
+
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxLogLimit {
+			return q, fmt.Errorf("limit must be between 1 and %d", maxLogLimit)
+		}
+		q.Limit = n
+	}
+	if v := query.Get("cursor"); v != "" {
+		c, err := decodeLogCursor(v)
+		if err != nil {
+			return q, err
+		}
+		q.Cursor = c
+	}
+	return q, nil
+}
+
+// where собирает условие по сайту и фильтрам запроса.
+func (q LogQuery) where(userID int, site string) (string, []any) {
+	conds := []string{"user_id = ?", "site = ?"}
+	args := []any{userID, site}
+	if !q.From.IsZero() {
+		conds = append(conds, "req_time >= ?")
+		args = append(args, q.From)
+	}
+	if !q.To.IsZero() {
+		conds = append(conds, "req_time < ?")
+		args = append(args, q.To)
+	}
+	if q.Region != "" {
+		conds = append(conds, "region = ?")
+		args = append(args, q.Region)
+	}
+	if q.Cursor != nil {
+		conds = append(conds, "req_time <= ?")
+		args = append(args, q.Cursor.Before)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// LogBucket - агрегат логов сайта за интервал
+type LogBucket struct {
+	Time   time.Time `json:"time"` // начало интервала
+	Checks int       `json:"checks"`
+	Failed int       `json:"failed"`
+	// Время ответа удачных проверок, мс; null - удачных не было
+	MinMs *int64   `json:"min_ms"`
+	AvgMs *float64 `json:"avg_ms"`
+	MaxMs *int64   `json:"max_ms"`
+}
+
+// GetLogBuckets агрегирует логи сайта по интервалам q.Bucket; пустые интервалы не возвращаются.
+func (s *Storage) GetLogBuckets(userID, siteID int, q LogQuery) ([]LogBucket, error) {
+	var site string
+	err := s.psql.QueryRow(`
+		SELECT site FROM user_sites WHERE id = $1 AND user_id = $2
+	`, siteID, userID).Scan(&site)
+	if err != nil {
+		return nil, err
+	}
+
+	where, args := q.where(userID, site)
+	ok := `status = 'ok' AND resp_time >= 0`
+	// Шаг берётся из logBuckets, поэтому его можно подставить в текст запроса
+	rows, err := s.ch.Query(fmt.Sprintf(`
+		SELECT toStartOfInterval(req_time, INTERVAL %d SECOND) AS bucket,
+			count(), countIf(status = 'bad'), countIf(%s),
+			minIf(resp_time, %s), avgIf(resp_time, %s), maxIf(resp_time, %s)
+		FROM ping_logs
+		WHERE %s
+		GROUP BY bucket
+		ORDER BY bucket
+	`, int(q.Bucket.Seconds()), ok, ok, ok, ok, where), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := []LogBucket{}
+	for rows.Next() {
+		var b LogBucket
+		var checks, failed, okChecks uint64
+		var minMs, maxMs int64
+		var avgMs float64
+		if err := rows.Scan(&b.Time, &checks, &failed, &okChecks, &minMs, &avgMs, &maxMs); err != nil {
+			return nil, err
+		}
+		b.Checks, b.Failed = int(checks), int(failed)
+		if okChecks > 0 {
+			b.MinMs, b.AvgMs, b.MaxMs = &minMs, &avgMs, &maxMs
+		}
+		buckets = append(buckets, b)
+	}
+	return buckets, rows.Err()
+}
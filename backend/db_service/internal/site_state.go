@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"database/sql"
+	"db_service/configs"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrStateNotFound = errors.New("site state not found")
+
+// SiteState - последнее известное состояние сайта и счётчики для порогов срабатывания
+type SiteState struct {
+	SiteID              int        `json:"site_id"`
+	Status              string     `json:"status"` // up | down
+	ChangedAt           time.Time  `json:"changed_at"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	RecentResults       string     `json:"recent_results"` // последние проверки, "1" - неудача, "0" - успех; новые в конце
+	FailingSince        *time.Time `json:"failing_since,omitempty"`
+	// Последний увиденный сертификат и отправленные по нему предупреждения
+	CertNotAfter   *time.Time `json:"cert_not_after,omitempty"`
+	CertWarnedDays int        `json:"cert_warned_days"` // наименьший порог (дней), о котором уже предупредили; 0 - не предупреждали
+	CertError      string     `json:"cert_error,omitempty"`
+	DNSRecords     string     `json:"dns_records,omitempty"` // последние записи dns-проверки через \n
+	DNSSeen        bool       `json:"dns_seen"`              // dns-проверка уже была; DNSRecords может быть пустым
+	// Когда api_service последний раз засчитал пропущенный heartbeat
+	HeartbeatMissedAt *time.Time `json:"heartbeat_missed_at,omitempty"`
+}
+
+func (s *Storage) GetSiteState(siteID int) (SiteState, error) {
+	st := SiteState{SiteID: siteID}
+	err := s.psql.QueryRow(`
+		SELECT status, changed_at, consecutive_failures, recent_results, failing_since,
+			cert_not_after, cert_warned_days, cert_error, dns_records, dns_seen, heartbeat_missed_at
+		FROM site_states WHERE site_id = $1
+	`, siteID).Scan(&st.Status, &st.ChangedAt, &st.ConsecutiveFailures, &st.RecentResults, &st.FailingSince,
+		&st.CertNotAfter, &st.CertWarnedDays, &st.CertError, &st.DNSRecords, &st.DNSSeen, &st.HeartbeatMissedAt)
+	if err == sql.ErrNoRows {
+		return st, ErrStateNotFound
+	}
+	return st, err
+}
+
+func (s *Storage) SaveSiteState(st SiteState) error {
+	_, err := s.psql.Exec(`
+		INSERT INTO site_states (site_id, status, changed_at, consecutive_failures, recent_results, failing_since,
+			cert_not_after, cert_warned_days, cert_error, dns_records, dns_seen, heartbeat_missed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (site_id) DO UPDATE
+		SET status = EXCLUDED.status,
+			changed_at = EXCLUDED.changed_at,
+			consecutive_failures = EXCLUDED.consecutive_failures,
+			recent_results = EXCLUDED.recent_results,
+			failing_since = EXCLUDED.failing_since,
+			cert_not_after = EXCLUDED.cert_not_after,
+			cert_warned_days = EXCLUDED.cert_warned_days,
+			cert_error = EXCLUDED.cert_error,
+			dns_records = EXCLUDED.dns_records,
+			dns_seen = EXCLUDED.dns_seen,
+			heartbeat_missed_at = EXCLUDED.heartbeat_missed_at
+	`, st.SiteID, st.Status, st.ChangedAt, st.ConsecutiveFailures, st.RecentResults, st.FailingSince,
+		st.CertNotAfter, st.CertWarnedDays, st.CertError, st.DNSRecords, st.DNSSeen, st.HeartbeatMissedAt)
+	return err
+}
+
+// SiteStateHandler: GET/PUT /site-state/{site_id}
+func (h *Handler) SiteStateHandler(w http.ResponseWriter, r *http.Request) {
+	configs.DBLogger.Printf("➡️ SiteStateHandler %s %s", r.Method, r.URL.String())
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 2 {
+		configs.DBLogger.Printf("❌ SiteStateHandler: invalid URL %s", r.URL.Path)
+		http.Error(w, "Invalid URL", http.StatusBadRequest)
+		return
+	}
+	siteID, err := strconv.Atoi(parts[1])
+	if err != nil {
+		configs.DBLogger.Printf("❌ SiteStateHandler: invalid site id %q", parts[1])
+		http.Error(w, "Invalid site id", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		st, err := h.store.GetSiteState(siteID)
+		if errors.Is(err, ErrStateNotFound) {
+			http.Error(w, "state not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			configs.DBLogger.Println("❌ SiteStateHandler GET: GetSiteState error:", err)
+			http.Error(w, fmt.Sprintf("Error getting state: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(st)
+
+	case http.MethodPut:
+		var st SiteState
+		if err := json.NewDecoder(r.Body).Decode(&st); err != nil {
+			configs.DBLogger.Println("❌ SiteStateHandler PUT: decode error:", err)
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if st.Status == "" {
+			http.Error(w, "status is required", http.StatusBadRequest)
+			return
+		}
+		st.SiteID = siteID
+		if st.ChangedAt.IsZero() {
+			st.ChangedAt = time.Now().UTC()
+		}
+		configs.DBLogger.Printf("📥 SaveSiteState site_id=%d status=%s", siteID, st.Status)
+
+		if err := h.store.SaveSiteState(st); err != nil {
+			configs.DBLogger.Println("❌ SiteStateHandler PUT: SaveSiteState error:", err)
+			http.Error(w, fmt.Sprintf("Error saving state: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(st)
+
+	default:
+		configs.DBLogger.Printf("❌ SiteStateHandler: method not allowed %s", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,249 @@
+package internal
+
+import (
+	"database/sql"
+	"db_service/configs"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SiteStats - показатели доступности сайта за окно [from, to).
+//
+// Проверкой считается набор логов с одинаковым req_time: при проверке из
+// нескольких регионов api_service пишет их с общим временем. Проверка
+// неудачна, если упала хотя бы в quorum регионах (или во всех, если ответило
+// меньше). Инцидент - серия неудачных проверок подряд.
+type SiteStats struct {
+	SiteID       int       `json:"site_id"`
+	Site         string    `json:"site"`
+	From         time.Time `json:"from"`
+	To           time.Time `json:"to"`
+	Region       string    `json:"region,omitempty"`
+	Checks       int       `json:"checks"`
+	FailedChecks int       `json:"failed_checks"`
+	UptimePct    *float64  `json:"uptime_pct"` // null - проверок в окне не было
+	Incidents    int       `json:"incidents"`
+	DowntimeSec  int64     `json:"downtime_sec"`
+	MTTRSec      *float64  `json:"mttr_sec"` // среднее время восстановления; null - ни один инцидент не закончился
+	MTBFSec      *float64  `json:"mtbf_sec"` // среднее время работы между инцидентами; null - инцидентов не было
+	// Время ответа успешных проверок, мс
+	P50Ms *float64 `json:"p50_ms"`
+	P95Ms *float64 `json:"p95_ms"`
+	P99Ms *float64 `json:"p99_ms"`
+	// Проверки в окнах обслуживания; в остальные показатели они не входят, если не запрошено include_maintenance
+	MaintenanceChecks int `json:"maintenance_checks"`
+}
+
+// StatsQuery - параметры расчёта
+type StatsQuery struct {
+	From, To time.Time
+	Region   string // пусто - все регионы
+	Quorum   int    // сколько регионов должны упасть, чтобы проверка считалась неудачной
+	// IncludeMaintenance - считать и проверки в окнах обслуживания
+	IncludeMaintenance bool
+}
+
+// GetSiteStats считает показатели агрегатами ClickHouse по ping_logs.
+func (s *Storage) GetSiteStats(userID, siteID int, q StatsQuery) (SiteStats, error) {
+	st := SiteStats{SiteID: siteID, From: q.From, To: q.To, Region: q.Region}
+	err := s.psql.QueryRow(`
+		SELECT site FROM user_sites WHERE id = $1 AND user_id = $2
+	`, siteID, userID).Scan(&st.Site)
+	if err != nil {
+		return st, err
+	}
+	if q.Quorum < 1 {
+		q.Quorum = 1
+	}
+
+	// Запись 'initial' появляется при добавлении сайта и проверкой не является
+	filter := `user_id = ? AND site = ? AND req_time >= ? AND req_time < ? AND status != 'initial'`
+	args := []any{userID, st.Site, q.From, q.To}
+	if q.Region != "" {
+		filter += ` AND region = ?`
+		args = append(args, q.Region)
+	}
+
+	err = s.ch.QueryRow(`
+		SELECT uniqExact(req_time) FROM ping_logs WHERE `+filter+` AND maintenance = 1
+	`, args...).Scan(&st.MaintenanceChecks)
+	if err != nil {
+		return st, fmt.Errorf("count maintenance checks: %w", err)
+	}
+	if !q.IncludeMaintenance {
+		filter += ` AND maintenance = 0`
+	}
+
+	checks := `
+		SELECT req_time, toInt8(countIf(status = 'bad') >= least(toUInt64(?), count())) AS failed
+		FROM ping_logs
+		WHERE ` + filter + `
+		GROUP BY req_time`
+	checkArgs := append([]any{q.Quorum}, args...)
+
+	var lastCheck time.Time
+	err = s.ch.QueryRow(`
+		SELECT count(), countIf(failed = 1), max(req_time)
+		FROM (`+checks+`)
+	`, checkArgs...).Scan(&st.Checks, &st.FailedChecks, &lastCheck)
+	if err != nil {
+		return st, fmt.Errorf("count checks: %w", err)
+	}
+	if st.Checks == 0 {
+		return st, nil
+	}
+	uptime := 100 * float64(st.Checks-st.FailedChecks) / float64(st.Checks)
+	st.UptimePct = &uptime
+
+	var pct []float64
+	err = s.ch.QueryRow(`
+		SELECT quantilesIf(0.5, 0.95, 0.99)(resp_time, status = 'ok' AND resp_time >= 0)
+		FROM ping_logs
+		WHERE `+filter, args...).Scan(&pct)
+	if err != nil {
+		return st, fmt.Errorf("response time quantiles: %w", err)
+	}
+	if len(pct) == 3 {
+		st.P50Ms, st.P95Ms, st.P99Ms = finite(pct[0]), finite(pct[1]), finite(pct[2])
+	}
+
+	// Только моменты смены состояния: начало каждой серии удачных или неудачных проверок
+	rows, err := s.ch.Query(`
+		SELECT req_time, failed
+		FROM (
+			SELECT req_time, failed,
+				lagInFrame(failed, 1, -1) OVER (ORDER BY req_time ROWS BETWEEN 1 PRECEDING AND CURRENT ROW) AS prev
+			FROM (`+checks+`)
+		)
+		WHERE failed != prev
+		ORDER BY req_time
+	`, checkArgs...)
+	if err != nil {
+		return st, fmt.Errorf("state changes: %w", err)
+	}
+	defer rows.Close()
+
+	type run struct {
+		start  time.Time
+		failed bool
+	}
+	var runs []run
+	for rows.Next() {
+		var r run
+		var failed int8
+		if err := rows.Scan(&r.start, &failed); err != nil {
+			return st, err
+		}
+		r.failed = failed == 1
+		runs = append(runs, r)
+	}
+	if err := rows.Err(); err != nil {
+		return st, err
+	}
+
+	// Серия длится до начала следующей; последняя - до последней проверки
+	var upSec, repairedSec int64
+	var repaired int
+	for i, r := range runs {
+		end := lastCheck
+		if i+1 < len(runs) {
+			end = runs[i+1].start
+		}
+		dur := int64(end.Sub(r.start).Seconds())
+		if !r.failed {
+			upSec += dur
+			continue
+		}
+		st.Incidents++
+		st.DowntimeSec += dur
+		if i+1 < len(runs) {
+			repaired++
+			repairedSec += dur
+		}
+	}
+	if repaired > 0 {
+		mttr := float64(repairedSec) / float64(repaired)
+		st.MTTRSec = &mttr
+	}
+	if st.Incidents > 0 {
+		mtbf := float64(upSec) / float64(st.Incidents)
+		st.MTBFSec = &mtbf
+	}
+	return st, nil
+}
+
+// finite превращает NaN, который quantilesIf возвращает на пустой выборке, в null.
+func finite(v float64) *float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	return &v
+}
+
+// StatsHandler: GET /stats/{site_id}?user_id=&from=&to=&quorum=&region=&include_maintenance=
+// from и to - RFC3339, окно выбирает api_service.
+func (h *Handler) StatsHandler(w http.ResponseWriter, r *http.Request) {
+	configs.DBLogger.Printf("➡️ StatsHandler %s %s", r.Method, r.URL.String())
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 2 {
+		http.Error(w, "Invalid URL", http.StatusBadRequest)
+		return
+	}
+	siteID, err := strconv.Atoi(parts[1])
+	if err != nil {
+		http.Error(w, "Invalid site id", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	userID, err := strconv.Atoi(query.Get("user_id"))
+	if err != nil || userID == 0 {
+		http.Error(w, "user_id query param required", http.StatusBadRequest)
+		return
+	}
+	from, errFrom := time.Parse(time.RFC3339, query.Get("from"))
+	to, errTo := time.Parse(time.RFC3339, query.Get("to"))
+	if errFrom != nil || errTo != nil || !from.Before(to) {
+		http.Error(w, "from and to must be RFC3339 and from < to", http.StatusBadRequest)
+		return
+	}
+	q := StatsQuery{From: from.UTC(), To: to.UTC(), Region: query.Get("region"), Quorum: 1}
+	if v := query.Get("include_maintenance"); v != "" {
+		if q.IncludeMaintenance, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "include_maintenance must be a boolean", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := query.Get("quorum"); v != "" {
+		if q.Quorum, err = strconv.Atoi(v); err != nil || q.Quorum < 1 {
+			http.Error(w, "quorum must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	st, err := h.store.GetSiteStats(userID, siteID, q)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "site not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		configs.DBLogger.Println("❌ StatsHandler: GetSiteStats error:", err)
+		http.Error(w, fmt.Sprintf("Error getting stats: %v", err), http.StatusInternalServerError)
+		return
+	}
+	configs.DBLogger.Printf("✅ StatsHandler: site_id=%d checks=%d incidents=%d", siteID, st.Checks, st.Incidents)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(st)
+}
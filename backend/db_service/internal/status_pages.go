@@ -0,0 +1,608 @@
+package internal
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"db_service/configs"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrStatusPageNotFound = errors.New("status page not found")
+
+const (
+	maxStatusPageSites = 100
+	maxStatusPageDays  = 90
+)
+
+// Общий статус публичной страницы
+const (
+	PageOperational   = "operational"
+	PagePartialOutage = "partial_outage"
+	PageMajorOutage   = "major_outage"
+	PageMaintenance   = "maintenance" // ничего не упало, но часть мониторов на плановых работах
+)
+
+// StatusPage - набор мониторов пользователя, показываемый по публичному URL
+type StatusPage struct {
+	ID        int              `json:"id"`
+	Slug      string           `json:"slug"`
+	Title     string           `json:"title"`
+	Sites     []StatusPageSite `json:"sites"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// StatusPageSite - монитор на странице
+type StatusPageSite struct {
+	SiteID int    `json:"site_id"`
+	Label  string `json:"label,omitempty"` // имя на странице; пусто - хост из адреса сайта
+}
+
+// PublicStatus - то, что видит посетитель публичной страницы. Адреса сайтов,
+// их ID и причины сбоев сюда не попадают: только имена, состояние и аптайм.
+type PublicStatus struct {
+	Title     string           `json:"title"`
+	Status    string           `json:"status"` // operational | partial_outage | major_outage | maintenance
+	UpdatedAt time.Time        `json:"updated_at"`
+	Monitors  []PublicMonitor  `json:"monitors"`
+	Incidents []PublicIncident `json:"incidents"` // незакрытые, новые первыми
+}
+
+type PublicMonitor struct {
+	Name      string      `json:"name"`
+	Status    string      `json:"status"`     // up | down | maintenance | unknown
+	UptimePct *float64    `json:"uptime_pct"` // за все дни без окон обслуживания; null - проверок не было
+	Days      []UptimeDay `json:"days"`       // старые первыми, последний - сегодня (UTC)
+}
+
+type UptimeDay struct {
+	Date      string   `json:"date"` // 2006-01-02
+	Checks    int      `json:"checks"`
+	Failed    int      `json:"failed"`
+	UptimePct *float64 `json:"uptime_pct"` // null - проверок в этот день не было
+}
+
+type PublicIncident struct {
+	ID       int       `json:"id"`
+	Monitor  string    `json:"monitor"`
+	Status   string    `json:"status"` // open | acknowledged
+	OpenedAt time.Time `json:"opened_at"`
+}
+
+// newStatusPageSlug - 128 случайных бит: страницы нельзя перебрать по адресу
+func newStatusPageSlug() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate status page slug: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// monitorName - имя монитора на публичной странице. Без подписи показываем
+// только хост: путь и параметры адреса могут содержать секреты.
+func monitorName(label, site string) string {
+	if label != "" {
+		return label
+	}
+	if u, err := url.Parse(site); err == nil && u.Host != "" {
+		return u.Hostname()
+	}
+	// tcp host:port, хост icmp/dns-проверки или имя heartbeat-монитора
+	return site
+}
+
+func (s *Storage) CreateStatusPage(userID int, p StatusPage) (StatusPage, error) {
+	slug, err := newStatusPageSlug()
+	if err != nil {
+		return p, err
+	}
+
+	tx, err := s.psql.Begin()
+	if err != nil {
+		return p, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO status_pages (user_id, slug, title) VALUES ($1, $2, $3) RETURNING id
+	`, userID, slug, p.Title).Scan(&id)
+	if err != nil {
+		return p, err
+	}
+	if err := setStatusPageSites(tx, userID, id, p.Sites); err != nil {
+		return p, err
+	}
+	if err := tx.Commit(); err != nil {
+		return p, err
+	}
+	return s.GetStatusPage(userID, id)
+}
+
+// UpdateStatusPage меняет заголовок (если задан) и состав мониторов (если Sites не nil).
+func (s *Storage) UpdateStatusPage(userID, id int, p StatusPage) (StatusPage, error) {
+	tx, err := s.psql.Begin()
+	if err != nil {
+		return p, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE status_pages SET title = COALESCE(NULLIF($3, ''), title) WHERE id = $1 AND user_id = $2
+	`, id, userID, p.Title)
+	if err != nil {
+		return p, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return p, ErrStatusPageNotFound
+	}
+	if p.Sites != nil {
+		if err := setStatusPageSites(tx, userID, id, p.Sites); err != nil {
+			return p, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return p, err
+	}
+	return s.GetStatusPage(userID, id)
+}
+
+// setStatusPageSites заменяет мониторы страницы. Добавить можно только свои
+// сайты: чужой или несуществующий ID - ErrSiteNotFound.
+func setStatusPageSites(tx *sql.Tx, userID, pageID int, sites []StatusPageSite) error {
+	if _, err := tx.Exec(`DELETE FROM status_page_sites WHERE page_id = $1`, pageID); err != nil {
+		return err
+	}
+	for i, site := range sites {
+		res, err := tx.Exec(`
+			INSERT INTO status_page_sites (page_id, site_id, label, position)
+			SELECT $1, id, $3, $4 FROM user_sites WHERE id = $2 AND user_id = $5
+		`, pageID, site.SiteID, site.Label, i, userID)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return fmt.Errorf("%w: %d", ErrSiteNotFound, site.SiteID)
+		}
+	}
+	return nil
+}
+
+func (s *Storage) DeleteStatusPage(userID, id int) error {
+	res, err := s.psql.Exec(`DELETE FROM status_pages WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrStatusPageNotFound
+	}
+	return nil
+}
+
+func (s *Storage) GetStatusPage(userID, id int) (StatusPage, error) {
+	pages, err := s.getStatusPages(`p.user_id = $1 AND p.id = $2`, userID, id)
+	if err != nil {
+		return StatusPage{}, err
+	}
+	if len(pages) == 0 {
+		return StatusPage{}, ErrStatusPageNotFound
+	}
+	return pages[0], nil
+}
+
+func (s *Storage) GetStatusPages(userID int) ([]StatusPage, error) {
+	return s.getStatusPages(`p.user_id = $1`, userID)
+}
+
+func (s *Storage) getStatusPages(where string, args ...any) ([]StatusPage, error) {
+	rows, err := s.psql.Query(`
+		SELECT p.id, p.slug, p.title, p.created_at, sps.site_id, sps.label
+		FROM status_pages p
+		LEFT JOIN status_page_sites sps ON sps.page_id = p.id
+		WHERE `+where+`
+		ORDER BY p.id, sps.position
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pages := []StatusPage{}
+	for rows.Next() {
+		var p StatusPage
+		var siteID sql.NullInt64
+		var label sql.NullString
+		if err := rows.Scan(&p.ID, &p.Slug, &p.Title, &p.CreatedAt, &siteID, &label); err != nil {
+			return nil, err
+		}
+		if n := len(pages); n == 0 || pages[n-1].ID != p.ID {
+			p.Sites = []StatusPageSite{}
+			pages = append(pages, p)
+		}
+		if siteID.Valid {
+			last := &pages[len(pages)-1]
+			last.Sites = append(last.Sites, StatusPageSite{SiteID: int(siteID.Int64), Label: label.String})
+		}
+	}
+	return pages, rows.Err()
+}
+
+// GetPublicStatus собирает публичную страницу: состояние мониторов из
+// site_states, дневной аптайм за days дней из ClickHouse (проверка неудачна,
+// если упала не меньше чем в quorum регионах; проверки в окнах обслуживания
+// не считаются) и незакрытые инциденты.
+// Читает только сайты и логи владельца страницы.
+func (s *Storage) GetPublicStatus(slug string, quorum, days int, now time.Time) (PublicStatus, error) {
+	ps := PublicStatus{UpdatedAt: now, Monitors: []PublicMonitor{}, Incidents: []PublicIncident{}}
+
+	var pageID, userID int
+	err := s.psql.QueryRow(`SELECT id, user_id, title FROM status_pages WHERE slug = $1`, slug).
+		Scan(&pageID, &userID, &ps.Title)
+	if err == sql.ErrNoRows {
+		return ps, ErrStatusPageNotFound
+	}
+	if err != nil {
+		return ps, err
+	}
+
+	rows, err := s.psql.Query(`
+		SELECT sps.site_id, sps.label, s.site, st.status
+		FROM status_page_sites sps
+		JOIN user_sites s ON s.id = sps.site_id AND s.user_id = $2
+		LEFT JOIN site_states st ON st.site_id = sps.site_id
+		WHERE sps.page_id = $1
+		ORDER BY sps.position
+	`, pageID, userID)
+	if err != nil {
+		return ps, err
+	}
+	var siteIDs []int
+	var sites []string
+	var states []sql.NullString
+	for rows.Next() {
+		var siteID int
+		var label, site string
+		var state sql.NullString
+		if err := rows.Scan(&siteID, &label, &site, &state); err != nil {
+			rows.Close()
+			return ps, err
+		}
+		siteIDs = append(siteIDs, siteID)
+		sites = append(sites, site)
+		states = append(states, state)
+		ps.Monitors = append(ps.Monitors, PublicMonitor{Name: monitorName(label, site)})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return ps, err
+	}
+
+	today := now.UTC().Truncate(24 * time.Hour)
+	from := today.AddDate(0, 0, -(days - 1))
+	daily, err := s.dailyUptime(userID, sites, quorum, from)
+	if err != nil {
+		return ps, err
+	}
+	maintenance, err := s.GetMaintenanceOccurrences(userID, now, now.Add(time.Second))
+	if err != nil {
+		return ps, err
+	}
+
+	var down, inMaintenance int
+	for i := range ps.Monitors {
+		m := &ps.Monitors[i]
+		var checks, failed int
+		for d := from; !d.After(today); d = d.AddDate(0, 0, 1) {
+			day := daily[sites[i]][d.Format("2006-01-02")]
+			day.Date = d.Format("2006-01-02")
+			day.UptimePct = uptimePct(day.Checks, day.Failed)
+			checks += day.Checks
+			failed += day.Failed
+			m.Days = append(m.Days, day)
+		}
+		m.UptimePct = uptimePct(checks, failed)
+
+		// Пока сайт ни разу не падал, строки в site_states нет - он доступен
+		switch {
+		case underMaintenance(maintenance, siteIDs[i], now):
+			// Плановые работы важнее состояния: сайт и должен быть недоступен
+			m.Status = "maintenance"
+			inMaintenance++
+		case states[i].String == "down":
+			m.Status = "down"
+			down++
+		case states[i].Valid || checks > 0:
+			m.Status = "up"
+		default:
+			m.Status = "unknown"
+		}
+	}
+	switch {
+	case down == 0 && inMaintenance > 0:
+		ps.Status = PageMaintenance
+	case down == 0:
+		ps.Status = PageOperational
+	case down == len(ps.Monitors)-inMaintenance:
+		ps.Status = PageMajorOutage
+	default:
+		ps.Status = PagePartialOutage
+	}
+
+	rows, err = s.psql.Query(`
+		SELECT i.id, sps.label, s.site, i.acknowledged_at IS NOT NULL, i.opened_at
+		FROM incidents i
+		JOIN status_page_sites sps ON sps.site_id = i.site_id AND sps.page_id = $1
+		JOIN user_sites s ON s.id = i.site_id AND s.user_id = $2
+		WHERE i.resolved_at IS NULL
+		ORDER BY i.opened_at DESC
+	`, pageID, userID)
+	if err != nil {
+		return ps, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var in PublicIncident
+		var label, site string
+		var acknowledged bool
+		if err := rows.Scan(&in.ID, &label, &site, &acknowledged, &in.OpenedAt); err != nil {
+			return ps, err
+		}
+		in.Monitor = monitorName(label, site)
+		in.Status = IncidentOpen
+		if acknowledged {
+			in.Status = IncidentAcknowledged
+		}
+		ps.Incidents = append(ps.Incidents, in)
+	}
+	return ps, rows.Err()
+}
+
+// dailyUptime считает проверки и неудачные проверки сайтов по дням (UTC): site -> дата -> день.
+func (s *Storage) dailyUptime(userID int, sites []string, quorum int, from time.Time) (map[string]map[string]UptimeDay, error) {
+	daily := map[string]map[string]UptimeDay{}
+	if len(sites) == 0 {
+		return daily, nil
+	}
+
+	rows, err := s.ch.Query(`
+		SELECT site, toDate(req_time) AS day, count(), countIf(failed = 1)
+		FROM (
+			SELECT site, req_time, toInt8(countIf(status = 'bad') >= least(toUInt64(?), count())) AS failed
+			FROM ping_logs
+			WHERE user_id = ? AND has(?, site) AND req_time >= ? AND maintenance = 0 AND status != 'initial'
+			GROUP BY site, req_time
+		)
+		GROUP BY site, day
+	`, quorum, userID, sites, from)
+	if err != nil {
+		return nil, fmt.Errorf("daily uptime: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var site string
+		var day time.Time
+		var checks, failed uint64
+		if err := rows.Scan(&site, &day, &checks, &failed); err != nil {
+			return nil, err
+		}
+		if daily[site] == nil {
+			daily[site] = map[string]UptimeDay{}
+		}
+		daily[site][day.Format("2006-01-02")] = UptimeDay{Checks: int(checks), Failed: int(failed)}
+	}
+	return daily, rows.Err()
+}
+
+// underMaintenance - идёт ли у сайта окно обслуживания в момент at
+func underMaintenance(occ []MaintenanceOccurrence, siteID int, at time.Time) bool {
+	for _, o := range occ {
+		if o.covers(siteID, at) {
+			return true
+		}
+	}
+	return false
+}
+
+func uptimePct(checks, failed int) *float64 {
+	if checks == 0 {
+		return nil
+	}
+	pct := 100 * float64(checks-failed) / float64(checks)
+	return &pct
+}
+
+// StatusPagesHandler - страницы статуса пользователя:
+//
+//	GET  /status-pages?user_id=
+//	POST /status-pages?user_id=           {"title", "sites": [{"site_id", "label"}]}
+//	GET/PUT/DELETE /status-pages/{id}?user_id=
+func (h *Handler) StatusPagesHandler(w http.ResponseWriter, r *http.Request) {
+	configs.DBLogger.Printf("➡️ StatusPagesHandler %s %s", r.Method, r.URL.String())
+
+	userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+	if err != nil || userID == 0 {
+		http.Error(w, "user_id query param required", http.StatusBadRequest)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) > 2 {
+		http.Error(w, "Invalid URL", http.StatusBadRequest)
+		return
+	}
+
+	if len(parts) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			pages, err := h.store.GetStatusPages(userID)
+			if err != nil {
+				configs.DBLogger.Println("❌ StatusPagesHandler GET: GetStatusPages error:", err)
+				http.Error(w, fmt.Sprintf("Error getting status pages: %v", err), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(pages)
+		case http.MethodPost:
+			p, ok := decodeStatusPage(w, r, true)
+			if !ok {
+				return
+			}
+			page, err := h.store.CreateStatusPage(userID, p)
+			if err != nil {
+				writeStatusPage(w, page, err)
+				return
+			}
+			configs.DBLogger.Printf("✅ status page %d created for user_id=%d", page.ID, userID)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(page)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	id, err := strconv.Atoi(parts[1])
+	if err != nil {
+		http.Error(w, "Invalid status page id", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		page, err := h.store.GetStatusPage(userID, id)
+		writeStatusPage(w, page, err)
+	case http.MethodPut, http.MethodPatch:
+		p, ok := decodeStatusPage(w, r, false)
+		if !ok {
+			return
+		}
+		page, err := h.store.UpdateStatusPage(userID, id, p)
+		writeStatusPage(w, page, err)
+	case http.MethodDelete:
+		if err := h.store.DeleteStatusPage(userID, id); err != nil {
+			writeStatusPage(w, StatusPage{}, err)
+			return
+		}
+		configs.DBLogger.Printf("✅ status page %d deleted", id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// decodeStatusPage читает и проверяет тело запроса; при ошибке сам отвечает клиенту.
+func decodeStatusPage(w http.ResponseWriter, r *http.Request, create bool) (StatusPage, bool) {
+	var p StatusPage
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		configs.DBLogger.Println("❌ StatusPagesHandler: decode error:", err)
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return p, false
+	}
+	p.Title = strings.TrimSpace(p.Title)
+	if create && p.Title == "" {
+		http.Error(w, "title is required", http.StatusBadRequest)
+		return p, false
+	}
+	if len(p.Title) > 255 {
+		http.Error(w, "title must be at most 255 characters", http.StatusBadRequest)
+		return p, false
+	}
+	if len(p.Sites) > maxStatusPageSites {
+		http.Error(w, fmt.Sprintf("at most %d sites per page", maxStatusPageSites), http.StatusBadRequest)
+		return p, false
+	}
+	if create && p.Sites == nil {
+		p.Sites = []StatusPageSite{}
+	}
+	seen := map[int]bool{}
+	for i := range p.Sites {
+		site := &p.Sites[i]
+		site.Label = strings.TrimSpace(site.Label)
+		if seen[site.SiteID] {
+			http.Error(w, fmt.Sprintf("duplicate site_id %d", site.SiteID), http.StatusBadRequest)
+			return p, false
+		}
+		if len(site.Label) > 255 {
+			http.Error(w, "label must be at most 255 characters", http.StatusBadRequest)
+			return p, false
+		}
+		seen[site.SiteID] = true
+	}
+	return p, true
+}
+
+func writeStatusPage(w http.ResponseWriter, page StatusPage, err error) {
+	switch {
+	case errors.Is(err, ErrStatusPageNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, ErrSiteNotFound):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		configs.DBLogger.Println("❌ StatusPagesHandler error:", err)
+		http.Error(w, fmt.Sprintf("Error saving status page: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// PublicStatusHandler: GET /public-status/{slug}?quorum=&days=
+// Без user_id: страница открыта всем, кто знает slug.
+func (h *Handler) PublicStatusHandler(w http.ResponseWriter, r *http.Request) {
+	configs.DBLogger.Printf("➡️ PublicStatusHandler %s %s", r.Method, r.URL.Path)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	slug := strings.TrimPrefix(r.URL.Path, "/public-status/")
+	if slug == "" || strings.Contains(slug, "/") {
+		http.Error(w, "Invalid URL", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	quorum, days := 1, maxStatusPageDays
+	if v := query.Get("quorum"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "quorum must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		quorum = n
+	}
+	if v := query.Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxStatusPageDays {
+			http.Error(w, fmt.Sprintf("days must be between 1 and %d", maxStatusPageDays), http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+
+	ps, err := h.store.GetPublicStatus(slug, quorum, days, time.Now().UTC())
+	if errors.Is(err, ErrStatusPageNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		configs.DBLogger.Println("❌ PublicStatusHandler: GetPublicStatus error:", err)
+		http.Error(w, fmt.Sprintf("Error getting status: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ps)
+}
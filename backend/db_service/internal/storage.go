@@ -3,13 +3,45 @@ package internal
 import (
 	"database/sql"
 	"db_service/configs"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
 )
 
+// Типы проверок (user_sites.monitor_type)
+const (
+	MonitorHTTP = "http"
+	MonitorTCP  = "tcp"
+	MonitorICMP = "icmp"
+	MonitorDNS  = "dns"
+	// heartbeat никто не пингует: задача сама дёргает /heartbeat/{token},
+	// а api_service поднимает алерт, если за check_interval + grace_period сигнала не было
+	MonitorHeartbeat = "heartbeat"
+	MonitorMultistep = "multistep" // цепочка HTTP-шагов из user_sites.steps
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrSiteNotFound       = errors.New("site not found")
+	ErrSiteExists         = errors.New("site already exists")
+)
+
+// Что делать с историей ping_logs при удалении сайта
+const (
+	LogsKeep    = "keep"    // оставить строки в ping_logs как есть
+	LogsArchive = "archive" // перенести в ping_logs_archive
+	LogsPurge   = "purge"   // удалить безвозвратно
+)
+
+// defaultCheckInterval совпадает с DEFAULT колонки user_sites.check_interval
+const defaultCheckInterval = 60
+
 type Storage struct {
 	psql *sql.DB
 	ch   *sql.DB
@@ -21,6 +53,52 @@ type PingLog struct {
 	RespTime int64     `json:"resp_time"`
 	Status   string    `json:"status"`
 	Site     string    `json:"site"`
+	// Из чего сложилось время ответа, мс; 0 - этап не понадобился (например, соединение переиспользовано)
+	DNSMs      int64 `json:"dns_ms"`
+	ConnectMs  int64 `json:"connect_ms"`
+	TLSMs      int64 `json:"tls_ms"`
+	TTFBMs     int64 `json:"ttfb_ms"`
+	TransferMs int64 `json:"transfer_ms"`
+	// Для icmp-проверок: итог серии echo-запросов
+	PacketsSent     int     `json:"packets_sent"`
+	PacketsReceived int     `json:"packets_received"`
+	LossPct         float64 `json:"loss_pct"`
+	RTTMinMs        float64 `json:"rtt_min_ms"`
+	RTTAvgMs        float64 `json:"rtt_avg_ms"`
+	RTTMaxMs        float64 `json:"rtt_max_ms"`
+	JitterMs        float64 `json:"jitter_ms"`
+	// Для multistep: результаты шагов как их вернул ping_service (JSON-массив)
+	Steps json.RawMessage `json:"steps,omitempty"`
+	// Регион агента ping_service, выполнившего проверку; пусто - heartbeat или лог до появления регионов
+	Region string `json:"region"`
+	// Проверка пришлась на окно обслуживания: алертов не было, отчёты об аптайме её не учитывают
+	Maintenance bool `json:"maintenance"`
+}
+
+// pingLogColumns - колонки ping_logs, из которых собирается PingLog; порядок совпадает со scanPingLog
+const pingLogColumns = `req_time, resp_time, status, site, dns_ms, connect_ms, tls_ms, ttfb_ms, transfer_ms,
+	packets_sent, packets_received, loss_pct, rtt_min_ms, rtt_avg_ms, rtt_max_ms, jitter_ms, steps, region, maintenance`
+
+// pingLogExtraColumns - колонки, добавленные в ping_logs и ping_logs_archive миграциями
+var pingLogExtraColumns = []struct{ name, typ string }{
+	{"dns_ms", "Int64"}, {"connect_ms", "Int64"}, {"tls_ms", "Int64"}, {"ttfb_ms", "Int64"}, {"transfer_ms", "Int64"},
+	{"packets_sent", "Int32"}, {"packets_received", "Int32"}, {"loss_pct", "Float64"},
+	{"rtt_min_ms", "Float64"}, {"rtt_avg_ms", "Float64"}, {"rtt_max_ms", "Float64"}, {"jitter_ms", "Float64"},
+	{"maintenance", "UInt8"},
+}
+
+func scanPingLog(row interface{ Scan(...any) error }) (PingLog, error) {
+	var l PingLog
+	var sent, received int32
+	var steps string
+	err := row.Scan(&l.ReqTime, &l.RespTime, &l.Status, &l.Site,
+		&l.DNSMs, &l.ConnectMs, &l.TLSMs, &l.TTFBMs, &l.TransferMs,
+		&sent, &received, &l.LossPct, &l.RTTMinMs, &l.RTTAvgMs, &l.RTTMaxMs, &l.JitterMs, &steps, &l.Region, &l.Maintenance)
+	l.PacketsSent, l.PacketsReceived = int(sent), int(received)
+	if steps != "" {
+		l.Steps = json.RawMessage(steps)
+	}
+	return l, err
 }
 
 func NewStorage(psqlDB, chDB *sql.DB) *Storage {
@@ -63,6 +141,12 @@ func InitPostgreSQL() (*sql.DB, error) {
 		return nil, fmt.Errorf("failed to create tables: %v", err)
 	}
 
+	err = migratePlaintextPasswords(db)
+	if err != nil {
+		configs.DBLogger.Printf("Failed to migrate passwords: %v", err)
+		return nil, fmt.Errorf("failed to migrate passwords: %v", err)
+	}
+
 	return db, nil
 }
 
@@ -131,7 +215,157 @@ func createPostgreSQLTables(db *sql.DB) error {
 			UNIQUE(user_id, site)
 		)
 	`)
-	return err
+	if err != nil {
+		return err
+	}
+
+	// Текущее состояние сайта (up/down) для алертов по переходам
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS site_states (
+			site_id INTEGER PRIMARY KEY REFERENCES user_sites(id) ON DELETE CASCADE,
+			status VARCHAR(16) NOT NULL,
+			changed_at TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	return migratePostgreSQLTables(db)
+}
+
+// migratePostgreSQLTables добавляет колонки, появившиеся после первого релиза,
+// в уже существующие таблицы. Каждое изменение должно быть идемпотентным.
+func migratePostgreSQLTables(db *sql.DB) error {
+	migrations := []string{
+		// Пороги срабатывания: N неудач подряд или M неудач из последних K проверок
+		`ALTER TABLE user_sites ADD COLUMN IF NOT EXISTS failure_threshold INTEGER NOT NULL DEFAULT 1`,
+		`ALTER TABLE user_sites ADD COLUMN IF NOT EXISTS window_size INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE user_sites ADD COLUMN IF NOT EXISTS window_failures INTEGER NOT NULL DEFAULT 0`,
+		// Счётчики для порогов, чтобы рестарт api_service их не обнулял
+		`ALTER TABLE site_states ADD COLUMN IF NOT EXISTS consecutive_failures INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE site_states ADD COLUMN IF NOT EXISTS recent_results VARCHAR(64) NOT NULL DEFAULT ''`,
+		`ALTER TABLE site_states ADD COLUMN IF NOT EXISTS failing_since TIMESTAMP`,
+		// Проверки тела ответа (body contains, regex, JSON path...), формат задаёт ping_service
+		`ALTER TABLE user_sites ADD COLUMN IF NOT EXISTS assertions JSONB`,
+		// Описание HTTP-запроса проверки: метод, заголовки, тело и допустимые коды ответа
+		`ALTER TABLE user_sites ADD COLUMN IF NOT EXISTS method VARCHAR(10) NOT NULL DEFAULT 'GET'`,
+		`ALTER TABLE user_sites ADD COLUMN IF NOT EXISTS headers JSONB`,
+		`ALTER TABLE user_sites ADD COLUMN IF NOT EXISTS body TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE user_sites ADD COLUMN IF NOT EXISTS expected_status JSONB`,
+		// Какие предупреждения о сертификате уже отправлены, чтобы не слать их на каждой проверке
+		`ALTER TABLE site_states ADD COLUMN IF NOT EXISTS cert_not_after TIMESTAMP`,
+		`ALTER TABLE site_states ADD COLUMN IF NOT EXISTS cert_warned_days INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE site_states ADD COLUMN IF NOT EXISTS cert_error TEXT NOT NULL DEFAULT ''`,
+		// Тип проверки (http, tcp) и параметры TCP-проверки
+		`ALTER TABLE user_sites ADD COLUMN IF NOT EXISTS monitor_type VARCHAR(16) NOT NULL DEFAULT 'http'`,
+		`ALTER TABLE user_sites ADD COLUMN IF NOT EXISTS timeout_ms INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE user_sites ADD COLUMN IF NOT EXISTS banner TEXT NOT NULL DEFAULT ''`,
+		// Параметры ICMP-серии
+		`ALTER TABLE user_sites ADD COLUMN IF NOT EXISTS packet_count INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE user_sites ADD COLUMN IF NOT EXISTS packet_interval_ms INTEGER NOT NULL DEFAULT 0`,
+		// Параметры DNS-проверки и последние увиденные записи для уведомлений об изменениях
+		`ALTER TABLE user_sites ADD COLUMN IF NOT EXISTS record_type VARCHAR(10) NOT NULL DEFAULT ''`,
+		`ALTER TABLE user_sites ADD COLUMN IF NOT EXISTS resolver TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE user_sites ADD COLUMN IF NOT EXISTS expected JSONB`,
+		`ALTER TABLE site_states ADD COLUMN IF NOT EXISTS dns_records TEXT NOT NULL DEFAULT ''`,
+		// Пустой набор записей - тоже результат; отличаем его от "ещё не проверяли"
+		`ALTER TABLE site_states ADD COLUMN IF NOT EXISTS dns_seen BOOLEAN NOT NULL DEFAULT FALSE`,
+		`UPDATE site_states SET dns_seen = TRUE WHERE dns_records <> '' AND NOT dns_seen`,
+		// Heartbeat-мониторы: секретный токен push-URL, допустимое опоздание и время последнего сигнала
+		`ALTER TABLE user_sites ADD COLUMN IF NOT EXISTS heartbeat_token VARCHAR(64)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS user_sites_heartbeat_token_idx ON user_sites (heartbeat_token)`,
+		`ALTER TABLE user_sites ADD COLUMN IF NOT EXISTS grace_period INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE user_sites ADD COLUMN IF NOT EXISTS last_heartbeat_at TIMESTAMP`,
+		`ALTER TABLE site_states ADD COLUMN IF NOT EXISTS heartbeat_missed_at TIMESTAMP`,
+		// Шаги multistep-проверки, формат задаёт ping_service
+		`ALTER TABLE user_sites ADD COLUMN IF NOT EXISTS steps JSONB`,
+		// Аренды для выбора лидера среди реплик api_service
+		`CREATE TABLE IF NOT EXISTS leases (
+			name VARCHAR(64) PRIMARY KEY,
+			holder VARCHAR(128) NOT NULL,
+			expires_at TIMESTAMP NOT NULL
+		)`,
+		// Инциденты: у сайта не больше одного незакрытого, история действий - в incident_events
+		`CREATE TABLE IF NOT EXISTS incidents (
+			id SERIAL PRIMARY KEY,
+			site_id INTEGER NOT NULL REFERENCES user_sites(id) ON DELETE CASCADE,
+			opened_at TIMESTAMP NOT NULL,
+			first_failure_at TIMESTAMP NOT NULL,
+			last_failure_at TIMESTAMP NOT NULL,
+			resolved_at TIMESTAMP,
+			resolved_by VARCHAR(255) NOT NULL DEFAULT '',
+			root_error TEXT NOT NULL DEFAULT '',
+			acknowledged_at TIMESTAMP,
+			acknowledged_by VARCHAR(255) NOT NULL DEFAULT ''
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS incidents_open_site_idx ON incidents (site_id) WHERE resolved_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS incidents_site_opened_idx ON incidents (site_id, opened_at DESC)`,
+		`CREATE TABLE IF NOT EXISTS incident_events (
+			id SERIAL PRIMARY KEY,
+			incident_id INTEGER NOT NULL REFERENCES incidents(id) ON DELETE CASCADE,
+			at TIMESTAMP NOT NULL,
+			kind VARCHAR(16) NOT NULL,
+			author VARCHAR(255) NOT NULL DEFAULT '',
+			message TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE INDEX IF NOT EXISTS incident_events_incident_idx ON incident_events (incident_id, at)`,
+		// Публичные страницы статуса: slug - случайная часть публичного URL
+		`CREATE TABLE IF NOT EXISTS status_pages (
+			id SERIAL PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			slug VARCHAR(64) UNIQUE NOT NULL,
+			title VARCHAR(255) NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS status_page_sites (
+			page_id INTEGER NOT NULL REFERENCES status_pages(id) ON DELETE CASCADE,
+			site_id INTEGER NOT NULL REFERENCES user_sites(id) ON DELETE CASCADE,
+			label VARCHAR(255) NOT NULL DEFAULT '',
+			position INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (page_id, site_id)
+		)`,
+		// Окна обслуживания: разовые или повторяющиеся, для всех мониторов пользователя или для выбранных
+		`CREATE TABLE IF NOT EXISTS maintenance_windows (
+			id SERIAL PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			title VARCHAR(255) NOT NULL DEFAULT '',
+			starts_at TIMESTAMP NOT NULL,
+			ends_at TIMESTAMP NOT NULL,
+			recurrence VARCHAR(16) NOT NULL DEFAULT '',
+			until TIMESTAMP,
+			all_sites BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS maintenance_window_sites (
+			window_id INTEGER NOT NULL REFERENCES maintenance_windows(id) ON DELETE CASCADE,
+			site_id INTEGER NOT NULL REFERENCES user_sites(id) ON DELETE CASCADE,
+			PRIMARY KEY (window_id, site_id)
+		)`,
+		// Каналы уведомлений помимо email (webhook и т.п.): для всех мониторов пользователя или для выбранных.
+		// config - настройки канала, их формат проверяет api_service
+		`CREATE TABLE IF NOT EXISTS notification_channels (
+			id SERIAL PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			type VARCHAR(16) NOT NULL,
+			name VARCHAR(255) NOT NULL DEFAULT '',
+			config JSONB NOT NULL,
+			enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			all_sites BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS notification_channel_sites (
+			channel_id INTEGER NOT NULL REFERENCES notification_channels(id) ON DELETE CASCADE,
+			site_id INTEGER NOT NULL REFERENCES user_sites(id) ON DELETE CASCADE,
+			PRIMARY KEY (channel_id, site_id)
+		)`,
+	}
+	for _, m := range migrations {
+		if _, err := db.Exec(m); err != nil {
+			return fmt.Errorf("migration %q: %w", m, err)
+		}
+	}
+	return nil
 }
 
 func createClickHouseTable(db *sql.DB) error {
@@ -146,20 +380,172 @@ func createClickHouseTable(db *sql.DB) error {
 		ORDER BY (user_id, site, req_time)
 		PARTITION BY toYYYYMM(req_time)
 	`)
-	return err
+	if err != nil {
+		return err
+	}
+
+	// Архив логов удалённых сайтов
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS ping_logs_archive (
+			user_id UInt32,
+			site String,
+			req_time DateTime,
+			resp_time Int64,
+			status String,
+			archived_at DateTime DEFAULT now()
+		) ENGINE = MergeTree()
+		ORDER BY (user_id, site, req_time)
+		PARTITION BY toYYYYMM(req_time)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// История TLS-сертификатов сайтов
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS cert_logs (
+			user_id UInt32,
+			site String,
+			checked_at DateTime,
+			issuer String,
+			subject String,
+			dns_names Array(String),
+			not_after DateTime,
+			days_left Int32,
+			error String
+		) ENGINE = MergeTree()
+		ORDER BY (user_id, site, checked_at)
+		PARTITION BY toYYYYMM(checked_at)
+	`)
+	if err != nil {
+		return err
+	}
+
+	return migrateClickHouseTables(db)
+}
+
+// migrateClickHouseTables - то же, что migratePostgreSQLTables, для ClickHouse.
+func migrateClickHouseTables(db *sql.DB) error {
+	var migrations []string
+	// Разбивка времени ответа по этапам запроса и статистика ICMP; в архиве те же колонки
+	for _, table := range []string{"ping_logs", "ping_logs_archive"} {
+		for _, col := range pingLogExtraColumns {
+			migrations = append(migrations,
+				fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s DEFAULT 0`, table, col.name, col.typ))
+		}
+		// Шаги multistep-проверки, JSON
+		migrations = append(migrations,
+			fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS steps String DEFAULT ''`, table))
+		// Регион агента, выполнившего проверку
+		migrations = append(migrations,
+			fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS region String DEFAULT ''`, table))
+	}
+	for _, m := range migrations {
+		if _, err := db.Exec(m); err != nil {
+			return fmt.Errorf("migration %q: %w", m, err)
+		}
+	}
+	return nil
+}
+
+// lockedPassword ставится вместо старого пароля, который нельзя захэшировать
+// (bcrypt принимает не больше 72 байт). Это не хэш bcrypt, так что войти с
+// ним нельзя: пароль такому пользователю задаёт администратор.
+const lockedPassword = "!locked"
+
+// migratePlaintextPasswords хэширует пароли, сохранённые до перехода на bcrypt.
+// Хэшем считается всё, у чего bcrypt.Cost разбирает заголовок, поэтому повторный
+// запуск ничего не трогает, а открытый пароль, начинающийся с "$2", не пропускается.
+func migratePlaintextPasswords(db *sql.DB) error {
+	rows, err := db.Query(`SELECT id, password FROM users WHERE password <> $1`, lockedPassword)
+	if err != nil {
+		return err
+	}
+
+	plain := map[int]string{}
+	for rows.Next() {
+		var id int
+		var password string
+		if err := rows.Scan(&id, &password); err != nil {
+			rows.Close()
+			return err
+		}
+		if _, err := bcrypt.Cost([]byte(password)); err != nil {
+			plain[id] = password
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	hashed, locked := 0, 0
+	for id, password := range plain {
+		hash, err := hashPassword(password)
+		if err != nil {
+			// Из-за одного такого пароля сервис не должен останавливаться
+			configs.DBLogger.Printf("❌ Can't hash password of user %d, locking it until reset: %v", id, err)
+			hash = lockedPassword
+			locked++
+		} else {
+			hashed++
+		}
+		if _, err := db.Exec(`UPDATE users SET password = $1 WHERE id = $2`, hash, id); err != nil {
+			return err
+		}
+	}
+	if hashed > 0 {
+		configs.DBLogger.Printf("🔐 Hashed %d plaintext passwords", hashed)
+	}
+	if locked > 0 {
+		configs.DBLogger.Printf("🔐 Locked %d passwords that can't be hashed", locked)
+	}
+	return nil
+}
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
 }
 
 // Методы работы с PostgreSQL
 func (s *Storage) CreateUser(email, password string) (int, error) {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return 0, err
+	}
+
 	var id int
-	err := s.psql.QueryRow(`
+	err = s.psql.QueryRow(`
 		INSERT INTO users (email, password) 
 		VALUES ($1, $2) 
 		RETURNING id
-	`, email, password).Scan(&id)
+	`, email, hash).Scan(&id)
 	return id, err
 }
 
+// VerifyUser проверяет пару email/пароль и возвращает ID пользователя.
+// Для несуществующего email и неверного пароля возвращается одна и та же ошибка.
+func (s *Storage) VerifyUser(email, password string) (int, error) {
+	var id int
+	var hash string
+	err := s.psql.QueryRow(`SELECT id, password FROM users WHERE email = $1`, email).Scan(&id, &hash)
+	if err == sql.ErrNoRows {
+		return 0, ErrInvalidCredentials
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		return 0, ErrInvalidCredentials
+	}
+	return id, nil
+}
+
 func (s *Storage) AddUserSite(userID int, site string) error {
 	_, err := s.psql.Exec(`
 		INSERT INTO user_sites (user_id, site) 
@@ -169,9 +555,80 @@ func (s *Storage) AddUserSite(userID int, site string) error {
 	return err
 }
 
-func (s *Storage) AddSiteWithCheck(userID int, site string, checkInterval int) error {
-	// Добавляем сайт
-	err := s.AddUserSite(userID, site)
+// siteColumns - колонки user_sites, из которых собирается SiteInfo; порядок совпадает со scanSite
+const siteColumns = `id, site, check_interval, failure_threshold, window_size, window_failures, assertions,
+	method, headers, body, expected_status, monitor_type, timeout_ms, banner, packet_count, packet_interval_ms,
+	record_type, resolver, expected, COALESCE(heartbeat_token, ''), grace_period, last_heartbeat_at, steps`
+
+func scanSite(row interface{ Scan(...any) error }) (SiteInfo, error) {
+	var info SiteInfo
+	var assertions, headers, expectedStatus, expected, steps []byte
+	err := row.Scan(&info.ID, &info.URL, &info.CheckInterval,
+		&info.FailureThreshold, &info.WindowSize, &info.WindowFailures, &assertions,
+		&info.Method, &headers, &info.Body, &expectedStatus, &info.Type, &info.TimeoutMs, &info.Banner,
+		&info.Count, &info.IntervalMs, &info.RecordType, &info.Resolver, &expected,
+		&info.HeartbeatToken, &info.GracePeriod, &info.LastHeartbeatAt, &steps)
+	info.Assertions = assertions
+	info.Headers = headers
+	info.ExpectedStatus = expectedStatus
+	info.Expected = expected
+	info.Steps = steps
+	return info, err
+}
+
+// jsonParam превращает JSON в параметр запроса для колонки JSONB.
+// []byte lib/pq отправил бы как bytea, поэтому передаём строку; пустое значение - NULL.
+func jsonParam(raw json.RawMessage) any {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	return string(raw)
+}
+
+// assertionsParam - как jsonParam, но пустые проверки {} хранятся как NULL:
+// иначе ping_service читал бы тело ответа, не проверяя в нём ничего.
+func assertionsParam(raw json.RawMessage) any {
+	if strings.TrimSpace(string(raw)) == "{}" {
+		return nil
+	}
+	return jsonParam(raw)
+}
+
+func (s *Storage) AddSiteWithCheck(userID int, site SiteInfo) error {
+	if site.CheckInterval <= 0 {
+		site.CheckInterval = defaultCheckInterval
+	}
+	if site.FailureThreshold <= 0 {
+		site.FailureThreshold = 1
+	}
+	if site.Method == "" {
+		site.Method = "GET"
+	}
+	if site.Type == "" {
+		site.Type = MonitorHTTP
+	}
+	// Токен есть только у heartbeat; отсчёт первого периода идёт с момента создания
+	var token, lastBeat any
+	if site.Type == MonitorHeartbeat {
+		t, err := newHeartbeatToken()
+		if err != nil {
+			return err
+		}
+		token, lastBeat = t, time.Now().UTC()
+	}
+
+	// Добавляем сайт с его настройками проверки
+	_, err := s.psql.Exec(`
+		INSERT INTO user_sites (user_id, site, check_interval, failure_threshold, window_size, window_failures, assertions,
+			method, headers, body, expected_status, monitor_type, timeout_ms, banner, packet_count, packet_interval_ms,
+			record_type, resolver, expected, heartbeat_token, grace_period, last_heartbeat_at, steps)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23)
+		ON CONFLICT (user_id, site) DO NOTHING
+	`, userID, site.URL, site.CheckInterval, site.FailureThreshold, site.WindowSize, site.WindowFailures,
+		assertionsParam(site.Assertions), site.Method, jsonParam(site.Headers), site.Body, jsonParam(site.ExpectedStatus),
+		site.Type, site.TimeoutMs, site.Banner, site.Count, site.IntervalMs,
+		site.RecordType, site.Resolver, jsonParam(site.Expected), token, site.GracePeriod, lastBeat,
+		jsonParam(site.Steps))
 	if err != nil {
 		return err
 	}
@@ -180,45 +637,229 @@ func (s *Storage) AddSiteWithCheck(userID int, site string, checkInterval int) e
 	_, err = s.ch.Exec(`
 		INSERT INTO ping_logs (user_id, site, req_time, resp_time, status)
 		VALUES (?, ?, ?, ?, ?)
-	`, userID, site, time.Now(), 0, "initial")
+	`, userID, site.URL, time.Now(), 0, "initial")
 
 	return err
 }
 
+// SiteUpdate - изменяемые поля сайта; nil означает "не менять"
+type SiteUpdate struct {
+	Site             *string `json:"site"`
+	CheckInterval    *int    `json:"time"`
+	FailureThreshold *int    `json:"failure_threshold"`
+	WindowSize       *int    `json:"window_size"`
+	WindowFailures   *int    `json:"window_failures"`
+	// nil - не менять, {} - убрать все проверки
+	Assertions json.RawMessage `json:"assertions"`
+	Method     *string         `json:"method"`
+	// nil - не менять, {} / [] - очистить
+	Headers        json.RawMessage `json:"headers"`
+	Body           *string         `json:"body"`
+	ExpectedStatus json.RawMessage `json:"expected_status"`
+	Type           *string         `json:"type"`
+	TimeoutMs      *int            `json:"timeout_ms"`
+	Banner         *string         `json:"banner"`
+	Count          *int            `json:"count"`
+	IntervalMs     *int            `json:"interval_ms"`
+	RecordType     *string         `json:"record_type"`
+	Resolver       *string         `json:"resolver"`
+	Expected       json.RawMessage `json:"expected"` // [] - не сверять записи
+	GracePeriod    *int            `json:"grace_period"`
+	Steps          json.RawMessage `json:"steps"`
+}
+
+func (u SiteUpdate) empty() bool {
+	return u.Site == nil && u.CheckInterval == nil && u.FailureThreshold == nil &&
+		u.WindowSize == nil && u.WindowFailures == nil && jsonParam(u.Assertions) == nil &&
+		u.Method == nil && jsonParam(u.Headers) == nil && u.Body == nil && jsonParam(u.ExpectedStatus) == nil &&
+		u.Type == nil && u.TimeoutMs == nil && u.Banner == nil && u.Count == nil && u.IntervalMs == nil &&
+		u.RecordType == nil && u.Resolver == nil && jsonParam(u.Expected) == nil && u.GracePeriod == nil &&
+		jsonParam(u.Steps) == nil
+}
+
+// GetUserSite возвращает настройки сайта пользователя.
+func (s *Storage) GetUserSite(userID, siteID int) (SiteInfo, error) {
+	info, err := scanSite(s.psql.QueryRow(`
+		SELECT `+siteColumns+` FROM user_sites WHERE id = $1 AND user_id = $2
+	`, siteID, userID))
+	if err == sql.ErrNoRows {
+		return info, ErrSiteNotFound
+	}
+	return info, err
+}
+
+// UpdateUserSite меняет адрес и/или настройки проверки сайта пользователя.
+// История в ping_logs привязана к адресу, поэтому при смене адреса старые
+// логи остаются под прежним URL и в выдачу по сайту больше не попадают.
+func (s *Storage) UpdateUserSite(userID, siteID int, upd SiteUpdate) (SiteInfo, error) {
+	// Пригодится, если сайт переводят в heartbeat и токена у него ещё нет
+	token, err := newHeartbeatToken()
+	if err != nil {
+		return SiteInfo{}, err
+	}
+	info, err := scanSite(s.psql.QueryRow(`
+		UPDATE user_sites
+		SET site = COALESCE($3, site),
+			check_interval = COALESCE($4, check_interval),
+			failure_threshold = COALESCE($5, failure_threshold),
+			window_size = COALESCE($6, window_size),
+			window_failures = COALESCE($7, window_failures),
+			assertions = CASE WHEN $8::jsonb = '{}'::jsonb THEN NULL ELSE COALESCE($8::jsonb, assertions) END,
+			method = COALESCE($9, method),
+			headers = COALESCE($10::jsonb, headers),
+			body = COALESCE($11, body),
+			expected_status = COALESCE($12::jsonb, expected_status),
+			monitor_type = COALESCE($13, monitor_type),
+			timeout_ms = COALESCE($14, timeout_ms),
+			banner = COALESCE($15, banner),
+			packet_count = COALESCE($16, packet_count),
+			packet_interval_ms = COALESCE($17, packet_interval_ms),
+			record_type = COALESCE($18, record_type),
+			resolver = COALESCE($19, resolver),
+			expected = COALESCE($20::jsonb, expected),
+			grace_period = COALESCE($21, grace_period),
+			heartbeat_token = CASE WHEN COALESCE($13, monitor_type) = '`+MonitorHeartbeat+`'
+				THEN COALESCE(heartbeat_token, $22) ELSE heartbeat_token END,
+			last_heartbeat_at = CASE WHEN COALESCE($13, monitor_type) = '`+MonitorHeartbeat+`'
+				THEN COALESCE(last_heartbeat_at, $23) ELSE last_heartbeat_at END,
+			steps = COALESCE($24::jsonb, steps)
+		WHERE id = $1 AND user_id = $2
+		RETURNING `+siteColumns,
+		siteID, userID, upd.Site, upd.CheckInterval, upd.FailureThreshold, upd.WindowSize, upd.WindowFailures,
+		jsonParam(upd.Assertions), upd.Method, jsonParam(upd.Headers), upd.Body, jsonParam(upd.ExpectedStatus),
+		upd.Type, upd.TimeoutMs, upd.Banner, upd.Count, upd.IntervalMs,
+		upd.RecordType, upd.Resolver, jsonParam(upd.Expected), upd.GracePeriod, token, time.Now().UTC(),
+		jsonParam(upd.Steps)))
+	if err == sql.ErrNoRows {
+		return info, ErrSiteNotFound
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return info, ErrSiteExists
+	}
+	return info, err
+}
+
+// DeleteUserSite удаляет сайт пользователя, предварительно обработав
+// его историю в ClickHouse согласно logsMode (LogsKeep/LogsArchive/LogsPurge).
+func (s *Storage) DeleteUserSite(userID, siteID int, logsMode string) error {
+	var site string
+	err := s.psql.QueryRow(`
+		SELECT site FROM user_sites WHERE id = $1 AND user_id = $2
+	`, siteID, userID).Scan(&site)
+	if err == sql.ErrNoRows {
+		return ErrSiteNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	// Сначала логи: если ClickHouse упадёт, сайт останется и удаление можно повторить
+	switch logsMode {
+	case LogsKeep:
+	case LogsArchive:
+		// Если прошлая попытка упала после вставки в архив, уже заархивированные
+		// строки не копируются повторно
+		_, err = s.ch.Exec(`
+			INSERT INTO ping_logs_archive (user_id, `+pingLogColumns+`)
+			SELECT user_id, `+pingLogColumns+`
+			FROM ping_logs
+			WHERE user_id = ? AND site = ?
+			  AND (user_id, site, req_time, region) NOT IN (
+				SELECT user_id, site, req_time, region
+				FROM ping_logs_archive
+				WHERE user_id = ? AND site = ?
+			  )
+		`, userID, site, userID, site)
+		if err != nil {
+			return fmt.Errorf("archive logs: %w", err)
+		}
+		if err := s.purgeSiteLogs(userID, site); err != nil {
+			return err
+		}
+	case LogsPurge:
+		if err := s.purgeSiteLogs(userID, site); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown logs mode %q", logsMode)
+	}
+
+	_, err = s.psql.Exec(`DELETE FROM user_sites WHERE id = $1 AND user_id = $2`, siteID, userID)
+	return err
+}
+
+func (s *Storage) purgeSiteLogs(userID int, site string) error {
+	_, err := s.ch.Exec(`ALTER TABLE ping_logs DELETE WHERE user_id = ? AND site = ?`, userID, site)
+	if err != nil {
+		return fmt.Errorf("purge logs: %w", err)
+	}
+	return nil
+}
+
 // Методы работы с ClickHouse
-func (s *Storage) GetSiteLogs(userID, siteID int) ([]PingLog, error) {
+
+// GetSiteLogs возвращает страницу логов сайта, новые первыми, и курсор
+// следующей страницы (пустой, если страница последняя).
+func (s *Storage) GetSiteLogs(userID, siteID int, q LogQuery) ([]PingLog, string, error) {
 	// 1) достаём URL сайта по siteID
 	var site string
 	err := s.psql.QueryRow(`
         SELECT site FROM user_sites WHERE id = $1 AND user_id = $2
     `, siteID, userID).Scan(&site)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	// 2) читаем логи из ClickHouse
+	// 2) читаем логи из ClickHouse; одна лишняя строка показывает, есть ли следующая страница
+	where, args := q.where(userID, site)
+	skip := 0
+	if q.Cursor != nil {
+		skip = q.Cursor.Skip
+	}
 	rows, err := s.ch.Query(`
-        SELECT req_time, resp_time, status, site
+        SELECT `+pingLogColumns+`
         FROM ping_logs
-        WHERE user_id = ? AND site = ?
-        ORDER BY req_time DESC
-    `, userID, site)
+        WHERE `+where+`
+        ORDER BY req_time DESC, region, status, resp_time
+        LIMIT ? OFFSET ?
+    `, append(args, q.Limit+1, skip)...)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer rows.Close()
 
 	// 3) маппим в структуру и проставляем SiteID
 	var logs []PingLog
 	for rows.Next() {
-		var log PingLog
-		if err := rows.Scan(&log.ReqTime, &log.RespTime, &log.Status, &log.Site); err != nil {
-			return nil, err
+		log, err := scanPingLog(rows)
+		if err != nil {
+			return nil, "", err
 		}
 		log.SiteID = siteID // ← вот здесь добавляем ID
 		logs = append(logs, log)
 	}
-	return logs, nil
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	if len(logs) <= q.Limit {
+		return logs, "", nil
+	}
+	logs = logs[:q.Limit]
+
+	// Следующая страница начинается с последнего отданного времени: пропускаем
+	// уже отданные логи с этим req_time, включая отданные на прошлых страницах
+	last := logs[len(logs)-1].ReqTime
+	next := logCursor{Before: last}
+	for _, l := range logs {
+		if l.ReqTime.Equal(last) {
+			next.Skip++
+		}
+	}
+	if q.Cursor != nil && q.Cursor.Before.Equal(last) {
+		next.Skip += q.Cursor.Skip
+	}
+	return logs, next.encode(), nil
 }
 
 func (s *Storage) GetAllUserLogs(userID int) ([]PingLog, error) {
@@ -241,7 +882,7 @@ func (s *Storage) GetAllUserLogs(userID int) ([]PingLog, error) {
 
 	// берём "первую" (последнюю по времени) запись на каждый site
 	rows, err := s.ch.Query(`
-        SELECT req_time, resp_time, status, site
+        SELECT `+pingLogColumns+`
         FROM ping_logs
         WHERE user_id = ?
         ORDER BY site ASC, req_time DESC
@@ -254,8 +895,8 @@ func (s *Storage) GetAllUserLogs(userID int) ([]PingLog, error) {
 
 	var logs []PingLog
 	for rows.Next() {
-		var log PingLog
-		if err := rows.Scan(&log.ReqTime, &log.RespTime, &log.Status, &log.Site); err != nil {
+		log, err := scanPingLog(rows)
+		if err != nil {
 			return nil, err
 		}
 		log.SiteID = siteIDByURL[log.Site] // 0, если сайт удалили из user_sites
@@ -278,38 +919,39 @@ func (s *Storage) GetUserEmail(userID int) (string, error) {
 }
 
 func (s *Storage) GetAllUsersSites() ([]UserSites, error) {
-	rows, err := s.psql.Query(`
-		SELECT u.id AS user_id,
-			COALESCE(us.id, 0) AS site_id,          -- <-- добавили COALESCE
-			COALESCE(us.site, '') AS site,
-			COALESCE(us.check_interval, 0) AS check_interval
-		FROM users u
-		LEFT JOIN user_sites us ON us.user_id = u.id
-		ORDER BY u.id, us.id
-	`)
+	// пользователи без сайтов тоже попадают в выдачу, с пустым списком
+	users, err := s.psql.Query(`SELECT id FROM users ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	byUser := map[int][]SiteInfo{}
+	userOrder := []int{}
+	for users.Next() {
+		var uid int
+		if err := users.Scan(&uid); err != nil {
+			users.Close()
+			return nil, err
+		}
+		userOrder = append(userOrder, uid)
+		byUser[uid] = []SiteInfo{}
+	}
+	users.Close()
 
+	rows, err := s.psql.Query(`SELECT user_id, ` + siteColumns + ` FROM user_sites ORDER BY user_id, id`)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	// собираем по пользователям
-	byUser := map[int][]SiteInfo{}
-	userOrder := []int{}
 	for rows.Next() {
-		var uid, sid, interval int
-		var url string
-		if err := rows.Scan(&uid, &sid, &url, &interval); err != nil {
+		var uid int
+		info, err := scanSite(scanPrefix{rows, &uid})
+		if err != nil {
 			return nil, err
 		}
-		if _, seen := byUser[uid]; !seen {
-			userOrder = append(userOrder, uid)
-			byUser[uid] = []SiteInfo{}
-		}
-		if sid != 0 && url != "" {
-			byUser[uid] = append(byUser[uid], SiteInfo{
-				ID: sid, URL: url, CheckInterval: interval,
-			})
+		if _, seen := byUser[uid]; seen && info.URL != "" {
+			byUser[uid] = append(byUser[uid], info)
 		}
 	}
 	// формируем итог
@@ -320,11 +962,27 @@ func (s *Storage) GetAllUsersSites() ([]UserSites, error) {
 	return out, nil
 }
 
+// scanPrefix позволяет прочитать scanSite-ом строку, перед колонками сайта в которой идут ещё поля
+type scanPrefix struct {
+	row    interface{ Scan(...any) error }
+	prefix any
+}
+
+func (p scanPrefix) Scan(dest ...any) error {
+	return p.row.Scan(append([]any{p.prefix}, dest...)...)
+}
+
 // Добавление лога пинга
-func (s *Storage) AddPingLog(userID int, site string, respTime int64, status string) error {
+func (s *Storage) AddPingLog(userID int, l PingLog) error {
+	if l.ReqTime.IsZero() {
+		l.ReqTime = time.Now()
+	}
 	_, err := s.ch.Exec(`
-		INSERT INTO ping_logs (user_id, site, req_time, resp_time, status)
-		VALUES (?, ?, ?, ?, ?)
-	`, userID, site, time.Now(), respTime, status)
+		INSERT INTO ping_logs (user_id, `+pingLogColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, userID, l.ReqTime, l.RespTime, l.Status, l.Site,
+		l.DNSMs, l.ConnectMs, l.TLSMs, l.TTFBMs, l.TransferMs,
+		int32(l.PacketsSent), int32(l.PacketsReceived), l.LossPct, l.RTTMinMs, l.RTTAvgMs, l.RTTMaxMs, l.JitterMs,
+		string(l.Steps), l.Region, l.Maintenance)
 	return err
 }
@@ -2,6 +2,7 @@ package configs
 
 import (
 	"log"
+	"net"
 	"os"
 	"strconv"
 	"strings"
@@ -28,6 +29,17 @@ var (
 	KafkaSessionTimeout    time.Duration
 	KafkaHeartbeatInterval time.Duration
 
+	// Notification channels (webhooks, Slack, Telegram)
+	ChannelMaxRetries   int           = 5
+	ChannelTimeout      time.Duration = 10 * time.Second // per attempt
+	ChannelDeadline     time.Duration = time.Minute      // all attempts for one channel
+	ChannelWorkers      int           = 8                // parallel deliveries
+	ChannelQueueSize    int           = 1000             // deliveries waiting for a worker; more are dropped
+	ChannelDrainTimeout time.Duration = 15 * time.Second // how long shutdown waits for queued deliveries
+	WebhookDeniedHosts  []string
+	WebhookAllowedNets  []*net.IPNet // private ranges webhooks may still reach
+	TelegramAPIURL      string
+
 	// Health Check Server
 	HealthPort string
 )
@@ -100,6 +112,59 @@ func Configure() {
 	KafkaSessionTimeout = 10 * time.Second
 	KafkaHeartbeatInterval = 3 * time.Second
 
+	// Notification channels
+	if v := os.Getenv("CHANNEL_MAX_RETRIES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			ChannelMaxRetries = n
+		} else {
+			log.Printf("invalid CHANNEL_MAX_RETRIES=%q, using default: %d", v, ChannelMaxRetries)
+		}
+	}
+	if v := os.Getenv("CHANNEL_WORKERS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			ChannelWorkers = n
+		} else {
+			log.Printf("invalid CHANNEL_WORKERS=%q, using default: %d", v, ChannelWorkers)
+		}
+	}
+	if v := os.Getenv("CHANNEL_QUEUE_SIZE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			ChannelQueueSize = n
+		} else {
+			log.Printf("invalid CHANNEL_QUEUE_SIZE=%q, using default: %d", v, ChannelQueueSize)
+		}
+	}
+
+	// Webhooks must not reach the services of this deployment: db_service has no auth
+	deniedHosts := os.Getenv("WEBHOOK_DENIED_HOSTS")
+	if deniedHosts == "" {
+		deniedHosts = "localhost,api_service,auth_service,db_service,ping_service,notification_service,postgres_db,clickhouse_db,redis,kafka1,zookeeper"
+	}
+	for _, host := range strings.Split(deniedHosts, ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			WebhookDeniedHosts = append(WebhookDeniedHosts, host)
+		}
+	}
+	// Private, loopback and link-local addresses (the compose network included) are
+	// refused at dial time; an operator can open internal ranges for on-prem receivers
+	for _, cidr := range strings.Split(os.Getenv("WEBHOOK_ALLOWED_NETS"), ",") {
+		if cidr = strings.TrimSpace(cidr); cidr == "" {
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			log.Printf("invalid WEBHOOK_ALLOWED_NETS entry %q, skipping", cidr)
+			continue
+		}
+		WebhookAllowedNets = append(WebhookAllowedNets, ipNet)
+	}
+
+	// Telegram Bot API; overridden for a self-hosted Bot API server or a local stand-in
+	TelegramAPIURL = strings.TrimRight(os.Getenv("TELEGRAM_API_URL"), "/")
+	if TelegramAPIURL == "" {
+		TelegramAPIURL = "https://api.telegram.org"
+	}
+
 	// Health Check Server
 	HealthPort = os.Getenv("HEALTH_PORT")
 	if HealthPort == "" {
@@ -116,4 +181,9 @@ func Configure() {
 	log.Printf("- Kafka Brokers: %v", KafkaBrokers)
 	log.Printf("- Kafka Topic: %s", KafkaTopic)
 	log.Printf("- Kafka Consumer Group: %s", KafkaConsumerGroup)
+	log.Printf("- Channel Max Retries: %d", ChannelMaxRetries)
+	log.Printf("- Channel Workers: %d, Queue Size: %d", ChannelWorkers, ChannelQueueSize)
+	log.Printf("- Webhook Denied Hosts: %v", WebhookDeniedHosts)
+	log.Printf("- Webhook Allowed Nets: %v", WebhookAllowedNets)
+	log.Printf("- Telegram API URL: %s", TelegramAPIURL)
 }
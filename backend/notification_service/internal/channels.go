@@ -0,0 +1,183 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"notification_service/configs"
+	"notification_service/models"
+	"sync"
+	"time"
+)
+
+// Channel delivers a notification to one of the user's destinations besides email.
+type Channel interface {
+	Send(ctx context.Context, req models.NotificationRequest) error
+}
+
+// NewChannel builds the channel described by cfg.
+func NewChannel(cfg models.ChannelConfig) (Channel, error) {
+	switch cfg.Type {
+	case models.ChannelWebhook:
+		return NewWebhookChannel(cfg)
+	case models.ChannelSlack:
+		return NewSlackChannel(cfg)
+	case models.ChannelTelegram:
+		return NewTelegramChannel(cfg)
+	default:
+		return nil, fmt.Errorf("unknown channel type %q", cfg.Type)
+	}
+}
+
+// permanentError is a failure that retrying will not fix: bad config or a 4xx response.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+func permanent(format string, args ...any) error {
+	return &permanentError{err: fmt.Errorf(format, args...)}
+}
+
+// statusError turns a response status into an error: nil for 2xx, retryable
+// for 408, 429 and 5xx, permanent for the rest
+func statusError(service string, code int) error {
+	switch {
+	case code >= 200 && code < 300:
+		return nil
+	case code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500:
+		return fmt.Errorf("%s returned status %d", service, code)
+	default:
+		return permanent("%s returned status %d", service, code)
+	}
+}
+
+// channelBackoff is the pause before the first retry; it doubles after each attempt
+var channelBackoff = time.Second
+
+// SendChannelWithRetry delivers req through ch, retrying transient failures
+// with exponential backoff: 1s, 2s, 4s, ...
+func SendChannelWithRetry(ctx context.Context, ch Channel, req models.NotificationRequest, maxRetries int) error {
+	var lastErr error
+	backoff := channelBackoff
+
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, configs.ChannelTimeout)
+		err := ch.Send(attemptCtx, req)
+		cancel()
+		if err == nil {
+			return nil
+		}
+
+		lastErr = err
+		var perm *permanentError
+		if errors.As(err, &perm) {
+			return err
+		}
+		log.Printf("Channel attempt %d/%d failed for site %s: %v", attempt, maxRetries, req.Site, err)
+
+		if attempt < maxRetries {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+	}
+	return fmt.Errorf("failed after %d attempts: %w", maxRetries, lastErr)
+}
+
+// channelDelivery is one notification for one channel
+type channelDelivery struct {
+	cfg models.ChannelConfig
+	req models.NotificationRequest
+}
+
+// ChannelDispatcher delivers notifications to channels in the background with
+// a fixed number of workers, so the Kafka consumer never waits for a slow or
+// dead endpoint. Each channel is a separate delivery: a webhook that keeps
+// failing ties up one worker for at most ChannelDeadline, not the others.
+// When the queue is full new deliveries are dropped and logged.
+type ChannelDispatcher struct {
+	queue  chan channelDelivery
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewChannelDispatcher(workers, queueSize int) *ChannelDispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &ChannelDispatcher{
+		queue:  make(chan channelDelivery, queueSize),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	for i := 0; i < workers; i++ {
+		d.wg.Add(1)
+		go d.worker()
+	}
+	return d
+}
+
+// Enqueue schedules req for every channel attached to it and returns at once.
+func (d *ChannelDispatcher) Enqueue(req models.NotificationRequest) {
+	for _, cfg := range req.Channels {
+		select {
+		case d.queue <- channelDelivery{cfg: cfg, req: req}:
+		default:
+			log.Printf("Channel queue is full, dropping %s notification for %s channel %d", req.Event, cfg.Type, cfg.ID)
+		}
+	}
+}
+
+// Stop waits up to timeout for queued deliveries, then abandons the rest.
+// Enqueue must not be called after Stop.
+func (d *ChannelDispatcher) Stop(timeout time.Duration) {
+	close(d.queue)
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Printf("Channel deliveries did not finish in %s, abandoning %d queued", timeout, len(d.queue))
+		d.cancel()
+		<-done
+	}
+	d.cancel()
+}
+
+func (d *ChannelDispatcher) worker() {
+	defer d.wg.Done()
+	for delivery := range d.queue {
+		if d.ctx.Err() != nil {
+			continue // stopping: drain without sending
+		}
+		sendChannel(d.ctx, delivery.cfg, delivery.req)
+	}
+}
+
+func sendChannel(parent context.Context, cfg models.ChannelConfig, req models.NotificationRequest) {
+	ch, err := NewChannel(cfg)
+	if err != nil {
+		log.Printf("Skipping %s channel %d: %v", cfg.Type, cfg.ID, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(parent, configs.ChannelDeadline)
+	defer cancel()
+	if err := SendChannelWithRetry(ctx, ch, req, configs.ChannelMaxRetries); err != nil {
+		log.Printf("Failed to send %s notification to %s channel %d: %v", req.Event, cfg.Type, cfg.ID, err)
+		return
+	}
+	log.Printf("Notification sent to %s channel %d", cfg.Type, cfg.ID)
+}
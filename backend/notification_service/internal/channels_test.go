@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"notification_service/configs"
+	"notification_service/models"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// allowLoopbackWebhooks lets webhookClient reach httptest servers on 127.0.0.1
+func allowLoopbackWebhooks(t *testing.T) {
+	t.Helper()
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+	saved := configs.WebhookAllowedNets
+	configs.WebhookAllowedNets = []*net.IPNet{loopback}
+	t.Cleanup(func() { configs.WebhookAllowedNets = saved })
+}
+
+// fastBackoff keeps retry tests from sleeping for seconds
+func fastBackoff(t *testing.T) {
+	saved := channelBackoff
+	channelBackoff = time.Millisecond
+	t.Cleanup(func() { channelBackoff = saved })
+}
+
+// channelStandIns build every channel type against a local server;
+// reply answers with the given status the way that service does
+var channelStandIns = []struct {
+	name  string
+	open  func(t *testing.T, url string) Channel
+	reply func(w http.ResponseWriter, status int)
+}{
+	{
+		name: models.ChannelWebhook,
+		open: func(t *testing.T, url string) Channel {
+			allowLoopbackWebhooks(t)
+			return newWebhookTestChannel(t, WebhookConfig{URL: url})
+		},
+		reply: func(w http.ResponseWriter, status int) { w.WriteHeader(status) },
+	},
+	{
+		name: models.ChannelSlack,
+		open: func(t *testing.T, url string) Channel {
+			allowLoopbackWebhooks(t)
+			return newSlackTestChannel(t, url)
+		},
+		reply: func(w http.ResponseWriter, status int) { w.WriteHeader(status) },
+	},
+	{
+		name: models.ChannelTelegram,
+		open: func(t *testing.T, url string) Channel {
+			useTelegramAPI(t, url)
+			return newTelegramTestChannel(t)
+		},
+		reply: func(w http.ResponseWriter, status int) {
+			telegramReply(w, status, status == http.StatusOK, http.StatusText(status))
+		},
+	},
+}
+
+func TestChannelStatusClassification(t *testing.T) {
+	tests := []struct {
+		status    int
+		permanent bool
+	}{
+		{http.StatusRequestTimeout, false},
+		{http.StatusTooManyRequests, false},
+		{http.StatusInternalServerError, false},
+		{http.StatusBadGateway, false},
+		{http.StatusServiceUnavailable, false},
+		{http.StatusBadRequest, true},
+		{http.StatusUnauthorized, true},
+		{http.StatusForbidden, true},
+		{http.StatusNotFound, true},
+	}
+	for _, si := range channelStandIns {
+		for _, tt := range tests {
+			t.Run(si.name+"/"+http.StatusText(tt.status), func(t *testing.T) {
+				srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					si.reply(w, tt.status)
+				}))
+				defer srv.Close()
+
+				err := si.open(t, srv.URL).Send(context.Background(), models.NotificationRequest{Site: "a.test"})
+				if err == nil {
+					t.Fatal("want an error")
+				}
+				var perm *permanentError
+				if errors.As(err, &perm) != tt.permanent {
+					t.Errorf("permanent = %v, want %v: %v", !tt.permanent, tt.permanent, err)
+				}
+			})
+		}
+	}
+}
+
+func TestSendChannelWithRetry(t *testing.T) {
+	const maxRetries = 3
+
+	tests := []struct {
+		name     string
+		statuses []int // replies in order; the last one repeats
+		wantErr  bool
+		attempts int32
+	}{
+		{"transient then ok", []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusOK}, false, 3},
+		{"always transient", []int{http.StatusServiceUnavailable}, true, maxRetries},
+		{"permanent", []int{http.StatusNotFound}, true, 1},
+		{"transient then permanent", []int{http.StatusInternalServerError, http.StatusForbidden}, true, 2},
+	}
+	for _, si := range channelStandIns {
+		for _, tt := range tests {
+			t.Run(si.name+"/"+tt.name, func(t *testing.T) {
+				fastBackoff(t)
+
+				var hits atomic.Int32
+				srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					n := int(hits.Add(1))
+					si.reply(w, tt.statuses[min(n, len(tt.statuses))-1])
+				}))
+				defer srv.Close()
+
+				err := SendChannelWithRetry(context.Background(), si.open(t, srv.URL), models.NotificationRequest{Site: "a.test"}, maxRetries)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("err = %v, want error: %v", err, tt.wantErr)
+				}
+				if n := hits.Load(); n != tt.attempts {
+					t.Errorf("attempts = %d, want %d", n, tt.attempts)
+				}
+			})
+		}
+	}
+}
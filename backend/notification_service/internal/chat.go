@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"fmt"
+	"notification_service/models"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxChatCode caps errors and record lists in chat messages, keeping them
+// well under Slack and Telegram limits; the full text is in the site logs
+const maxChatCode = 500
+
+// chatMessage is a notification laid out for chat channels (Slack, Telegram):
+// a title line and a list of labelled fields, each rendered by the channel
+// in its own markup
+type chatMessage struct {
+	Title  string
+	Fields []chatField
+	Footer string
+}
+
+type chatField struct {
+	Label string
+	Value string
+	// Code values (errors, records) are rendered monospace
+	Code bool
+}
+
+func (m *chatMessage) add(label, value string) {
+	if value != "" {
+		m.Fields = append(m.Fields, chatField{Label: label, Value: value})
+	}
+}
+
+func (m *chatMessage) addCode(label, value string) {
+	if value != "" {
+		m.Fields = append(m.Fields, chatField{Label: label, Value: truncate(value, maxChatCode), Code: true})
+	}
+}
+
+// Text is the plain-text version for push notifications and clients that
+// cannot render markup
+func (m chatMessage) Text() string {
+	var b strings.Builder
+	b.WriteString(m.Title)
+	for _, f := range m.Fields {
+		fmt.Fprintf(&b, "\n%s: %s", f.Label, f.Value)
+	}
+	return b.String()
+}
+
+// newChatMessage uses the same wording as the emails in templates.go
+func newChatMessage(req models.NotificationRequest) chatMessage {
+	m := chatMessage{Footer: "PingTower · " + req.Time}
+
+	switch req.Event {
+	case models.EventRecovery:
+		m.Title = "✅ Сервис восстановлен: " + req.Site
+		m.add("Сервис", req.Site)
+		m.add("Длительность сбоя", formatDuration(req.Duration))
+		m.add("Недоступен с", req.DownSince)
+		m.add("Восстановлен", req.Time)
+		if req.ResponseTime > 0 {
+			m.add("Время ответа", fmt.Sprintf("%d мс", req.ResponseTime))
+		}
+		if req.IncidentID != 0 {
+			m.add("Инцидент", fmt.Sprintf("#%d", req.IncidentID))
+		}
+
+	case models.EventCertExpiry, models.EventCertInvalid:
+		m.add("Сервис", req.Site)
+		m.add("Издатель", req.CertIssuer)
+		m.add("Действителен до", req.CertNotAfter)
+		if req.Event == models.EventCertInvalid {
+			m.Title = "🔒 Сертификат не прошёл проверку: " + req.Site
+			m.addCode("Проблема", req.CertError)
+		} else if req.DaysLeft != nil && *req.DaysLeft < 0 {
+			m.Title = "⌛ Сертификат истёк: " + req.Site
+			m.add("Истёк дней назад", fmt.Sprintf("%d", -*req.DaysLeft))
+		} else {
+			m.Title = "⏳ Сертификат скоро истекает: " + req.Site
+			if req.DaysLeft != nil {
+				m.add("Осталось дней", fmt.Sprintf("%d", *req.DaysLeft))
+			}
+		}
+
+	case models.EventDNSChanged:
+		m.Title = fmt.Sprintf("🧭 DNS-записи %s изменились: %s", req.RecordType, req.Site)
+		m.add("Домен", req.Site)
+		m.add("Тип записи", req.RecordType)
+		m.addCode("Было", joinRecords(req.OldRecords))
+		m.addCode("Стало", joinRecords(req.NewRecords))
+
+	default:
+		m.Title = "🚨 Сервис недоступен: " + req.Site
+		m.add("Сервис", req.Site)
+		m.add("Время обнаружения", req.Time)
+		if req.ResponseTime > 0 {
+			m.add("Время ответа", fmt.Sprintf("%d мс", req.ResponseTime))
+		}
+		m.addCode("Ошибка", req.Error)
+		if req.IncidentID != 0 {
+			m.add("Инцидент", fmt.Sprintf("#%d", req.IncidentID))
+		}
+	}
+	return m
+}
+
+// truncate cuts s to at most n runes, marking the cut with an ellipsis
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:n-1]) + "…"
+}
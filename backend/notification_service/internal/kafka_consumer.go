@@ -16,6 +16,7 @@ import (
 type KafkaConsumer struct {
 	consumerGroup sarama.ConsumerGroup
 	smtpService   *SMTPService
+	channels      *ChannelDispatcher
 	ctx           context.Context
 	cancel        context.CancelFunc
 	wg            sync.WaitGroup
@@ -23,6 +24,7 @@ type KafkaConsumer struct {
 
 type ConsumerGroupHandler struct {
 	smtpService *SMTPService
+	channels    *ChannelDispatcher
 }
 
 func NewKafkaConsumer() (*KafkaConsumer, error) {
@@ -65,6 +67,7 @@ func NewKafkaConsumer() (*KafkaConsumer, error) {
 	return &KafkaConsumer{
 		consumerGroup: cg,
 		smtpService:   NewSMTPService(),
+		channels:      NewChannelDispatcher(configs.ChannelWorkers, configs.ChannelQueueSize),
 		ctx:           ctx,
 		cancel:        cancel,
 	}, nil
@@ -73,6 +76,7 @@ func NewKafkaConsumer() (*KafkaConsumer, error) {
 func (kc *KafkaConsumer) Start() error {
 	handler := &ConsumerGroupHandler{
 		smtpService: kc.smtpService,
+		channels:    kc.channels,
 	}
 
 	// Отдельно читаем ошибки группы, чтобы они не глушились
@@ -126,6 +130,8 @@ func (kc *KafkaConsumer) Stop() {
 	if err := kc.consumerGroup.Close(); err != nil {
 		log.Printf("Error closing consumer group: %v", err)
 	}
+	// Новых сообщений больше не будет - даём каналам дослать очередь
+	kc.channels.Stop(configs.ChannelDrainTimeout)
 	log.Println("Kafka consumer stopped")
 }
 
@@ -168,13 +174,22 @@ func (h *ConsumerGroupHandler) processMessage(message *sarama.ConsumerMessage) e
 		return err
 	}
 
-	log.Printf("Processing notification for email: %s, site: %s, time: %s",
-		notificationReq.Email, notificationReq.Site, notificationReq.Time)
+	if notificationReq.Event == "" {
+		notificationReq.Event = models.EventDown
+	}
+
+	log.Printf("Processing %s notification for email: %s, site: %s, time: %s",
+		notificationReq.Event, notificationReq.Email, notificationReq.Site, notificationReq.Time)
 
 	// можно использовать контекст с таймаутом, чтобы не зависать на SMTP
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	// Каналы пользователя (webhook, Slack, Telegram) отправляются в фоне: дежурному не
+	// нужно ждать SMTP, а недоступный webhook одного пользователя не задерживает чтение
+	// топика. Каналы получают уведомление, даже если письмо не ушло.
+	h.channels.Enqueue(notificationReq)
+
 	response, err := h.smtpService.SendNotificationWithRetry(ctx, notificationReq, configs.MaxRetries)
 	if err != nil {
 		log.Printf("Failed to send notification: %v", err)
@@ -196,5 +211,10 @@ func validateKafkaMessage(req models.NotificationRequest) error {
 	if req.Time == "" {
 		return &ValidationError{Field: "time", Message: "time is required"}
 	}
+	switch req.Event {
+	case "", models.EventDown, models.EventRecovery, models.EventCertExpiry, models.EventCertInvalid, models.EventDNSChanged:
+	default:
+		return &ValidationError{Field: "event", Message: "unknown event " + req.Event}
+	}
 	return nil
 }
@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"notification_service/models"
+	"strings"
+)
+
+// SlackConfig is the config of a Slack channel
+type SlackConfig struct {
+	// Incoming webhook of the Slack app, https://hooks.slack.com/services/...
+	WebhookURL string `json:"webhook_url"`
+}
+
+// SlackChannel posts Block Kit messages to a Slack incoming webhook
+type SlackChannel struct {
+	id  int
+	cfg SlackConfig
+}
+
+// Block Kit limits
+const (
+	slackMaxHeader         = 150
+	slackMaxFieldsPerBlock = 10
+	slackMaxFieldText      = 2000
+)
+
+type slackText struct {
+	Type string `json:"type"` // plain_text | mrkdwn
+	Text string `json:"text"`
+}
+
+type slackBlock struct {
+	Type     string      `json:"type"` // header | section | context
+	Text     *slackText  `json:"text,omitempty"`
+	Fields   []slackText `json:"fields,omitempty"`
+	Elements []slackText `json:"elements,omitempty"`
+}
+
+type slackMessage struct {
+	// Shown in notifications and by clients that cannot render blocks
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func NewSlackChannel(cfg models.ChannelConfig) (*SlackChannel, error) {
+	c := &SlackChannel{id: cfg.ID}
+	if err := json.Unmarshal(cfg.Config, &c.cfg); err != nil {
+		return nil, fmt.Errorf("invalid slack config: %w", err)
+	}
+	if err := checkWebhookURL(c.cfg.WebhookURL); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func slackMessageFor(m chatMessage) slackMessage {
+	msg := slackMessage{
+		Text: slackEscaper.Replace(m.Text()),
+		Blocks: []slackBlock{{
+			Type: "header",
+			Text: &slackText{Type: "plain_text", Text: truncate(m.Title, slackMaxHeader)},
+		}},
+	}
+
+	var fields []slackText
+	for _, f := range m.Fields {
+		value := slackEscaper.Replace(f.Value)
+		if f.Code {
+			value = "`" + strings.ReplaceAll(value, "`", "'") + "`"
+		}
+		text := truncate(fmt.Sprintf("*%s*\n%s", f.Label, value), slackMaxFieldText)
+		fields = append(fields, slackText{Type: "mrkdwn", Text: text})
+	}
+	for len(fields) > 0 {
+		n := min(len(fields), slackMaxFieldsPerBlock)
+		msg.Blocks = append(msg.Blocks, slackBlock{Type: "section", Fields: fields[:n]})
+		fields = fields[n:]
+	}
+
+	msg.Blocks = append(msg.Blocks, slackBlock{
+		Type:     "context",
+		Elements: []slackText{{Type: "mrkdwn", Text: slackEscaper.Replace(m.Footer)}},
+	})
+	return msg
+}
+
+func (c *SlackChannel) Send(ctx context.Context, req models.NotificationRequest) error {
+	body, err := json.Marshal(slackMessageFor(newChatMessage(req)))
+	if err != nil {
+		return permanent("encode slack message: %v", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return permanent("build request: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("User-Agent", "PingTower-Webhook/1.0")
+
+	return doWebhook(httpReq, "slack")
+}
@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"notification_service/models"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func newSlackTestChannel(t *testing.T, url string) *SlackChannel {
+	t.Helper()
+	cfg, _ := json.Marshal(SlackConfig{WebhookURL: url})
+	ch, err := NewSlackChannel(models.ChannelConfig{ID: 1, Type: models.ChannelSlack, Config: cfg})
+	if err != nil {
+		t.Fatalf("NewSlackChannel: %v", err)
+	}
+	return ch
+}
+
+func TestSlackSendPayload(t *testing.T) {
+	allowLoopbackWebhooks(t)
+
+	var got slackMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	req := models.NotificationRequest{
+		Site:       "https://a.test/?x=1&y=<2>",
+		Time:       "2026-01-02 03:04:05",
+		Error:      "unexpected `status` 503 & <html>",
+		IncidentID: 7,
+	}
+	if err := newSlackTestChannel(t, srv.URL).Send(context.Background(), req); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if len(got.Blocks) != 3 {
+		t.Fatalf("blocks = %d, want header, section and context", len(got.Blocks))
+	}
+	if b := got.Blocks[0]; b.Type != "header" || b.Text.Type != "plain_text" || !strings.Contains(b.Text.Text, req.Site) {
+		t.Errorf("header = %+v", b)
+	}
+	if strings.ContainsAny(got.Text, "<>") || !strings.Contains(got.Text, "&amp;y=&lt;2&gt;") {
+		t.Errorf("text is not escaped: %q", got.Text)
+	}
+
+	fields := map[string]string{}
+	for _, f := range got.Blocks[1].Fields {
+		if f.Type != "mrkdwn" {
+			t.Errorf("field type = %q", f.Type)
+		}
+		label, value, _ := strings.Cut(f.Text, "\n")
+		fields[label] = value
+	}
+	if v := fields["*Сервис*"]; v != "https://a.test/?x=1&amp;y=&lt;2&gt;" {
+		t.Errorf("site field = %q", v)
+	}
+	if v := fields["*Ошибка*"]; v != "`unexpected 'status' 503 &amp; &lt;html&gt;`" {
+		t.Errorf("error field = %q", v)
+	}
+	if v := fields["*Инцидент*"]; v != "#7" {
+		t.Errorf("incident field = %q", v)
+	}
+	if b := got.Blocks[2]; b.Type != "context" || len(b.Elements) != 1 || b.Elements[0].Text != "PingTower · "+req.Time {
+		t.Errorf("context = %+v", b)
+	}
+}
+
+func TestSlackMessageLimits(t *testing.T) {
+	m := chatMessage{Title: strings.Repeat("й", 200), Footer: "footer"}
+	for i := 0; i < 23; i++ {
+		m.add(fmt.Sprintf("Field %d", i), "value")
+	}
+	m.Fields = append(m.Fields, chatField{Label: "Long", Value: strings.Repeat("x", 3000)})
+
+	msg := slackMessageFor(m)
+
+	header := msg.Blocks[0].Text.Text
+	if n := utf8.RuneCountInString(header); n != slackMaxHeader || !strings.HasSuffix(header, "…") {
+		t.Errorf("header has %d runes, want %d ending with an ellipsis", n, slackMaxHeader)
+	}
+
+	var sections, total int
+	for _, b := range msg.Blocks {
+		if b.Type != "section" {
+			continue
+		}
+		sections++
+		total += len(b.Fields)
+		if len(b.Fields) > slackMaxFieldsPerBlock {
+			t.Errorf("section has %d fields, max %d", len(b.Fields), slackMaxFieldsPerBlock)
+		}
+		for _, f := range b.Fields {
+			if n := utf8.RuneCountInString(f.Text); n > slackMaxFieldText {
+				t.Errorf("field has %d runes, max %d", n, slackMaxFieldText)
+			}
+		}
+	}
+	if sections != 3 || total != 24 {
+		t.Errorf("got %d fields in %d sections, want 24 in 3", total, sections)
+	}
+}
@@ -0,0 +1,123 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"notification_service/configs"
+	"notification_service/models"
+	"strings"
+)
+
+// TelegramConfig is the config of a Telegram channel
+type TelegramConfig struct {
+	BotToken string `json:"bot_token"` // from @BotFather
+	ChatID   string `json:"chat_id"`   // numeric chat ID or @channel_username
+}
+
+// TelegramChannel sends messages through the Telegram Bot API
+type TelegramChannel struct {
+	id  int
+	cfg TelegramConfig
+}
+
+// telegramMaxText is the sendMessage limit on the text length
+const telegramMaxText = 4096
+
+// telegramClient talks only to configs.TelegramAPIURL, which is set by the
+// operator, so it needs none of the webhookClient restrictions
+var telegramClient = &http.Client{}
+
+type telegramMessage struct {
+	ChatID                string `json:"chat_id"`
+	Text                  string `json:"text"`
+	ParseMode             string `json:"parse_mode"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview"`
+}
+
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+}
+
+func NewTelegramChannel(cfg models.ChannelConfig) (*TelegramChannel, error) {
+	c := &TelegramChannel{id: cfg.ID}
+	if err := json.Unmarshal(cfg.Config, &c.cfg); err != nil {
+		return nil, fmt.Errorf("invalid telegram config: %w", err)
+	}
+	if c.cfg.BotToken == "" || strings.ContainsAny(c.cfg.BotToken, "/?#") || c.cfg.ChatID == "" {
+		return nil, errors.New("telegram config needs bot_token and chat_id")
+	}
+	return c, nil
+}
+
+// telegramHTML renders the message with Telegram's HTML parse mode
+func telegramHTML(m chatMessage) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<b>%s</b>\n", html.EscapeString(m.Title))
+	for _, f := range m.Fields {
+		value := html.EscapeString(f.Value)
+		if f.Code {
+			value = "<code>" + value + "</code>"
+		}
+		fmt.Fprintf(&b, "\n<b>%s:</b> %s", html.EscapeString(f.Label), value)
+	}
+	fmt.Fprintf(&b, "\n\n<i>%s</i>", html.EscapeString(m.Footer))
+	return b.String()
+}
+
+func (c *TelegramChannel) Send(ctx context.Context, req models.NotificationRequest) error {
+	m := newChatMessage(req)
+	text := telegramHTML(m)
+	if len([]rune(text)) > telegramMaxText {
+		// Cutting HTML could leave a tag open; plain text is always accepted
+		text = html.EscapeString(truncate(m.Text(), telegramMaxText/2))
+	}
+
+	body, err := json.Marshal(telegramMessage{
+		ChatID:                c.cfg.ChatID,
+		Text:                  text,
+		ParseMode:             "HTML",
+		DisableWebPagePreview: true,
+	})
+	if err != nil {
+		return permanent("encode telegram message: %v", err)
+	}
+
+	endpoint := configs.TelegramAPIURL + "/bot" + c.cfg.BotToken + "/sendMessage"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return permanent("build request: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := telegramClient.Do(httpReq)
+	if err != nil {
+		// *url.Error quotes the URL, and the URL carries the bot token
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("telegram request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result telegramResponse
+	json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&result)
+	if err := statusError("telegram", resp.StatusCode); err != nil {
+		if result.Description != "" {
+			return fmt.Errorf("%w: %s", err, result.Description)
+		}
+		return err
+	}
+	if !result.OK {
+		return permanent("telegram rejected the message: %s", result.Description)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"notification_service/configs"
+	"notification_service/models"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+const testBotToken = "123456:SECRET-token_value"
+
+// useTelegramAPI points the Bot API at url for the duration of the test
+func useTelegramAPI(t *testing.T, url string) {
+	t.Helper()
+	saved := configs.TelegramAPIURL
+	configs.TelegramAPIURL = url
+	t.Cleanup(func() { configs.TelegramAPIURL = saved })
+}
+
+func newTelegramTestChannel(t *testing.T) *TelegramChannel {
+	t.Helper()
+	cfg, _ := json.Marshal(TelegramConfig{BotToken: testBotToken, ChatID: "@oncall"})
+	ch, err := NewTelegramChannel(models.ChannelConfig{ID: 2, Type: models.ChannelTelegram, Config: cfg})
+	if err != nil {
+		t.Fatalf("NewTelegramChannel: %v", err)
+	}
+	return ch
+}
+
+// telegramReply answers like the Bot API
+func telegramReply(w http.ResponseWriter, status int, ok bool, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(telegramResponse{OK: ok, Description: description})
+}
+
+func TestTelegramSendMessage(t *testing.T) {
+	var got telegramMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/bot"+testBotToken+"/sendMessage" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+		telegramReply(w, http.StatusOK, true, "")
+	}))
+	defer srv.Close()
+	useTelegramAPI(t, srv.URL)
+
+	req := models.NotificationRequest{
+		Site:         "https://a.test/?x=1&y=<2>",
+		Time:         "2026-01-02 03:04:05",
+		ResponseTime: 1200,
+		Error:        "read <tcp> & reset",
+		IncidentID:   7,
+	}
+	if err := newTelegramTestChannel(t).Send(context.Background(), req); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	want := "<b>🚨 Сервис недоступен: https://a.test/?x=1&amp;y=&lt;2&gt;</b>\n" +
+		"\n<b>Сервис:</b> https://a.test/?x=1&amp;y=&lt;2&gt;" +
+		"\n<b>Время обнаружения:</b> 2026-01-02 03:04:05" +
+		"\n<b>Время ответа:</b> 1200 мс" +
+		"\n<b>Ошибка:</b> <code>read &lt;tcp&gt; &amp; reset</code>" +
+		"\n<b>Инцидент:</b> #7" +
+		"\n\n<i>PingTower · 2026-01-02 03:04:05</i>"
+	if got.Text != want {
+		t.Errorf("text =\n%s\nwant\n%s", got.Text, want)
+	}
+	if got.ChatID != "@oncall" || got.ParseMode != "HTML" || !got.DisableWebPagePreview {
+		t.Errorf("message = %+v", got)
+	}
+}
+
+func TestTelegramLongMessageFallsBackToPlainText(t *testing.T) {
+	var got telegramMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		telegramReply(w, http.StatusOK, true, "")
+	}))
+	defer srv.Close()
+	useTelegramAPI(t, srv.URL)
+
+	req := models.NotificationRequest{Site: strings.Repeat("<a.test>", 1000)}
+	if err := newTelegramTestChannel(t).Send(context.Background(), req); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if strings.Contains(got.Text, "<b>") || strings.Contains(got.Text, "<a.test>") {
+		t.Errorf("text keeps markup: %.80q", got.Text)
+	}
+	if n := utf8.RuneCountInString(got.Text); n > telegramMaxText {
+		t.Errorf("text has %d runes, max %d", n, telegramMaxText)
+	}
+}
+
+// ok:false with 200 means Telegram read the request and refused it; resending won't help
+func TestTelegramRejectedMessageIsPermanent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		telegramReply(w, http.StatusOK, false, "Bad Request: can't parse entities")
+	}))
+	defer srv.Close()
+	useTelegramAPI(t, srv.URL)
+
+	err := newTelegramTestChannel(t).Send(context.Background(), models.NotificationRequest{Site: "a.test"})
+	var perm *permanentError
+	if !errors.As(err, &perm) || !strings.Contains(err.Error(), "can't parse entities") {
+		t.Errorf("err = %v, want a permanent error with the API description", err)
+	}
+}
+
+func TestTelegramErrorHidesToken(t *testing.T) {
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		telegramReply(w, http.StatusUnauthorized, false, "Unauthorized")
+	}))
+	defer rejecting.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close() // nothing listens there any more
+
+	for name, url := range map[string]string{"api error": rejecting.URL, "connection error": closed.URL} {
+		t.Run(name, func(t *testing.T) {
+			useTelegramAPI(t, url)
+			err := newTelegramTestChannel(t).Send(context.Background(), models.NotificationRequest{Site: "a.test"})
+			if err == nil {
+				t.Fatal("want an error")
+			}
+			if strings.Contains(err.Error(), testBotToken) || strings.Contains(err.Error(), "SECRET") {
+				t.Errorf("error leaks the bot token: %v", err)
+			}
+		})
+	}
+}
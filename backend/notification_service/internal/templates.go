@@ -3,10 +3,26 @@ package internal
 import (
 	"fmt"
 	"notification_service/models"
+	"strings"
+	"time"
 )
 
+// GenerateEmailContent picks the template matching the notification event.
 func GenerateEmailContent(req models.NotificationRequest) models.EmailContent {
-	subject := fmt.Sprintf("[CRITICAL] %s DOWN - Action Required", req.Site)
+	switch req.Event {
+	case models.EventRecovery:
+		return generateRecoveryContent(req)
+	case models.EventCertExpiry, models.EventCertInvalid:
+		return generateCertContent(req)
+	case models.EventDNSChanged:
+		return generateDNSContent(req)
+	default:
+		return generateDownContent(req)
+	}
+}
+
+func generateDownContent(req models.NotificationRequest) models.EmailContent {
+	subject := fmt.Sprintf("[CRITICAL] %s DOWN - Action Required", req.Site) + incidentSubject(req.IncidentID)
 	
 	html := fmt.Sprintf(`
 <!DOCTYPE html>
@@ -54,7 +70,7 @@ func GenerateEmailContent(req models.NotificationRequest) models.EmailContent {
                     <span class="label">Время обнаружения:</span> 
                     <span class="value">%s</span>
                 </div>
-                <div class="detail-item">
+%s                <div class="detail-item">
                     <span class="label">Тип проблемы:</span> 
                     <span class="value">Сервис не отвечает на запросы</span>
                 </div>
@@ -77,7 +93,7 @@ func GenerateEmailContent(req models.NotificationRequest) models.EmailContent {
         </div>
     </div>
 </body>
-</html>`, req.Site, req.Time, req.Time)
+</html>`, req.Site, req.Time, incidentHTML(req.IncidentID), req.Time)
 
 	text := fmt.Sprintf(`
 🚨 КРИТИЧЕСКАЯ ПРОБЛЕМА ОБНАРУЖЕНА
@@ -85,7 +101,7 @@ func GenerateEmailContent(req models.NotificationRequest) models.EmailContent {
 Сервис: %s
 Статус: НЕДОСТУПЕН
 Время: %s
-
+%s
 📊 Детали:
 - Тип проблемы: Сервис не отвечает на запросы
 - Требуется немедленное действие
@@ -98,11 +114,346 @@ func GenerateEmailContent(req models.NotificationRequest) models.EmailContent {
 
 ---
 Это автоматическое уведомление от системы мониторинга PingTower
-`, req.Site, req.Time)
+`, req.Site, req.Time, incidentText(req.IncidentID))
+
+	return models.EmailContent{
+		Subject: subject,
+		HTML:    html,
+		Text:    text,
+	}
+}
+
+func generateRecoveryContent(req models.NotificationRequest) models.EmailContent {
+	subject := fmt.Sprintf("[RESOLVED] %s is back UP", req.Site) + incidentSubject(req.IncidentID)
+	downtime := formatDuration(req.Duration)
+	downSince := req.DownSince
+	if downSince == "" {
+		downSince = "неизвестно"
+	}
+
+	html := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Service Recovered</title>
+    <style>
+        body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; margin: 0; padding: 20px; background-color: #f5f5f5; }
+        .container { max-width: 600px; margin: 0 auto; background-color: #ffffff; border-radius: 8px; overflow: hidden; box-shadow: 0 4px 6px rgba(0, 0, 0, 0.1); }
+        .header { background-color: #28a745; color: white; padding: 20px; text-align: center; }
+        .header h1 { margin: 0; font-size: 24px; }
+        .content { padding: 30px; }
+        .alert-icon { font-size: 48px; text-align: center; margin-bottom: 20px; }
+        .status-badge { display: inline-block; background-color: #28a745; color: white; padding: 8px 16px; border-radius: 20px; font-weight: bold; margin: 10px 0; }
+        .details { background-color: #f8f9fa; padding: 20px; border-radius: 6px; margin: 20px 0; border-left: 4px solid #28a745; }
+        .details h3 { margin-top: 0; color: #495057; }
+        .detail-item { margin: 10px 0; }
+        .label { font-weight: bold; color: #495057; }
+        .value { color: #212529; }
+        .footer { background-color: #f8f9fa; padding: 20px; text-align: center; color: #6c757d; font-size: 14px; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>✅ СЕРВИС ВОССТАНОВЛЕН</h1>
+        </div>
+        
+        <div class="content">
+            <div class="alert-icon">🎉</div>
+            
+            <div class="details">
+                <h3>📊 Детали восстановления:</h3>
+                <div class="detail-item">
+                    <span class="label">Сервис:</span> 
+                    <span class="value">%s</span>
+                </div>
+                <div class="detail-item">
+                    <span class="label">Статус:</span> 
+                    <span class="status-badge">ДОСТУПЕН</span>
+                </div>
+                <div class="detail-item">
+                    <span class="label">Недоступен с:</span> 
+                    <span class="value">%s</span>
+                </div>
+                <div class="detail-item">
+                    <span class="label">Восстановлен:</span> 
+                    <span class="value">%s</span>
+                </div>
+                <div class="detail-item">
+                    <span class="label">Длительность сбоя:</span> 
+                    <span class="value">%s</span>
+                </div>
+%s                <div class="detail-item">
+                    <span class="label">Время ответа:</span> 
+                    <span class="value">%d мс</span>
+                </div>
+            </div>
+        </div>
+        
+        <div class="footer">
+            <p>Это автоматическое уведомление от системы мониторинга PingTower</p>
+            <p>Время отправки: %s</p>
+        </div>
+    </div>
+</body>
+</html>`, req.Site, downSince, req.Time, downtime, incidentHTML(req.IncidentID), req.ResponseTime, req.Time)
+
+	text := fmt.Sprintf(`
+✅ СЕРВИС ВОССТАНОВЛЕН
+
+Сервис: %s
+Статус: ДОСТУПЕН
+Недоступен с: %s
+Восстановлен: %s
+Длительность сбоя: %s
+%sВремя ответа: %d мс
+
+---
+Это автоматическое уведомление от системы мониторинга PingTower
+`, req.Site, downSince, req.Time, downtime, incidentText(req.IncidentID), req.ResponseTime)
 
 	return models.EmailContent{
 		Subject: subject,
 		HTML:    html,
 		Text:    text,
 	}
-}
\ No newline at end of file
+}
+
+func generateCertContent(req models.NotificationRequest) models.EmailContent {
+	daysLeft := "неизвестно"
+	if req.DaysLeft != nil {
+		daysLeft = fmt.Sprintf("%d", *req.DaysLeft)
+	}
+
+	subject := fmt.Sprintf("[WARNING] %s TLS certificate expires in %s days", req.Site, daysLeft)
+	title := "⏳ СЕРТИФИКАТ СКОРО ИСТЕКАЕТ"
+	problem := fmt.Sprintf("До истечения сертификата осталось дней: %s", daysLeft)
+	switch {
+	case req.Event == models.EventCertInvalid:
+		subject = fmt.Sprintf("[WARNING] %s TLS certificate is invalid", req.Site)
+		title = "🔒 СЕРТИФИКАТ НЕ ПРОШЁЛ ПРОВЕРКУ"
+		problem = req.CertError
+	case req.DaysLeft != nil && *req.DaysLeft < 0:
+		subject = fmt.Sprintf("[CRITICAL] %s TLS certificate has expired", req.Site)
+		title = "⌛ СЕРТИФИКАТ ИСТЁК"
+		problem = fmt.Sprintf("Сертификат истёк, дней назад: %d", -*req.DaysLeft)
+	}
+
+	html := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Certificate Warning</title>
+    <style>
+        body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; margin: 0; padding: 20px; background-color: #f5f5f5; }
+        .container { max-width: 600px; margin: 0 auto; background-color: #ffffff; border-radius: 8px; overflow: hidden; box-shadow: 0 4px 6px rgba(0, 0, 0, 0.1); }
+        .header { background-color: #fd7e14; color: white; padding: 20px; text-align: center; }
+        .header h1 { margin: 0; font-size: 24px; }
+        .content { padding: 30px; }
+        .details { background-color: #f8f9fa; padding: 20px; border-radius: 6px; margin: 20px 0; border-left: 4px solid #fd7e14; }
+        .details h3 { margin-top: 0; color: #495057; }
+        .detail-item { margin: 10px 0; }
+        .label { font-weight: bold; color: #495057; }
+        .value { color: #212529; }
+        .footer { background-color: #f8f9fa; padding: 20px; text-align: center; color: #6c757d; font-size: 14px; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>%s</h1>
+        </div>
+        
+        <div class="content">
+            <div class="details">
+                <h3>📊 Детали сертификата:</h3>
+                <div class="detail-item">
+                    <span class="label">Сервис:</span> 
+                    <span class="value">%s</span>
+                </div>
+                <div class="detail-item">
+                    <span class="label">Издатель:</span> 
+                    <span class="value">%s</span>
+                </div>
+                <div class="detail-item">
+                    <span class="label">Действителен до:</span> 
+                    <span class="value">%s</span>
+                </div>
+                <div class="detail-item">
+                    <span class="label">Проблема:</span> 
+                    <span class="value">%s</span>
+                </div>
+            </div>
+        </div>
+        
+        <div class="footer">
+            <p>Это автоматическое уведомление от системы мониторинга PingTower</p>
+            <p>Время отправки: %s</p>
+        </div>
+    </div>
+</body>
+</html>`, title, req.Site, req.CertIssuer, req.CertNotAfter, problem, req.Time)
+
+	text := fmt.Sprintf(`
+%s
+
+Сервис: %s
+Издатель: %s
+Действителен до: %s
+Проблема: %s
+
+---
+Это автоматическое уведомление от системы мониторинга PingTower
+`, title, req.Site, req.CertIssuer, req.CertNotAfter, problem)
+
+	return models.EmailContent{
+		Subject: subject,
+		HTML:    html,
+		Text:    text,
+	}
+}
+
+func generateDNSContent(req models.NotificationRequest) models.EmailContent {
+	subject := fmt.Sprintf("[WARNING] %s %s records changed", req.Site, req.RecordType)
+	title := "🧭 DNS-ЗАПИСИ ИЗМЕНИЛИСЬ"
+	oldRecords := joinRecords(req.OldRecords)
+	newRecords := joinRecords(req.NewRecords)
+
+	html := fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>DNS Change</title>
+    <style>
+        body { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; margin: 0; padding: 20px; background-color: #f5f5f5; }
+        .container { max-width: 600px; margin: 0 auto; background-color: #ffffff; border-radius: 8px; overflow: hidden; box-shadow: 0 4px 6px rgba(0, 0, 0, 0.1); }
+        .header { background-color: #fd7e14; color: white; padding: 20px; text-align: center; }
+        .header h1 { margin: 0; font-size: 24px; }
+        .content { padding: 30px; }
+        .details { background-color: #f8f9fa; padding: 20px; border-radius: 6px; margin: 20px 0; border-left: 4px solid #fd7e14; }
+        .details h3 { margin-top: 0; color: #495057; }
+        .detail-item { margin: 10px 0; }
+        .label { font-weight: bold; color: #495057; }
+        .value { color: #212529; }
+        .footer { background-color: #f8f9fa; padding: 20px; text-align: center; color: #6c757d; font-size: 14px; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>%s</h1>
+        </div>
+        
+        <div class="content">
+            <div class="details">
+                <h3>📊 Детали изменения:</h3>
+                <div class="detail-item">
+                    <span class="label">Домен:</span> 
+                    <span class="value">%s</span>
+                </div>
+                <div class="detail-item">
+                    <span class="label">Тип записи:</span> 
+                    <span class="value">%s</span>
+                </div>
+                <div class="detail-item">
+                    <span class="label">Было:</span> 
+                    <span class="value">%s</span>
+                </div>
+                <div class="detail-item">
+                    <span class="label">Стало:</span> 
+                    <span class="value">%s</span>
+                </div>
+                <div class="detail-item">
+                    <span class="label">Время проверки:</span> 
+                    <span class="value">%s</span>
+                </div>
+            </div>
+        </div>
+        
+        <div class="footer">
+            <p>Это автоматическое уведомление от системы мониторинга PingTower</p>
+        </div>
+    </div>
+</body>
+</html>`, title, req.Site, req.RecordType, oldRecords, newRecords, req.Time)
+
+	text := fmt.Sprintf(`
+%s
+
+Домен: %s
+Тип записи: %s
+Было: %s
+Стало: %s
+Время проверки: %s
+
+---
+Это автоматическое уведомление от системы мониторинга PingTower
+`, title, req.Site, req.RecordType, oldRecords, newRecords, req.Time)
+
+	return models.EmailContent{
+		Subject: subject,
+		HTML:    html,
+		Text:    text,
+	}
+}
+
+// incidentSubject, incidentHTML and incidentText mention the incident in down
+// and recovery emails; they render nothing when no incident ID was sent.
+func incidentSubject(id int) string {
+	if id == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" [Incident #%d]", id)
+}
+
+func incidentHTML(id int) string {
+	if id == 0 {
+		return ""
+	}
+	return fmt.Sprintf(`                <div class="detail-item">
+                    <span class="label">Инцидент:</span> 
+                    <span class="value">#%d</span>
+                </div>
+`, id)
+}
+
+func incidentText(id int) string {
+	if id == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Инцидент: #%d\n", id)
+}
+
+// joinRecords lists DNS records; an empty set is a result too (NXDOMAIN, no records of the type).
+func joinRecords(records []string) string {
+	if len(records) == 0 {
+		return "нет записей"
+	}
+	return strings.Join(records, ", ")
+}
+
+// formatDuration renders an outage length in seconds as "1 ч 5 мин 3 с".
+func formatDuration(seconds int64) string {
+	if seconds <= 0 {
+		return "менее секунды"
+	}
+	d := time.Duration(seconds) * time.Second
+
+	var parts []string
+	if h := int64(d.Hours()); h > 0 {
+		parts = append(parts, fmt.Sprintf("%d ч", h))
+	}
+	if m := int64(d.Minutes()) % 60; m > 0 {
+		parts = append(parts, fmt.Sprintf("%d мин", m))
+	}
+	if s := int64(d.Seconds()) % 60; s > 0 {
+		parts = append(parts, fmt.Sprintf("%d с", s))
+	}
+	return strings.Join(parts, " ")
+}
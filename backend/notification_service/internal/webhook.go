@@ -0,0 +1,201 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"notification_service/configs"
+	"notification_service/models"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+)
+
+// WebhookConfig is the config of a webhook channel
+type WebhookConfig struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method,omitempty"` // POST by default
+	Headers map[string]string `json:"headers,omitempty"`
+	// text/template over the notification, must render JSON; empty means the notification itself
+	BodyTemplate string `json:"body_template,omitempty"`
+	// HMAC-SHA256 key; the signature of "<timestamp>.<body>" goes to X-PingTower-Signature
+	Secret string `json:"secret,omitempty"`
+}
+
+// WebhookChannel posts notifications to a user-defined HTTP endpoint
+type WebhookChannel struct {
+	id   int
+	cfg  WebhookConfig
+	tmpl *template.Template
+}
+
+// webhookFuncs are available in body templates; json renders a value as a JSON literal
+var webhookFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+var errAddressNotAllowed = errors.New("address is not allowed for webhooks")
+
+// sharedAddressSpace is 100.64.0.0/10 (RFC 6598), used by carrier-grade NAT
+// and some cloud VPCs
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// webhookAddressAllowed reports whether a user-defined channel may connect to ip.
+// Loopback, link-local, private and shared ranges are refused, because the
+// services of this deployment (db_service has no auth) live there, unless the
+// operator listed the range in WEBHOOK_ALLOWED_NETS.
+func webhookAddressAllowed(ip net.IP) bool {
+	for _, n := range configs.WebhookAllowedNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return !(ip.IsLoopback() || ip.IsUnspecified() || ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		sharedAddressSpace.Contains(ip))
+}
+
+// webhookClient does not follow redirects, ignores HTTP(S)_PROXY and checks the
+// resolved address of every connection, so a hostname that resolves to an
+// internal address is refused as well
+var webhookClient = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+	Transport: &http.Transport{
+		// No proxy: the proxy would dial the target itself, past the check below
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				ip := net.ParseIP(host)
+				if ip == nil || !webhookAddressAllowed(ip) {
+					return fmt.Errorf("%s: %w", address, errAddressNotAllowed)
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+}
+
+// checkWebhookURL rejects URLs a user-defined channel must not call
+func checkWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook url %q", raw)
+	}
+	if slices.Contains(configs.WebhookDeniedHosts, strings.ToLower(u.Hostname())) {
+		return fmt.Errorf("webhook host %s is not allowed", u.Hostname())
+	}
+	return nil
+}
+
+func NewWebhookChannel(cfg models.ChannelConfig) (*WebhookChannel, error) {
+	c := &WebhookChannel{id: cfg.ID}
+	if err := json.Unmarshal(cfg.Config, &c.cfg); err != nil {
+		return nil, fmt.Errorf("invalid webhook config: %w", err)
+	}
+	if c.cfg.Method == "" {
+		c.cfg.Method = http.MethodPost
+	}
+
+	if err := checkWebhookURL(c.cfg.URL); err != nil {
+		return nil, err
+	}
+
+	if c.cfg.BodyTemplate != "" {
+		var err error
+		c.tmpl, err = template.New("webhook").Funcs(webhookFuncs).Option("missingkey=error").Parse(c.cfg.BodyTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid body template: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// body renders the request body; channel configs never get into it
+func (c *WebhookChannel) body(req models.NotificationRequest) ([]byte, error) {
+	req.Channels = nil
+	if c.tmpl == nil {
+		return json.Marshal(req)
+	}
+
+	var buf bytes.Buffer
+	if err := c.tmpl.Execute(&buf, req); err != nil {
+		return nil, permanent("render body template: %v", err)
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, permanent("body template rendered invalid JSON")
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *WebhookChannel) Send(ctx context.Context, req models.NotificationRequest) error {
+	body, err := c.body(req)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, c.cfg.Method, c.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return permanent("build request: %v", err)
+	}
+	for name, value := range c.cfg.Headers {
+		// api_service rejects these, but a config saved before that must not forge a signature
+		if strings.HasPrefix(strings.ToLower(name), "x-pingtower-") {
+			continue
+		}
+		httpReq.Header.Set(name, value)
+	}
+	if httpReq.Header.Get("Content-Type") == "" {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	httpReq.Header.Set("User-Agent", "PingTower-Webhook/1.0")
+	httpReq.Header.Set("X-PingTower-Event", req.Event)
+	// The same for every retry, so the receiver can drop duplicates
+	httpReq.Header.Set("X-PingTower-Delivery", fmt.Sprintf("%d-%d", req.GetHashCode(), c.id))
+	if c.cfg.Secret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(c.cfg.Secret))
+		mac.Write([]byte(ts + "."))
+		mac.Write(body)
+		httpReq.Header.Set("X-PingTower-Timestamp", ts)
+		httpReq.Header.Set("X-PingTower-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	return doWebhook(httpReq, "webhook")
+}
+
+// doWebhook sends a request to a user-defined URL and drains the response
+func doWebhook(req *http.Request, service string) error {
+	resp, err := webhookClient.Do(req)
+	if errors.Is(err, errAddressNotAllowed) {
+		return permanent("%s request failed: %w", service, err)
+	}
+	if err != nil {
+		return fmt.Errorf("%s request failed: %w", service, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return statusError(service, resp.StatusCode)
+}
@@ -0,0 +1,290 @@
+package internal
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"notification_service/configs"
+	"notification_service/models"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newWebhookTestChannel(t *testing.T, cfg WebhookConfig) *WebhookChannel {
+	t.Helper()
+	raw, _ := json.Marshal(cfg)
+	ch, err := NewWebhookChannel(models.ChannelConfig{ID: 3, Type: models.ChannelWebhook, Config: raw})
+	if err != nil {
+		t.Fatalf("NewWebhookChannel: %v", err)
+	}
+	return ch
+}
+
+// capturedRequest is what the stand-in receiver saw
+type capturedRequest struct {
+	method string
+	header http.Header
+	body   []byte
+}
+
+// webhookReceiver records every request and answers 204
+func webhookReceiver(t *testing.T) (*httptest.Server, *[]capturedRequest) {
+	t.Helper()
+	allowLoopbackWebhooks(t)
+
+	var got []capturedRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = append(got, capturedRequest{method: r.Method, header: r.Header.Clone(), body: body})
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &got
+}
+
+var testNotification = models.NotificationRequest{
+	Email:      "owner@example.com",
+	Site:       `https://a.test/"quoted"`,
+	Event:      models.EventDown,
+	Time:       "2026-01-02T03:04:05Z",
+	Error:      "status 503",
+	IncidentID: 7,
+	Channels:   []models.ChannelConfig{{ID: 3, Type: models.ChannelWebhook, Config: json.RawMessage(`{"secret":"s3cret"}`)}},
+}
+
+func TestWebhookSignature(t *testing.T) {
+	srv, got := webhookReceiver(t)
+	ch := newWebhookTestChannel(t, WebhookConfig{URL: srv.URL, Secret: "s3cret"})
+
+	before := time.Now().Unix()
+	if err := ch.Send(context.Background(), testNotification); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	r := (*got)[0]
+
+	ts := r.header.Get("X-PingTower-Timestamp")
+	if n, err := strconv.ParseInt(ts, 10, 64); err != nil || n < before || n > time.Now().Unix() {
+		t.Errorf("X-PingTower-Timestamp = %q", ts)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(ts + "." + string(r.body)))
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); r.header.Get("X-PingTower-Signature") != want {
+		t.Errorf("X-PingTower-Signature = %q, want %q", r.header.Get("X-PingTower-Signature"), want)
+	}
+}
+
+func TestWebhookUnsignedWithoutSecret(t *testing.T) {
+	srv, got := webhookReceiver(t)
+	if err := newWebhookTestChannel(t, WebhookConfig{URL: srv.URL}).Send(context.Background(), testNotification); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	for _, h := range []string{"X-PingTower-Signature", "X-PingTower-Timestamp"} {
+		if v := (*got)[0].header.Get(h); v != "" {
+			t.Errorf("%s = %q without a secret", h, v)
+		}
+	}
+}
+
+func TestWebhookDefaultBody(t *testing.T) {
+	srv, got := webhookReceiver(t)
+	if err := newWebhookTestChannel(t, WebhookConfig{URL: srv.URL}).Send(context.Background(), testNotification); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	r := (*got)[0]
+	if r.method != http.MethodPost || r.header.Get("Content-Type") != "application/json" {
+		t.Errorf("request = %s %q", r.method, r.header.Get("Content-Type"))
+	}
+	var body map[string]any
+	if err := json.Unmarshal(r.body, &body); err != nil {
+		t.Fatalf("body is not JSON: %v", err)
+	}
+	if body["site"] != testNotification.Site || body["incident_id"] != float64(7) {
+		t.Errorf("body = %s", r.body)
+	}
+	// Channel configs carry secrets and must not reach the receiver
+	if _, ok := body["channels"]; ok || strings.Contains(string(r.body), "s3cret") {
+		t.Errorf("body carries channel configs: %s", r.body)
+	}
+}
+
+func TestWebhookBodyTemplate(t *testing.T) {
+	srv, got := webhookReceiver(t)
+	ch := newWebhookTestChannel(t, WebhookConfig{
+		URL:          srv.URL,
+		Method:       http.MethodPut,
+		BodyTemplate: `{"text": {{json .Site}}, "event": "{{.Event}}", "incident": {{.IncidentID}}}`,
+	})
+	if err := ch.Send(context.Background(), testNotification); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	r := (*got)[0]
+	if r.method != http.MethodPut {
+		t.Errorf("method = %s, want PUT", r.method)
+	}
+	var body struct {
+		Text     string `json:"text"`
+		Event    string `json:"event"`
+		Incident int    `json:"incident"`
+	}
+	if err := json.Unmarshal(r.body, &body); err != nil {
+		t.Fatalf("body %s: %v", r.body, err)
+	}
+	if body.Text != testNotification.Site || body.Event != models.EventDown || body.Incident != 7 {
+		t.Errorf("body = %s", r.body)
+	}
+}
+
+func TestWebhookBodyTemplateErrors(t *testing.T) {
+	tests := []struct {
+		name, template string
+	}{
+		// Without json the quotes in the site break the JSON
+		{"invalid JSON", `{"text": "{{.Site}}"}`},
+		{"unknown field", `{"text": {{json .Nope}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, got := webhookReceiver(t)
+			ch := newWebhookTestChannel(t, WebhookConfig{URL: srv.URL, BodyTemplate: tt.template})
+
+			err := ch.Send(context.Background(), testNotification)
+			var perm *permanentError
+			if !errors.As(err, &perm) {
+				t.Errorf("err = %v, want a permanent error", err)
+			}
+			if len(*got) != 0 {
+				t.Errorf("receiver got %d requests, want none", len(*got))
+			}
+		})
+	}
+
+	cfg, _ := json.Marshal(WebhookConfig{URL: "https://hooks.example.com", BodyTemplate: `{{.Site`})
+	if _, err := NewWebhookChannel(models.ChannelConfig{ID: 3, Type: models.ChannelWebhook, Config: cfg}); err == nil {
+		t.Error("want an error for a template that does not parse")
+	}
+}
+
+func TestWebhookHeaders(t *testing.T) {
+	srv, got := webhookReceiver(t)
+	ch := newWebhookTestChannel(t, WebhookConfig{
+		URL: srv.URL,
+		Headers: map[string]string{
+			"Authorization":         "Bearer abc",
+			"Content-Type":          "application/vnd.api+json",
+			"User-Agent":            "curl/8",
+			"X-PingTower-Signature": "sha256=forged",
+			"x-pingtower-delivery":  "forged",
+		},
+	})
+	for i := 0; i < 2; i++ {
+		if err := ch.Send(context.Background(), testNotification); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+
+	first, second := (*got)[0].header, (*got)[1].header
+	if first.Get("Authorization") != "Bearer abc" || first.Get("Content-Type") != "application/vnd.api+json" {
+		t.Errorf("custom headers lost: %v", first)
+	}
+	if ua := first.Get("User-Agent"); ua != "PingTower-Webhook/1.0" {
+		t.Errorf("User-Agent = %q", ua)
+	}
+	if v := first.Get("X-PingTower-Signature"); v != "" {
+		t.Errorf("reserved header passed through: %q", v)
+	}
+	if v := first.Get("X-PingTower-Event"); v != models.EventDown {
+		t.Errorf("X-PingTower-Event = %q", v)
+	}
+
+	// A retry of the same delivery carries the same X-PingTower-Delivery
+	delivery := first.Get("X-PingTower-Delivery")
+	if delivery == "" || delivery == "forged" || second.Get("X-PingTower-Delivery") != delivery {
+		t.Errorf("X-PingTower-Delivery = %q then %q, want the same generated ID", delivery, second.Get("X-PingTower-Delivery"))
+	}
+	other := testNotification
+	other.Time = "2026-01-02T03:05:05Z"
+	if err := ch.Send(context.Background(), other); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if (*got)[2].header.Get("X-PingTower-Delivery") == delivery {
+		t.Error("another notification reused the delivery ID")
+	}
+}
+
+func TestWebhookAddressAllowed(t *testing.T) {
+	tests := []struct {
+		ip      string
+		allowed bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:4700::1111", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"0.0.0.0", false},
+		{"10.0.0.5", false},
+		{"172.18.0.3", false}, // docker-compose network
+		{"192.168.1.1", false},
+		{"100.64.0.1", false},
+		{"169.254.169.254", false}, // cloud metadata
+		{"fd00::1", false},
+		{"fe80::1", false},
+	}
+	for _, tt := range tests {
+		if got := webhookAddressAllowed(net.ParseIP(tt.ip)); got != tt.allowed {
+			t.Errorf("webhookAddressAllowed(%s) = %v, want %v", tt.ip, got, tt.allowed)
+		}
+	}
+
+	_, internal, _ := net.ParseCIDR("172.16.0.0/12")
+	saved := configs.WebhookAllowedNets
+	configs.WebhookAllowedNets = []*net.IPNet{internal}
+	t.Cleanup(func() { configs.WebhookAllowedNets = saved })
+	if !webhookAddressAllowed(net.ParseIP("172.18.0.3")) || webhookAddressAllowed(net.ParseIP("10.0.0.5")) {
+		t.Error("WEBHOOK_ALLOWED_NETS should open 172.16.0.0/12 and nothing else")
+	}
+}
+
+func TestWebhookRefusesLoopbackByDefault(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached a loopback address")
+	}))
+	defer srv.Close()
+
+	// A hostname too: the check is on the address actually dialled
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	for _, url := range []string{srv.URL, "http://localhost:" + port} {
+		err := newWebhookTestChannel(t, WebhookConfig{URL: url}).Send(context.Background(), testNotification)
+		var perm *permanentError
+		if !errors.Is(err, errAddressNotAllowed) || !errors.As(err, &perm) {
+			t.Errorf("%s: err = %v, want a permanent %v", url, err, errAddressNotAllowed)
+		}
+	}
+
+	if webhookClient.Transport.(*http.Transport).Proxy != nil {
+		t.Error("webhookClient must not go through HTTP(S)_PROXY")
+	}
+}
+
+func TestWebhookDeniedHosts(t *testing.T) {
+	saved := configs.WebhookDeniedHosts
+	configs.WebhookDeniedHosts = []string{"db_service"}
+	t.Cleanup(func() { configs.WebhookDeniedHosts = saved })
+
+	for _, url := range []string{"http://DB_Service:8082/users", "ftp://example.com", "example.com/hook"} {
+		cfg, _ := json.Marshal(WebhookConfig{URL: url})
+		if _, err := NewWebhookChannel(models.ChannelConfig{ID: 3, Type: models.ChannelWebhook, Config: cfg}); err == nil {
+			t.Errorf("%s: want the url to be rejected", url)
+		}
+	}
+}
@@ -1,16 +1,59 @@
 package models
 
-import "hash/fnv"
+import (
+	"encoding/json"
+	"hash/fnv"
+)
+
+// Event types carried in NotificationRequest.Event
+const (
+	EventDown        = "down"
+	EventRecovery    = "recovery"
+	EventCertExpiry  = "cert_expiry"
+	EventCertInvalid = "cert_invalid"
+	EventDNSChanged  = "dns_changed"
+)
+
+// Channel types carried in ChannelConfig.Type
+const (
+	ChannelWebhook  = "webhook"
+	ChannelSlack    = "slack"
+	ChannelTelegram = "telegram"
+)
 
 type NotificationRequest struct {
-	Email string `json:"email"`
-	Site  string `json:"site"`
-	Time  string `json:"time"`
+	Email        string `json:"email"`
+	Site         string `json:"site"`
+	Event        string `json:"event"` // down | recovery | cert_expiry | cert_invalid | dns_changed; empty means down
+	Time         string `json:"time"`
+	ResponseTime int64  `json:"response_time"`
+	DownSince    string `json:"down_since,omitempty"`
+	Duration     int64  `json:"duration,omitempty"`    // outage duration in seconds, for recovery
+	IncidentID   int    `json:"incident_id,omitempty"` // incident opened for the outage, for down and recovery
+	Error        string `json:"error,omitempty"`       // why the last check failed, for down
+	// Certificate details, for cert_expiry and cert_invalid
+	CertIssuer   string `json:"cert_issuer,omitempty"`
+	CertNotAfter string `json:"cert_not_after,omitempty"`
+	DaysLeft     *int   `json:"days_left,omitempty"`
+	CertError    string `json:"cert_error,omitempty"`
+	// DNS records before and after the change, for dns_changed
+	RecordType string   `json:"record_type,omitempty"`
+	OldRecords []string `json:"old_records,omitempty"`
+	NewRecords []string `json:"new_records,omitempty"`
+	// Extra destinations chosen by the user for this site, besides the email
+	Channels []ChannelConfig `json:"channels,omitempty"`
+}
+
+// ChannelConfig is a user's notification channel; Config depends on Type
+type ChannelConfig struct {
+	ID     int             `json:"id"`
+	Type   string          `json:"type"`
+	Config json.RawMessage `json:"config"`
 }
 
 func (r NotificationRequest) GetHashCode() uint32 {
 	h := fnv.New32a()
-	h.Write([]byte(r.Email + r.Site + r.Time))
+	h.Write([]byte(r.Email + r.Site + r.Event + r.Time))
 	return h.Sum32()
 }
 
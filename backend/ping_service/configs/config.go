@@ -9,7 +9,11 @@ import (
 var PingLogger *log.Logger
 var Client *http.Client
 
+// DNSResolver - резолвер (host:port) для dns-проверок, у которых он не задан; пусто - системный
+var DNSResolver string
+
 func Configure() {
 	PingLogger = log.New(os.Stdout, "LOGGER: ", log.LstdFlags)
 	Client = &http.Client{}
+	DNSResolver = os.Getenv("DNS_RESOLVER")
 }
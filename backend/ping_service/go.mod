@@ -1,3 +1,7 @@
 module ping_service
 
 go 1.24.6
+
+require golang.org/x/net v0.38.0
+
+require golang.org/x/sys v0.31.0 // indirect
@@ -0,0 +1,109 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"ping_service/models"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// defaultBodyLimit - сколько тела читаем для проверок, если max_body_size не задан
+const defaultBodyLimit = 1 << 20
+
+// readBody читает тело ответа для проверок. Если задан max_body_size и тело
+// больше него, возвращается ошибка проверки.
+func readBody(body io.Reader, a *models.Assertions) ([]byte, error) {
+	limit := int64(defaultBodyLimit)
+	if a.MaxBodySize > 0 {
+		limit = a.MaxBodySize
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("read body: %v", err)
+	}
+	if int64(len(data)) > limit {
+		if a.MaxBodySize > 0 {
+			return nil, fmt.Errorf("assertion failed: body is larger than %d bytes", a.MaxBodySize)
+		}
+		data = data[:limit]
+	}
+	return data, nil
+}
+
+// checkAssertions проверяет тело ответа и возвращает первую не прошедшую проверку.
+func checkAssertions(body []byte, a *models.Assertions) error {
+	if a.BodyContains != "" && !bytes.Contains(body, []byte(a.BodyContains)) {
+		return fmt.Errorf("assertion failed: body does not contain %q", a.BodyContains)
+	}
+	if a.BodyNotContains != "" && bytes.Contains(body, []byte(a.BodyNotContains)) {
+		return fmt.Errorf("assertion failed: body contains %q", a.BodyNotContains)
+	}
+	if a.BodyRegex != "" {
+		re, err := regexp.Compile(a.BodyRegex)
+		if err != nil {
+			return fmt.Errorf("assertion failed: invalid regex %q: %v", a.BodyRegex, err)
+		}
+		if !re.Match(body) {
+			return fmt.Errorf("assertion failed: body does not match %q", a.BodyRegex)
+		}
+	}
+	if a.JSONPath != "" {
+		var doc any
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return fmt.Errorf("assertion failed: body is not valid JSON: %v", err)
+		}
+		got, err := lookupJSONPath(doc, a.JSONPath)
+		if err != nil {
+			return fmt.Errorf("assertion failed: %v", err)
+		}
+		if len(a.JSONEquals) > 0 {
+			var want any
+			if err := json.Unmarshal(a.JSONEquals, &want); err != nil {
+				return fmt.Errorf("assertion failed: invalid json_equals: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				gotJSON, _ := json.Marshal(got)
+				return fmt.Errorf("assertion failed: %s = %s, want %s", a.JSONPath, gotJSON, a.JSONEquals)
+			}
+		}
+	}
+	return nil
+}
+
+// lookupJSONPath достаёт значение по пути вида "data.items.0.status",
+// "$.data.items[0].status" тоже поддерживается.
+func lookupJSONPath(doc any, path string) (any, error) {
+	p := strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	p = strings.ReplaceAll(p, "[", ".")
+	p = strings.ReplaceAll(p, "]", "")
+
+	cur := doc
+	if p == "" {
+		return cur, nil
+	}
+	for _, key := range strings.Split(p, ".") {
+		switch node := cur.(type) {
+		case map[string]any:
+			v, ok := node[key]
+			if !ok {
+				return nil, fmt.Errorf("path %s: key %q not found", path, key)
+			}
+			cur = v
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, fmt.Errorf("path %s: index %q out of range", path, key)
+			}
+			cur = node[i]
+		default:
+			return nil, fmt.Errorf("path %s: %q is not an object or array", path, key)
+		}
+	}
+	return cur, nil
+}
@@ -0,0 +1,173 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"ping_service/configs"
+	"ping_service/models"
+	"sort"
+	"strings"
+	"time"
+)
+
+const dnsTimeout = 5 * time.Second
+
+// pingDNS резолвит записи нужного типа через заданный (или системный) резолвер
+// и сверяет их с ожидаемым набором. Порядок записей не важен.
+func pingDNS(ctx context.Context, req models.PingRequest) (models.PingResponse, error) {
+	name := dnsName(req.Site)
+	if name == "" {
+		return models.PingResponse{}, fmt.Errorf("invalid domain name")
+	}
+	recordType := strings.ToUpper(req.RecordType)
+	if recordType == "" {
+		recordType = "A"
+	}
+	resolverAddr := req.Resolver
+	if resolverAddr == "" {
+		resolverAddr = configs.DNSResolver
+	}
+	if resolverAddr != "" {
+		if _, _, err := net.SplitHostPort(resolverAddr); err != nil {
+			resolverAddr = net.JoinHostPort(resolverAddr, "53")
+		}
+	}
+
+	pingTime := time.Now().UTC()
+	result := &models.DNSResult{RecordType: recordType, Resolver: resolverAddr}
+
+	ctx, cancel := context.WithTimeout(ctx, dnsTimeout)
+	defer cancel()
+
+	start := time.Now()
+	records, err := lookupRecords(ctx, newResolver(resolverAddr), name, recordType)
+	elapsed := time.Since(start)
+	if err != nil {
+		if _, ok := err.(unsupportedTypeError); ok {
+			return models.PingResponse{}, err
+		}
+		log.Printf("dns lookup error for %s %s: %v", recordType, name, err)
+		return models.PingResponse{
+			PingTime:     pingTime.Format(time.RFC3339Nano),
+			ResponseTime: -1,
+			Error:        err.Error(),
+			DNS:          result,
+		}, nil
+	}
+	result.Records = records
+
+	if len(req.Expected) > 0 {
+		expected := normalizeRecords(recordType, req.Expected)
+		if strings.Join(expected, "\n") != strings.Join(records, "\n") {
+			return models.PingResponse{
+				PingTime:     pingTime.Format(time.RFC3339Nano),
+				ResponseTime: -1,
+				Error:        fmt.Sprintf("%s records of %s are %v, expected %v", recordType, name, records, expected),
+				DNS:          result,
+			}, nil
+		}
+	}
+
+	return models.PingResponse{
+		PingTime:     pingTime.Format(time.RFC3339Nano),
+		ResponseTime: elapsed.Milliseconds(),
+		DNS:          result,
+	}, nil
+}
+
+type unsupportedTypeError string
+
+func (e unsupportedTypeError) Error() string {
+	return "unsupported record type " + string(e)
+}
+
+// newResolver возвращает резолвер, который ходит только на addr; пустой addr - системный.
+func newResolver(addr string) *net.Resolver {
+	if addr == "" {
+		return net.DefaultResolver
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			d := net.Dialer{Timeout: dnsTimeout}
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+}
+
+func lookupRecords(ctx context.Context, r *net.Resolver, name, recordType string) ([]string, error) {
+	var raw []string
+	switch recordType {
+	case "A", "AAAA":
+		network := "ip4"
+		if recordType == "AAAA" {
+			network = "ip6"
+		}
+		ips, err := r.LookupIP(ctx, network, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			raw = append(raw, ip.String())
+		}
+	case "CNAME":
+		cname, err := r.LookupCNAME(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		raw = []string{cname}
+	case "MX":
+		mxs, err := r.LookupMX(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, mx := range mxs {
+			raw = append(raw, fmt.Sprintf("%d %s", mx.Pref, mx.Host))
+		}
+	case "TXT":
+		txts, err := r.LookupTXT(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		raw = txts
+	default:
+		return nil, unsupportedTypeError(recordType)
+	}
+	return normalizeRecords(recordType, raw), nil
+}
+
+// normalizeRecords приводит записи к виду для сравнения: имена без завершающей
+// точки в нижнем регистре, IP в каноничной форме, всё отсортировано.
+func normalizeRecords(recordType string, records []string) []string {
+	out := make([]string, 0, len(records))
+	for _, rec := range records {
+		rec = strings.TrimSpace(rec)
+		switch recordType {
+		case "A", "AAAA":
+			if ip := net.ParseIP(rec); ip != nil {
+				rec = ip.String()
+			}
+		case "CNAME":
+			rec = strings.ToLower(strings.TrimSuffix(rec, "."))
+		case "MX":
+			rec = strings.ToLower(strings.TrimSuffix(strings.Join(strings.Fields(rec), " "), "."))
+		}
+		out = append(out, rec)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// dnsName принимает домен или URL и возвращает имя для запроса.
+func dnsName(site string) string {
+	s := strings.TrimPrefix(site, "dns://")
+	if strings.Contains(s, "://") {
+		return icmpHost(s)
+	}
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
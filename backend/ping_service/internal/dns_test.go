@@ -0,0 +1,187 @@
+package internal
+
+import (
+	"context"
+	"net"
+	"ping_service/models"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// stubResolver - UDP-сервер DNS на 127.0.0.1, отвечающий заранее заданными
+// записями. Неизвестное имя - NXDOMAIN, имя из silent - без ответа.
+type stubResolver struct {
+	conn    net.PacketConn
+	records map[string][]dnsmessage.Resource // ключ - "имя.|тип"
+	silent  map[string]bool
+}
+
+func startStubResolver(t *testing.T) *stubResolver {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &stubResolver{conn: conn, records: map[string][]dnsmessage.Resource{}, silent: map[string]bool{}}
+	t.Cleanup(func() { conn.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *stubResolver) addr() string { return s.conn.LocalAddr().String() }
+
+func (s *stubResolver) add(name string, typ dnsmessage.Type, body dnsmessage.ResourceBody) {
+	n := dnsmessage.MustNewName(name)
+	key := name + "|" + typ.String()
+	s.records[key] = append(s.records[key], dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: n, Type: typ, Class: dnsmessage.ClassINET, TTL: 60},
+		Body:   body,
+	})
+}
+
+func (s *stubResolver) serve() {
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		var p dnsmessage.Parser
+		h, err := p.Start(buf[:n])
+		if err != nil {
+			continue
+		}
+		q, err := p.Question()
+		if err != nil {
+			continue
+		}
+		if s.silent[q.Name.String()] {
+			continue
+		}
+
+		resp := dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: h.ID, Response: true, Authoritative: true, RecursionDesired: h.RecursionDesired},
+			Questions: []dnsmessage.Question{q},
+		}
+		answers, known := s.records[q.Name.String()+"|"+q.Type.String()]
+		if !known && !s.hasName(q.Name.String()) {
+			resp.Header.RCode = dnsmessage.RCodeNameError
+		}
+		resp.Answers = answers
+		out, err := resp.Pack()
+		if err != nil {
+			continue
+		}
+		s.conn.WriteTo(out, from)
+	}
+}
+
+func (s *stubResolver) hasName(name string) bool {
+	for key := range s.records {
+		if strings.HasPrefix(key, name+"|") {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPingDNS(t *testing.T) {
+	stub := startStubResolver(t)
+	stub.add("example.test.", dnsmessage.TypeA, &dnsmessage.AResource{A: [4]byte{192, 0, 2, 20}})
+	stub.add("example.test.", dnsmessage.TypeA, &dnsmessage.AResource{A: [4]byte{192, 0, 2, 10}})
+	stub.add("example.test.", dnsmessage.TypeMX, &dnsmessage.MXResource{Pref: 20, MX: dnsmessage.MustNewName("MX2.Example.Test.")})
+	stub.add("example.test.", dnsmessage.TypeMX, &dnsmessage.MXResource{Pref: 10, MX: dnsmessage.MustNewName("mx1.example.test.")})
+	stub.add("example.test.", dnsmessage.TypeTXT, &dnsmessage.TXTResource{TXT: []string{"v=spf1 -all"}})
+	stub.add("example.test.", dnsmessage.TypeTXT, &dnsmessage.TXTResource{TXT: []string{"google-site-verification=abc"}})
+	stub.silent["slow.example.test."] = true
+
+	tests := []struct {
+		name       string
+		site       string
+		recordType string
+		expected   []string
+		records    []string
+		wantErr    string // пусто - проверка успешна
+	}{
+		{
+			name: "A sorted", site: "example.test", recordType: "A",
+			records: []string{"192.0.2.10", "192.0.2.20"},
+		},
+		{
+			name: "A matches expected in any order", site: "https://example.test/path", recordType: "a",
+			expected: []string{"192.0.2.20", "192.0.2.10"},
+			records:  []string{"192.0.2.10", "192.0.2.20"},
+		},
+		{
+			name: "A mismatch", site: "example.test", recordType: "A",
+			expected: []string{"192.0.2.10"},
+			records:  []string{"192.0.2.10", "192.0.2.20"},
+			wantErr:  "expected",
+		},
+		{
+			name: "MX normalised", site: "dns://example.test", recordType: "MX",
+			expected: []string{"20  mx2.example.test.", "10 MX1.example.test"},
+			records:  []string{"10 mx1.example.test", "20 mx2.example.test"},
+		},
+		{
+			name: "TXT", site: "example.test", recordType: "TXT",
+			expected: []string{"v=spf1 -all", "google-site-verification=abc"},
+			records:  []string{"google-site-verification=abc", "v=spf1 -all"},
+		},
+		{
+			name: "TXT mismatch", site: "example.test", recordType: "TXT",
+			expected: []string{"v=spf1 ~all"},
+			records:  []string{"google-site-verification=abc", "v=spf1 -all"},
+			wantErr:  "expected",
+		},
+		{
+			name: "NXDOMAIN", site: "missing.test", recordType: "A",
+			wantErr: "no such host",
+		},
+		{
+			name: "timeout", site: "slow.example.test", recordType: "A",
+			wantErr: "timeout",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			resp, err := pingDNS(ctx, models.PingRequest{
+				Site: tt.site, RecordType: tt.recordType, Resolver: stub.addr(), Expected: tt.expected,
+			})
+			if err != nil {
+				t.Fatalf("pingDNS: %v", err)
+			}
+
+			if tt.wantErr == "" {
+				if resp.ResponseTime < 0 || resp.Error != "" {
+					t.Fatalf("check failed: response_time=%d error=%q", resp.ResponseTime, resp.Error)
+				}
+			} else {
+				if resp.ResponseTime != -1 {
+					t.Errorf("response_time = %d, want -1", resp.ResponseTime)
+				}
+				if !strings.Contains(resp.Error, tt.wantErr) {
+					t.Errorf("error = %q, want it to contain %q", resp.Error, tt.wantErr)
+				}
+			}
+			if tt.records != nil && !reflect.DeepEqual(resp.DNS.Records, tt.records) {
+				t.Errorf("records = %q, want %q", resp.DNS.Records, tt.records)
+			}
+		})
+	}
+}
+
+func TestPingDNSUnsupportedType(t *testing.T) {
+	_, err := pingDNS(context.Background(), models.PingRequest{Site: "example.test", RecordType: "SRV", Resolver: "127.0.0.1:1"})
+	if err == nil {
+		t.Fatal("want an error for an unsupported record type")
+	}
+}
@@ -3,19 +3,27 @@ package internal
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"ping_service/models"
+	"strings"
 	"time"
 )
 
+// httpClient не переиспользует соединения: проверка сайта раз в минуту
+// почти всегда попадала бы в соединение из пула, и в таймингах не было бы
+// DNS, TCP и TLS, а время ответа не включало бы установку соединения.
+// Каждая проверка - и каждый шаг сценария - открывает соединение заново.
 var httpClient = &http.Client{
 	Timeout: 15 * time.Second,
 	Transport: &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
+		Proxy:             http.ProxyFromEnvironment,
+		DisableKeepAlives: true,
 		DialContext: (&net.Dialer{
 			Timeout:   5 * time.Second,
 			KeepAlive: 30 * time.Second,
@@ -23,7 +31,6 @@ var httpClient = &http.Client{
 		TLSHandshakeTimeout:   10 * time.Second,
 		ResponseHeaderTimeout: 10 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
-		IdleConnTimeout:       90 * time.Second,
 	},
 }
 
@@ -52,6 +59,46 @@ func PingHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	switch req.Type {
+	case "", models.TypeHTTP:
+		// ниже
+	case models.TypeTCP:
+		resp, err := pingTCP(r.Context(), req)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+		return
+	case models.TypeICMP:
+		resp, err := pingICMP(r.Context(), req)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+		return
+	case models.TypeMultistep:
+		resp, err := pingMultistep(r.Context(), req)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+		return
+	case models.TypeDNS:
+		resp, err := pingDNS(r.Context(), req)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+		return
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unknown check type " + req.Type})
+		return
+	}
+
 	target := req.Site
 	if _, err := url.ParseRequestURI(target); err != nil || !hasScheme(target) {
 		target = "https://" + req.Site
@@ -67,11 +114,31 @@ func PingHandler(w http.ResponseWriter, r *http.Request) {
 
 	pingTime := time.Now().UTC()
 
-	reqHTTP, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	method := strings.ToUpper(req.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	var reqBody io.Reader
+	if req.Body != "" {
+		reqBody = strings.NewReader(req.Body)
+	}
+
+	reqHTTP, err := http.NewRequestWithContext(ctx, method, u.String(), reqBody)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "cannot create request: " + err.Error()})
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "cannot create request: " + err.Error()})
 		return
 	}
+	for name, value := range req.Headers {
+		// Host в net/http задаётся полем запроса, а не заголовком
+		if strings.EqualFold(name, "Host") {
+			reqHTTP.Host = value
+			continue
+		}
+		reqHTTP.Header.Set(name, value)
+	}
+
+	trace := newTimingTrace()
+	reqHTTP = reqHTTP.WithContext(httptrace.WithClientTrace(ctx, trace.clientTrace()))
 
 	start := time.Now()
 	resp, err := httpClient.Do(reqHTTP)
@@ -79,33 +146,102 @@ func PingHandler(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		log.Printf("ping error for %s: %v", u.String(), err)
+		// Сертификат не прошёл проверку - всё равно отдаём его, чтобы было видно, что с ним не так
+		var cert *models.CertInfo
+		if isCertError(err) {
+			if c, certErr := fetchUnverifiedCert(ctx, u); certErr == nil {
+				cert = certInfo(c, time.Now())
+				cert.Error = err.Error()
+			}
+		}
 		// Возвращаем 200, но помечаем фейл через response_time=-1 и error
 		writeJSON(w, http.StatusOK, models.PingResponse{
 			PingTime:     pingTime.Format(time.RFC3339Nano),
 			ResponseTime: -1,
 			Error:        err.Error(),
+			Cert:         cert,
+			Timings:      trace.timings(time.Now()),
 		})
 		return
 	}
 	defer resp.Body.Close()
-	io.Copy(io.Discard, resp.Body)
 
-	if resp.StatusCode >= 400 {
-		log.Printf("ping non-2xx for %s: %d", u.String(), resp.StatusCode)
+	var cert *models.CertInfo
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		cert = certInfo(resp.TLS.PeerCertificates[0], time.Now())
+	}
+
+	// Тело читаем, только если есть что в нём проверять
+	var body []byte
+	if !req.Assertions.IsEmpty() {
+		body, err = readBody(resp.Body, req.Assertions)
+		if err != nil {
+			log.Printf("ping body error for %s: %v", u.String(), err)
+			writeJSON(w, http.StatusOK, models.PingResponse{
+				PingTime:     pingTime.Format(time.RFC3339Nano),
+				ResponseTime: -1,
+				Error:        err.Error(),
+				Cert:         cert,
+				Timings:      trace.timings(time.Now()),
+			})
+			return
+		}
+	} else {
+		io.Copy(io.Discard, resp.Body)
+	}
+	timings := trace.timings(time.Now())
+
+	if !statusAccepted(resp.StatusCode, req.ExpectedStatus) {
+		log.Printf("ping unexpected status for %s: %d", u.String(), resp.StatusCode)
+		msg := "http status " + resp.Status
+		if len(req.ExpectedStatus) > 0 {
+			msg = fmt.Sprintf("unexpected http status %s, expected one of %v", resp.Status, req.ExpectedStatus)
+		}
 		writeJSON(w, http.StatusOK, models.PingResponse{
 			PingTime:     pingTime.Format(time.RFC3339Nano),
 			ResponseTime: -1,
-			Error:        "http status " + resp.Status,
+			Error:        msg,
+			Cert:         cert,
+			Timings:      timings,
 		})
 		return
 	}
 
+	if !req.Assertions.IsEmpty() {
+		if err := checkAssertions(body, req.Assertions); err != nil {
+			log.Printf("ping assertion failed for %s: %v", u.String(), err)
+			writeJSON(w, http.StatusOK, models.PingResponse{
+				PingTime:     pingTime.Format(time.RFC3339Nano),
+				ResponseTime: -1,
+				Error:        err.Error(),
+				Cert:         cert,
+				Timings:      timings,
+			})
+			return
+		}
+	}
+
 	writeJSON(w, http.StatusOK, models.PingResponse{
 		PingTime:     pingTime.Format(time.RFC3339Nano),
 		ResponseTime: elapsed.Milliseconds(),
+		Cert:         cert,
+		Timings:      timings,
 	})
 }
 
+// statusAccepted - входит ли код ответа в список ожидаемых; пустой список - любой код < 400.
+func statusAccepted(code int, expected []int) bool {
+	if len(expected) == 0 {
+		return code < 400
+	}
+	for _, c := range expected {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
 func hasScheme(s string) bool {
 	u, err := url.Parse(s)
 	return err == nil && u.Scheme != ""
@@ -0,0 +1,46 @@
+package internal
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"ping_service/models"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// Каждая проверка должна открывать своё соединение, иначе в таймингах
+// не будет connect_ms и dns_ms
+func TestPingHandlerOpensFreshConnection(t *testing.T) {
+	var conns atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		PingHandler(rec, httptest.NewRequest(http.MethodPost, "/ping", strings.NewReader(`{"site":"`+srv.URL+`"}`)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+		}
+		var resp models.PingResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if resp.ResponseTime < 0 || resp.Timings == nil {
+			t.Fatalf("response = %+v", resp)
+		}
+	}
+	if n := conns.Load(); n != 3 {
+		t.Errorf("connections = %d, want one per check", n)
+	}
+}
@@ -0,0 +1,269 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"net"
+	"net/url"
+	"ping_service/models"
+	"strings"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	defaultICMPCount    = 4
+	maxICMPCount        = 20
+	defaultICMPInterval = 200 * time.Millisecond
+	minICMPInterval     = 50 * time.Millisecond
+	defaultICMPTimeout  = time.Second
+	maxICMPDuration     = 10 * time.Second // вся серия, чтобы уложиться в таймаут api_service
+	icmpResolveTimeout  = 2 * time.Second  // резолв хоста перед серией
+)
+
+// icmpConn - открытый ICMP-сокет и то, как с ним работать.
+type icmpConn struct {
+	conn     *icmp.PacketConn
+	dgram    bool // udp-сокет: ядро само подставляет ID, адрес - *net.UDPAddr
+	echoType icmp.Type
+	replyTyp icmp.Type
+	proto    int
+}
+
+// listenICMP открывает непривилегированный datagram-сокет (net.ipv4.ping_group_range),
+// а если ядро его не даёт - raw-сокет (нужен CAP_NET_RAW).
+func listenICMP(ipv6Addr bool) (*icmpConn, error) {
+	c := &icmpConn{echoType: ipv4.ICMPTypeEcho, replyTyp: ipv4.ICMPTypeEchoReply, proto: 1}
+	dgramNet, rawNet, laddr := "udp4", "ip4:icmp", "0.0.0.0"
+	if ipv6Addr {
+		c.echoType, c.replyTyp, c.proto = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply, 58
+		dgramNet, rawNet, laddr = "udp6", "ip6:ipv6-icmp", "::"
+	}
+
+	conn, err := icmp.ListenPacket(dgramNet, laddr)
+	if err == nil {
+		c.conn, c.dgram = conn, true
+		return c, nil
+	}
+	conn, rawErr := icmp.ListenPacket(rawNet, laddr)
+	if rawErr != nil {
+		return nil, fmt.Errorf("cannot open icmp socket: %v; raw: %v", err, rawErr)
+	}
+	c.conn = conn
+	return c, nil
+}
+
+// pingICMP отправляет серию echo-запросов и считает потери, min/avg/max RTT и джиттер.
+// Сайт доступен, если пришёл хотя бы один ответ.
+func pingICMP(ctx context.Context, req models.PingRequest) (models.PingResponse, error) {
+	host := icmpHost(req.Site)
+	if host == "" {
+		return models.PingResponse{}, errors.New("invalid host")
+	}
+
+	count := req.Count
+	if count <= 0 {
+		count = defaultICMPCount
+	}
+	if count > maxICMPCount {
+		count = maxICMPCount
+	}
+	interval := defaultICMPInterval
+	if req.IntervalMs > 0 {
+		interval = time.Duration(req.IntervalMs) * time.Millisecond
+	}
+	if interval < minICMPInterval {
+		interval = minICMPInterval
+	}
+	timeout := defaultICMPTimeout
+	if req.TimeoutMs > 0 {
+		timeout = time.Duration(req.TimeoutMs) * time.Millisecond
+	}
+	// Обрезанная серия дала бы потери и джиттер не по тем пакетам, что настроены
+	burst := icmpBurst(count, interval, timeout)
+	if burst > maxICMPDuration {
+		return models.PingResponse{}, fmt.Errorf("icmp series of %d packets takes up to %s, max %s", count, burst, maxICMPDuration)
+	}
+
+	pingTime := time.Now().UTC()
+	fail := func(err error, stats *models.ICMPStats) models.PingResponse {
+		log.Printf("icmp ping error for %s: %v", host, err)
+		return models.PingResponse{
+			PingTime:     pingTime.Format(time.RFC3339Nano),
+			ResponseTime: -1,
+			Error:        err.Error(),
+			ICMP:         stats,
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, icmpResolveTimeout+burst)
+	defer cancel()
+
+	ipAddr, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil || len(ipAddr) == 0 {
+		return fail(fmt.Errorf("resolve %s: %v", host, err), nil), nil
+	}
+	ip := ipAddr[0].IP
+	for _, a := range ipAddr {
+		if a.IP.To4() != nil { // IPv4 предпочтительнее: IPv6 есть не везде
+			ip = a.IP
+			break
+		}
+	}
+
+	c, err := listenICMP(ip.To4() == nil)
+	if err != nil {
+		return fail(err, nil), nil
+	}
+	defer c.conn.Close()
+
+	rtts, sent := c.run(ctx, ip, count, interval, timeout)
+	stats := icmpStats(sent, rtts)
+	if len(rtts) == 0 {
+		return fail(fmt.Errorf("no echo replies from %s (%d sent)", ip, sent), stats), nil
+	}
+
+	return models.PingResponse{
+		PingTime:     pingTime.Format(time.RFC3339Nano),
+		ResponseTime: int64(math.Round(stats.RTTAvgMs)),
+		ICMP:         stats,
+	}, nil
+}
+
+// icmpBurst - сколько в худшем случае длится серия: ответа на каждый запрос
+// ждём до timeout, а следующий отправляем не раньше чем через interval.
+func icmpBurst(count int, interval, timeout time.Duration) time.Duration {
+	return time.Duration(count-1)*max(interval, timeout) + timeout
+}
+
+// run отправляет count запросов с интервалом interval и ждёт каждый ответ не дольше timeout.
+func (c *icmpConn) run(ctx context.Context, ip net.IP, count int, interval, timeout time.Duration) ([]time.Duration, int) {
+	var dst net.Addr = &net.IPAddr{IP: ip}
+	if c.dgram {
+		dst = &net.UDPAddr{IP: ip}
+	}
+	id := rand.Intn(0xffff)
+
+	var rtts []time.Duration
+	sent := 0
+	for seq := 0; seq < count; seq++ {
+		if ctx.Err() != nil {
+			break
+		}
+		started := time.Now()
+
+		msg := icmp.Message{
+			Type: c.echoType,
+			Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte("pingtower")},
+		}
+		b, err := msg.Marshal(nil)
+		if err != nil {
+			break
+		}
+		if _, err := c.conn.WriteTo(b, dst); err != nil {
+			log.Printf("icmp write to %s: %v", ip, err)
+			break
+		}
+		sent++
+
+		deadline := started.Add(timeout)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		if c.waitReply(id, seq, deadline) {
+			rtts = append(rtts, time.Since(started))
+		}
+
+		if seq < count-1 {
+			if wait := interval - time.Since(started); wait > 0 {
+				select {
+				case <-ctx.Done():
+				case <-time.After(wait):
+				}
+			}
+		}
+	}
+	return rtts, sent
+}
+
+// waitReply читает сокет до ответа на запрос seq или до дедлайна.
+func (c *icmpConn) waitReply(id, seq int, deadline time.Time) bool {
+	c.conn.SetReadDeadline(deadline)
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := c.conn.ReadFrom(buf)
+		if err != nil {
+			return false
+		}
+		msg, err := icmp.ParseMessage(c.proto, buf[:n])
+		if err != nil || msg.Type != c.replyTyp {
+			continue
+		}
+		echo, ok := msg.Body.(*icmp.Echo)
+		// На datagram-сокете ID подменяет ядро, и чужие ответы сюда не попадают
+		if !ok || echo.Seq != seq || (!c.dgram && echo.ID != id) {
+			continue
+		}
+		return true
+	}
+}
+
+// icmpStats сводит RTT серии; джиттер - среднее абсолютное изменение RTT между соседними ответами.
+func icmpStats(sent int, rtts []time.Duration) *models.ICMPStats {
+	st := &models.ICMPStats{PacketsSent: sent, PacketsReceived: len(rtts)}
+	if sent > 0 {
+		st.LossPct = round2(float64(sent-len(rtts)) * 100 / float64(sent))
+	}
+	if len(rtts) == 0 {
+		return st
+	}
+
+	ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+	min, max, sum := ms(rtts[0]), ms(rtts[0]), 0.0
+	jitter := 0.0
+	for i, d := range rtts {
+		v := ms(d)
+		sum += v
+		if v < min {
+			min = v
+		}
+		if v > max {
+			max = v
+		}
+		if i > 0 {
+			jitter += math.Abs(v - ms(rtts[i-1]))
+		}
+	}
+	st.RTTMinMs, st.RTTMaxMs = round2(min), round2(max)
+	st.RTTAvgMs = round2(sum / float64(len(rtts)))
+	if len(rtts) > 1 {
+		st.JitterMs = round2(jitter / float64(len(rtts)-1))
+	}
+	return st
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// icmpHost принимает имя хоста, IP или URL и возвращает хост.
+func icmpHost(site string) string {
+	s := strings.TrimPrefix(site, "icmp://")
+	if strings.Contains(s, "://") {
+		if u, err := url.Parse(s); err == nil {
+			return u.Hostname()
+		}
+		return ""
+	}
+	if h, _, err := net.SplitHostPort(s); err == nil {
+		return h
+	}
+	return strings.Trim(s, "[]/")
+}
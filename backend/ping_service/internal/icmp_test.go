@@ -0,0 +1,34 @@
+package internal
+
+import (
+	"context"
+	"ping_service/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestICMPBurst(t *testing.T) {
+	tests := []struct {
+		count             int
+		interval, timeout time.Duration
+		want              time.Duration
+	}{
+		{4, 200 * time.Millisecond, time.Second, 4 * time.Second},
+		{20, 500 * time.Millisecond, 100 * time.Millisecond, 9600 * time.Millisecond},
+		{1, 5 * time.Second, time.Second, time.Second},
+	}
+	for _, tt := range tests {
+		if got := icmpBurst(tt.count, tt.interval, tt.timeout); got != tt.want {
+			t.Errorf("icmpBurst(%d, %s, %s) = %s, want %s", tt.count, tt.interval, tt.timeout, got, tt.want)
+		}
+	}
+}
+
+// Серия длиннее предела отклоняется, а не обрезается
+func TestPingICMPRejectsLongSeries(t *testing.T) {
+	_, err := pingICMP(context.Background(), models.PingRequest{Site: "192.0.2.1", Count: 20, IntervalMs: 5000})
+	if err == nil || !strings.Contains(err.Error(), "max 10s") {
+		t.Fatalf("err = %v, want the series to be rejected", err)
+	}
+}
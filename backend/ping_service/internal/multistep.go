@@ -0,0 +1,252 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptrace"
+	"net/url"
+	"ping_service/models"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	maxSteps         = 10
+	multistepTimeout = 25 * time.Second // вся цепочка, чтобы уложиться в таймаут клиента api_service
+)
+
+var stepVarRe = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// pingMultistep выполняет шаги по порядку с общим cookie jar, подставляя
+// в запросы переменные, извлечённые из предыдущих ответов. Проверка успешна,
+// если прошли все шаги; на первой неудаче цепочка прерывается.
+func pingMultistep(ctx context.Context, req models.PingRequest) (models.PingResponse, error) {
+	if err := validateSteps(req.Steps); err != nil {
+		return models.PingResponse{}, err
+	}
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{
+		Timeout:   httpClient.Timeout,
+		Transport: httpClient.Transport,
+		Jar:       jar,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, multistepTimeout)
+	defer cancel()
+
+	pingTime := time.Now().UTC()
+	vars := map[string]string{}
+	results := make([]models.StepResult, 0, len(req.Steps))
+	var total int64
+
+	for i, step := range req.Steps {
+		res := runStep(ctx, client, step, vars)
+		results = append(results, res)
+		if res.Error != "" {
+			name := step.Name
+			if name == "" {
+				name = step.URL
+			}
+			log.Printf("multistep %s: step %d (%s) failed: %s", req.Site, i+1, name, res.Error)
+			return models.PingResponse{
+				PingTime:     pingTime.Format(time.RFC3339Nano),
+				ResponseTime: -1,
+				Error:        fmt.Sprintf("step %d (%s): %s", i+1, name, res.Error),
+				Steps:        results,
+			}, nil
+		}
+		total += res.ResponseTime
+	}
+
+	return models.PingResponse{
+		PingTime:     pingTime.Format(time.RFC3339Nano),
+		ResponseTime: total,
+		Steps:        results,
+	}, nil
+}
+
+// validateSteps проверяет описание цепочки до её запуска: ошибки здесь - это 400, а не упавшая проверка.
+func validateSteps(steps []models.Step) error {
+	if len(steps) == 0 {
+		return errors.New("multistep check requires steps")
+	}
+	if len(steps) > maxSteps {
+		return fmt.Errorf("at most %d steps are allowed", maxSteps)
+	}
+	defined := map[string]bool{}
+	for i, step := range steps {
+		if step.URL == "" {
+			return fmt.Errorf("step %d: url is required", i+1)
+		}
+		for _, field := range stepTemplates(step) {
+			for _, m := range stepVarRe.FindAllStringSubmatch(field, -1) {
+				if !defined[m[1]] {
+					return fmt.Errorf("step %d: variable %q is not extracted by previous steps", i+1, m[1])
+				}
+			}
+		}
+		for _, ex := range step.Extract {
+			if ex.Var == "" || ex.Path == "" {
+				return fmt.Errorf("step %d: extract needs var and path", i+1)
+			}
+			switch ex.From {
+			case "header", "json", "cookie":
+			default:
+				return fmt.Errorf("step %d: unknown extract source %q", i+1, ex.From)
+			}
+			defined[ex.Var] = true
+		}
+	}
+	return nil
+}
+
+// stepTemplates - поля шага, в которых подставляются переменные
+func stepTemplates(step models.Step) []string {
+	fields := []string{step.URL, step.Body}
+	for _, v := range step.Headers {
+		fields = append(fields, v)
+	}
+	return fields
+}
+
+func expandVars(s string, vars map[string]string) string {
+	return stepVarRe.ReplaceAllStringFunc(s, func(m string) string {
+		return vars[stepVarRe.FindStringSubmatch(m)[1]]
+	})
+}
+
+// runStep выполняет один шаг и дописывает извлечённые переменные в vars.
+func runStep(ctx context.Context, client *http.Client, step models.Step, vars map[string]string) models.StepResult {
+	method := strings.ToUpper(step.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	// В результат идёт шаблон URL: после подстановки в нём может оказаться токен
+	res := models.StepResult{Name: step.Name, URL: step.URL, Method: method, ResponseTime: -1}
+
+	target := expandVars(step.URL, vars)
+	if !hasScheme(target) {
+		target = "https://" + target
+	}
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" {
+		res.Error = "invalid step URL"
+		return res
+	}
+
+	var body io.Reader
+	if step.Body != "" {
+		body = strings.NewReader(expandVars(step.Body, vars))
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		res.Error = "cannot create request: " + err.Error()
+		return res
+	}
+	for name, value := range step.Headers {
+		value = expandVars(value, vars)
+		if strings.EqualFold(name, "Host") {
+			httpReq.Host = value
+			continue
+		}
+		httpReq.Header.Set(name, value)
+	}
+
+	trace := newTimingTrace()
+	httpReq = httpReq.WithContext(httptrace.WithClientTrace(ctx, trace.clientTrace()))
+
+	start := time.Now()
+	resp, err := client.Do(httpReq)
+	elapsed := time.Since(start)
+	if err != nil {
+		res.Error = err.Error()
+		res.Timings = trace.timings(time.Now())
+		return res
+	}
+	defer resp.Body.Close()
+	res.StatusCode = resp.StatusCode
+
+	assertions := step.Assertions
+	if assertions == nil {
+		assertions = &models.Assertions{}
+	}
+	data, err := readBody(resp.Body, assertions)
+	res.Timings = trace.timings(time.Now())
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	if !statusAccepted(resp.StatusCode, step.ExpectedStatus) {
+		res.Error = "http status " + resp.Status
+		if len(step.ExpectedStatus) > 0 {
+			res.Error = fmt.Sprintf("unexpected http status %s, expected one of %v", resp.Status, step.ExpectedStatus)
+		}
+		return res
+	}
+	if err := checkAssertions(data, assertions); err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	for _, ex := range step.Extract {
+		value, err := extractValue(ex, resp, data, client.Jar)
+		if err != nil {
+			res.Error = fmt.Sprintf("extract %s: %v", ex.Var, err)
+			return res
+		}
+		vars[ex.Var] = value
+		res.Extracted = append(res.Extracted, ex.Var)
+	}
+
+	res.ResponseTime = elapsed.Milliseconds()
+	return res
+}
+
+// extractValue достаёт значение переменной из заголовка, JSON-тела или cookie ответа.
+func extractValue(ex models.Extract, resp *http.Response, body []byte, jar http.CookieJar) (string, error) {
+	switch ex.From {
+	case "header":
+		v := resp.Header.Get(ex.Path)
+		if v == "" {
+			return "", fmt.Errorf("header %q not found", ex.Path)
+		}
+		return v, nil
+	case "cookie":
+		for _, c := range resp.Cookies() {
+			if c.Name == ex.Path {
+				return c.Value, nil
+			}
+		}
+		// Cookie могла прийти на одном из редиректов - тогда она уже в jar
+		for _, c := range jar.Cookies(resp.Request.URL) {
+			if c.Name == ex.Path {
+				return c.Value, nil
+			}
+		}
+		return "", fmt.Errorf("cookie %q not found", ex.Path)
+	case "json":
+		var doc any
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return "", fmt.Errorf("body is not valid JSON: %v", err)
+		}
+		v, err := lookupJSONPath(doc, ex.Path)
+		if err != nil {
+			return "", err
+		}
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+		b, _ := json.Marshal(v)
+		return string(b), nil
+	}
+	return "", fmt.Errorf("unknown extract source %q", ex.From)
+}
@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"ping_service/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTCPTimeout = 5 * time.Second
+	maxTCPTimeout     = 30 * time.Second
+	maxBannerSize     = 4096
+)
+
+// pingTCP проверяет, что порт принимает соединения и, если задан banner,
+// что сервер первым делом присылает ожидаемую строку (SMTP, SSH, FTP...).
+// Ошибка возвращается только для некорректного запроса; недоступность порта
+// - это ResponseTime=-1 и Error, как у HTTP-проверки.
+func pingTCP(ctx context.Context, req models.PingRequest) (models.PingResponse, error) {
+	addr, err := tcpAddress(req.Site)
+	if err != nil {
+		return models.PingResponse{}, err
+	}
+
+	timeout := defaultTCPTimeout
+	if req.TimeoutMs > 0 {
+		timeout = time.Duration(req.TimeoutMs) * time.Millisecond
+	}
+	if timeout > maxTCPTimeout {
+		timeout = maxTCPTimeout
+	}
+
+	pingTime := time.Now().UTC()
+	fail := func(err error) models.PingResponse {
+		log.Printf("tcp ping error for %s: %v", addr, err)
+		return models.PingResponse{
+			PingTime:     pingTime.Format(time.RFC3339Nano),
+			ResponseTime: -1,
+			Error:        err.Error(),
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	elapsed := time.Since(start)
+	if err != nil {
+		return fail(err), nil
+	}
+	defer conn.Close()
+
+	if req.Banner != "" {
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		got, err := readBanner(conn, req.Banner)
+		if err != nil {
+			return fail(fmt.Errorf("banner %q not received: %v (got %q)", req.Banner, err, got)), nil
+		}
+	}
+
+	return models.PingResponse{
+		PingTime:     pingTime.Format(time.RFC3339Nano),
+		ResponseTime: elapsed.Milliseconds(),
+		Timings:      &models.Timings{ConnectMs: elapsed.Milliseconds()},
+	}, nil
+}
+
+// readBanner читает из соединения, пока не встретит want, не истечёт дедлайн
+// или не наберётся maxBannerSize байт.
+func readBanner(conn net.Conn, want string) (string, error) {
+	var buf bytes.Buffer
+	chunk := make([]byte, 512)
+	for buf.Len() < maxBannerSize {
+		n, err := conn.Read(chunk)
+		buf.Write(chunk[:n])
+		if strings.Contains(buf.String(), want) {
+			return buf.String(), nil
+		}
+		if err != nil {
+			return buf.String(), err
+		}
+	}
+	return buf.String(), fmt.Errorf("no match in first %d bytes", maxBannerSize)
+}
+
+// tcpAddress приводит "host:port" или "tcp://host:port" к адресу для Dial.
+func tcpAddress(site string) (string, error) {
+	addr := strings.TrimPrefix(site, "tcp://")
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return "", fmt.Errorf("tcp site must be host:port")
+	}
+	if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+		return "", fmt.Errorf("invalid port %q", port)
+	}
+	return net.JoinHostPort(host, port), nil
+}
@@ -0,0 +1,60 @@
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/url"
+	"ping_service/models"
+	"time"
+)
+
+// certInfo описывает листовой сертификат сервера.
+func certInfo(cert *x509.Certificate, now time.Time) *models.CertInfo {
+	return &models.CertInfo{
+		Issuer:    cert.Issuer.String(),
+		Subject:   cert.Subject.String(),
+		DNSNames:  cert.DNSNames,
+		NotBefore: cert.NotBefore.UTC().Format(time.RFC3339),
+		NotAfter:  cert.NotAfter.UTC().Format(time.RFC3339),
+		DaysLeft:  int(cert.NotAfter.Sub(now).Hours() / 24),
+	}
+}
+
+// isCertError - упал ли запрос на проверке сертификата (цепочка, имя, срок).
+func isCertError(err error) bool {
+	var verifyErr *tls.CertificateVerificationError
+	var unknownAuth x509.UnknownAuthorityError
+	var invalid x509.CertificateInvalidError
+	var hostname x509.HostnameError
+	return errors.As(err, &verifyErr) || errors.As(err, &unknownAuth) ||
+		errors.As(err, &invalid) || errors.As(err, &hostname)
+}
+
+// fetchUnverifiedCert повторяет TLS-рукопожатие без проверки цепочки, чтобы
+// вернуть в ответе сертификат, который не прошёл валидацию.
+func fetchUnverifiedCert(ctx context.Context, u *url.URL) (*x509.Certificate, error) {
+	host := u.Hostname()
+	port := u.Port()
+	if port == "" {
+		port = "443"
+	}
+
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: 5 * time.Second},
+		Config:    &tls.Config{ServerName: host, InsecureSkipVerify: true},
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, errors.New("server sent no certificates")
+	}
+	return certs[0], nil
+}
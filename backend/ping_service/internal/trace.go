@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"ping_service/models"
+	"sync"
+	"time"
+)
+
+// timingTrace собирает моменты этапов запроса через httptrace.
+// Колбэки могут вызываться из разных горутин (несколько адресов при dial), поэтому под мьютексом.
+type timingTrace struct {
+	mu        sync.Mutex
+	start     time.Time
+	dnsStart  time.Time
+	dnsDone   time.Time
+	connStart time.Time
+	connDone  time.Time
+	tlsStart  time.Time
+	tlsDone   time.Time
+	wrote     time.Time
+	firstByte time.Time
+}
+
+func newTimingTrace() *timingTrace {
+	return &timingTrace{start: time.Now()}
+}
+
+func (t *timingTrace) mark(field *time.Time, once bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if once && !field.IsZero() {
+		return
+	}
+	*field = time.Now()
+}
+
+func (t *timingTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { t.mark(&t.dnsStart, true) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { t.mark(&t.dnsDone, false) },
+		ConnectStart:      func(string, string) { t.mark(&t.connStart, true) },
+		ConnectDone:       func(string, string, error) { t.mark(&t.connDone, false) },
+		TLSHandshakeStart: func() { t.mark(&t.tlsStart, true) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { t.mark(&t.tlsDone, false) },
+		WroteRequest:      func(httptrace.WroteRequestInfo) { t.mark(&t.wrote, false) },
+		GotFirstResponseByte: func() {
+			t.mark(&t.firstByte, true)
+		},
+	}
+}
+
+// timings раскладывает запрос по этапам; end - момент, когда тело дочитано (или запрос упал).
+// Этапы, которых не было (http без TLS, IP вместо имени), остаются нулевыми.
+func (t *timingTrace) timings(end time.Time) *models.Timings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	res := &models.Timings{
+		DNSMs:     since(t.dnsStart, t.dnsDone),
+		ConnectMs: since(t.connStart, t.connDone),
+		TLSMs:     since(t.tlsStart, t.tlsDone),
+	}
+	if !t.firstByte.IsZero() {
+		sent := t.wrote
+		if sent.IsZero() {
+			sent = t.start
+		}
+		res.TTFBMs = since(sent, t.firstByte)
+		res.TransferMs = since(t.firstByte, end)
+	}
+	return res
+}
+
+func since(from, to time.Time) int64 {
+	if from.IsZero() || to.IsZero() || to.Before(from) {
+		return 0
+	}
+	return to.Sub(from).Milliseconds()
+}
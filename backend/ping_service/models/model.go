@@ -1,11 +1,137 @@
 package models
 
+import "encoding/json"
+
+// Типы проверок
+const (
+	TypeHTTP = "http"
+	TypeTCP  = "tcp"
+	TypeICMP = "icmp"
+	TypeDNS  = "dns"
+	// Цепочка HTTP-запросов (логин -> токен -> защищённый API); шаги описаны в Steps
+	TypeMultistep = "multistep"
+)
+
 type PingRequest struct {
-	Site string `json:"site"`
+	Site       string      `json:"site"`           // для tcp - host:port
+	Type       string      `json:"type,omitempty"` // http (по умолчанию) | tcp
+	Assertions *Assertions `json:"assertions,omitempty"`
+	// Метод по умолчанию GET; без ExpectedStatus успехом считается любой код < 400
+	Method         string            `json:"method,omitempty"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	Body           string            `json:"body,omitempty"`
+	ExpectedStatus []int             `json:"expected_status,omitempty"`
+	// Для tcp: таймаут соединения (по умолчанию 5 с) и строка, которую сервер должен прислать первой
+	TimeoutMs int    `json:"timeout_ms,omitempty"`
+	Banner    string `json:"banner,omitempty"`
+	// Для icmp: сколько echo-запросов в серии (по умолчанию 4) и пауза между ними (по умолчанию 200 мс);
+	// TimeoutMs - ожидание каждого ответа (по умолчанию 1 с)
+	Count      int `json:"count,omitempty"`
+	IntervalMs int `json:"interval_ms,omitempty"`
+	// Для dns: тип записи (A, AAAA, CNAME, MX, TXT; по умолчанию A), резолвер host:port
+	// и ожидаемый набор записей (MX - "10 mx.example.com"); пустой Expected - только резолвинг
+	RecordType string   `json:"record_type,omitempty"`
+	Resolver   string   `json:"resolver,omitempty"`
+	Expected   []string `json:"expected,omitempty"`
+	// Для multistep: шаги выполняются по порядку до первой неудачи
+	Steps []Step `json:"steps,omitempty"`
+}
+
+// Step - один HTTP-запрос multistep-проверки. В URL, значениях заголовков и теле
+// можно подставлять {{имя}} переменных, извлечённых предыдущими шагами.
+type Step struct {
+	Name           string            `json:"name,omitempty"`
+	URL            string            `json:"url"`
+	Method         string            `json:"method,omitempty"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	Body           string            `json:"body,omitempty"`
+	ExpectedStatus []int             `json:"expected_status,omitempty"`
+	Assertions     *Assertions       `json:"assertions,omitempty"`
+	Extract        []Extract         `json:"extract,omitempty"`
+}
+
+// Extract - как достать переменную из ответа шага
+type Extract struct {
+	Var  string `json:"var"`
+	From string `json:"from"` // header | json | cookie
+	Path string `json:"path"` // имя заголовка или cookie, путь JSON ("data.token")
+}
+
+// StepResult - итог одного шага; значения переменных не возвращаются, там бывают токены
+type StepResult struct {
+	Name         string   `json:"name,omitempty"`
+	URL          string   `json:"url"`
+	Method       string   `json:"method"`
+	StatusCode   int      `json:"status_code,omitempty"`
+	ResponseTime int64    `json:"response_time"` // -1 - шаг не прошёл
+	Error        string   `json:"error,omitempty"`
+	Timings      *Timings `json:"timings,omitempty"`
+	Extracted    []string `json:"extracted,omitempty"` // имена извлечённых переменных
+}
+
+// Assertions - проверки тела ответа; пустые поля не проверяются
+type Assertions struct {
+	BodyContains    string          `json:"body_contains,omitempty"`
+	BodyNotContains string          `json:"body_not_contains,omitempty"`
+	BodyRegex       string          `json:"body_regex,omitempty"`
+	JSONPath        string          `json:"json_path,omitempty"`   // например "data.items.0.status"
+	JSONEquals      json.RawMessage `json:"json_equals,omitempty"` // ожидаемое значение по JSONPath
+	MaxBodySize     int64           `json:"max_body_size,omitempty"`
+}
+
+// IsEmpty сообщает, что проверять в теле нечего: нет ни одной проверки (в том числе {} от api_service)
+func (a *Assertions) IsEmpty() bool {
+	return a == nil || (a.BodyContains == "" && a.BodyNotContains == "" && a.BodyRegex == "" &&
+		a.JSONPath == "" && len(a.JSONEquals) == 0 && a.MaxBodySize == 0)
 }
 
 type PingResponse struct {
-	PingTime     string `json:"ping_time"`
-	ResponseTime int64  `json:"response_time"`
-	Error        string `json:"error,omitempty"`
+	PingTime     string     `json:"ping_time"`
+	ResponseTime int64      `json:"response_time"`
+	Error        string     `json:"error,omitempty"`
+	Cert         *CertInfo  `json:"cert,omitempty"` // только для https
+	Timings      *Timings   `json:"timings,omitempty"`
+	ICMP         *ICMPStats `json:"icmp,omitempty"`
+	DNS          *DNSResult `json:"dns,omitempty"`
+	// Для multistep: шаги до первого упавшего включительно; ResponseTime - сумма шагов
+	Steps []StepResult `json:"steps,omitempty"`
+}
+
+// DNSResult - что вернул резолвер; записи нормализованы и отсортированы
+type DNSResult struct {
+	RecordType string   `json:"record_type"`
+	Resolver   string   `json:"resolver,omitempty"`
+	Records    []string `json:"records"`
+}
+
+// ICMPStats - итог серии echo-запросов
+type ICMPStats struct {
+	PacketsSent     int     `json:"packets_sent"`
+	PacketsReceived int     `json:"packets_received"`
+	LossPct         float64 `json:"loss_pct"`
+	RTTMinMs        float64 `json:"rtt_min_ms"`
+	RTTAvgMs        float64 `json:"rtt_avg_ms"`
+	RTTMaxMs        float64 `json:"rtt_max_ms"`
+	JitterMs        float64 `json:"jitter_ms"`
+}
+
+// Timings - длительность этапов запроса, мс; этапы, которых не было
+// (соединение переиспользовано, http без TLS), равны 0
+type Timings struct {
+	DNSMs      int64 `json:"dns_ms"`
+	ConnectMs  int64 `json:"connect_ms"`
+	TLSMs      int64 `json:"tls_ms"`
+	TTFBMs     int64 `json:"ttfb_ms"`     // от отправки запроса до первого байта ответа
+	TransferMs int64 `json:"transfer_ms"` // от первого байта до конца тела
+}
+
+// CertInfo - листовой TLS-сертификат сервера
+type CertInfo struct {
+	Issuer    string   `json:"issuer"`
+	Subject   string   `json:"subject"`
+	DNSNames  []string `json:"dns_names,omitempty"`
+	NotBefore string   `json:"not_before"` // RFC3339
+	NotAfter  string   `json:"not_after"`  // RFC3339
+	DaysLeft  int      `json:"days_left"`
+	Error     string   `json:"error,omitempty"` // ошибка проверки цепочки или имени
 }